@@ -0,0 +1,33 @@
+package admin
+
+import (
+	"go_node_engine/model"
+	"time"
+)
+
+// API_PREFIX is the path prefix of the admin API version served
+const API_PREFIX = "/v1"
+
+// Instance is an instance deployed on the node, with its spec, status and last sampled usage
+type Instance struct {
+	Sname     string           `json:"job_name"`
+	Instance  int              `json:"instance_number"`
+	Runtime   string           `json:"virtualization"`
+	Status    string           `json:"status"`
+	Reason    string           `json:"status_reason,omitempty"`
+	Detail    string           `json:"status_detail,omitempty"`
+	Resources *model.Resources `json:"resources,omitempty"`
+	Spec      model.Service    `json:"spec"`
+}
+
+// NodeInfo is the node as known locally, whether or not the cluster is reachable
+type NodeInfo struct {
+	Node         model.Node              `json:"node"`
+	Registration model.RegistrationState `json:"registration"`
+	Uptime       time.Duration           `json:"uptime"`
+}
+
+// Error is the body of the failed requests
+type Error struct {
+	Error string `json:"error"`
+}
@@ -0,0 +1,141 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go_node_engine/model"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// Client talks to the admin API of the NodeEngine running on the node
+type Client struct {
+	socket string
+	http   *http.Client
+}
+
+// NewClient returns a client of the admin API served on the unix socket
+func NewClient(socket string) *Client {
+	return &Client{
+		socket: socket,
+		http: &http.Client{Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+			},
+		}},
+	}
+}
+
+// Instances returns the instances deployed on the node
+func (c *Client) Instances(ctx context.Context) ([]Instance, error) {
+	instances := make([]Instance, 0)
+	return instances, c.getJSON(ctx, "/instances", &instances)
+}
+
+// Instance returns an instance deployed on the node
+func (c *Client) Instance(ctx context.Context, sname string, instance int) (Instance, error) {
+	var deployed Instance
+	return deployed, c.getJSON(ctx, instancePath(sname, instance, ""), &deployed)
+}
+
+// Node returns the node info
+func (c *Client) Node(ctx context.Context) (NodeInfo, error) {
+	var node NodeInfo
+	return node, c.getJSON(ctx, "/node", &node)
+}
+
+// Events returns the recent events of the node, oldest first
+func (c *Client) Events(ctx context.Context) ([]model.Event, error) {
+	events := make([]model.Event, 0)
+	return events, c.getJSON(ctx, "/events", &events)
+}
+
+// Config returns the configuration in use, as YAML
+func (c *Client) Config(ctx context.Context) ([]byte, error) {
+	response, err := c.do(ctx, http.MethodGet, "/config")
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	return io.ReadAll(response.Body)
+}
+
+// Undeploy stops the instance and releases its resources
+func (c *Client) Undeploy(ctx context.Context, sname string, instance int) error {
+	return c.post(ctx, instancePath(sname, instance, ACTION_UNDEPLOY))
+}
+
+// Pause freezes the instance
+func (c *Client) Pause(ctx context.Context, sname string, instance int) error {
+	return c.post(ctx, instancePath(sname, instance, ACTION_PAUSE))
+}
+
+// Resume lets a paused instance continue
+func (c *Client) Resume(ctx context.Context, sname string, instance int) error {
+	return c.post(ctx, instancePath(sname, instance, ACTION_RESUME))
+}
+
+// Logs copies the last lines of the instance output to w, then the new output until ctx is done if following it
+func (c *Client) Logs(ctx context.Context, sname string, instance int, tail int, follow bool, w io.Writer) error {
+	query := url.Values{"tail": {strconv.Itoa(tail)}, "follow": {strconv.FormatBool(follow)}}
+	response, err := c.do(ctx, http.MethodGet, instancePath(sname, instance, ACTION_LOGS)+"?"+query.Encode())
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	_, err = io.Copy(w, response.Body)
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+func (c *Client) getJSON(ctx context.Context, path string, body interface{}) error {
+	response, err := c.do(ctx, http.MethodGet, path)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	return json.NewDecoder(response.Body).Decode(body)
+}
+
+func (c *Client) post(ctx context.Context, path string) error {
+	response, err := c.do(ctx, http.MethodPost, path)
+	if err != nil {
+		return err
+	}
+	return response.Body.Close()
+}
+
+// do sends the request, returning the error reported by the API if it fails
+func (c *Client) do(ctx context.Context, method string, path string) (*http.Response, error) {
+	request, err := http.NewRequestWithContext(ctx, method, "http://node-engine"+API_PREFIX+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := c.http.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("unable to reach the NodeEngine admin socket %s: %v", c.socket, err)
+	}
+	if response.StatusCode >= 300 {
+		defer response.Body.Close()
+		var failure Error
+		if err := json.NewDecoder(response.Body).Decode(&failure); err != nil || failure.Error == "" {
+			return nil, fmt.Errorf("admin API answered %s", response.Status)
+		}
+		return nil, fmt.Errorf("%s", failure.Error)
+	}
+	return response, nil
+}
+
+func instancePath(sname string, instance int, action string) string {
+	path := fmt.Sprintf("/instances/%s/%d", url.PathEscape(sname), instance)
+	if action != "" {
+		path += "/" + action
+	}
+	return path
+}
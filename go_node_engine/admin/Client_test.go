@@ -0,0 +1,50 @@
+package admin
+
+import (
+	"bytes"
+	"context"
+	"go_node_engine/model"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestClient(t *testing.T) {
+	client := NewClient(serveSocket(t))
+	ctx := context.Background()
+
+	instances, err := client.Instances(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, len(instances), 0)
+
+	node, err := client.Node(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, node.Registration, model.GetRegistrationState())
+
+	configuration, err := client.Config(ctx)
+	assert.NilError(t, err)
+	assert.Assert(t, strings.Contains(string(configuration), "admin:"))
+
+	_, err = client.Instance(ctx, "app", 3)
+	assert.Error(t, err, "instance not deployed")
+	assert.Error(t, client.Pause(ctx, "app", 3), "instance not deployed")
+
+	events, err := client.Events(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, events[len(events)-1].Message, "pause requested")
+
+	directory := t.TempDir()
+	model.SetLogDirectory(directory)
+	assert.NilError(t, os.WriteFile(filepath.Join(directory, "app.instance.3"), []byte("one\ntwo\n"), 0644))
+	output := &bytes.Buffer{}
+	assert.NilError(t, client.Logs(ctx, "app", 3, 1, false, output))
+	assert.Equal(t, output.String(), "two\n")
+}
+
+func TestClientUnreachable(t *testing.T) {
+	_, err := NewClient(filepath.Join(t.TempDir(), "missing.sock")).Instances(context.Background())
+	assert.ErrorContains(t, err, "unable to reach the NodeEngine admin socket")
+}
@@ -0,0 +1,118 @@
+package admin
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go_node_engine/virtualization"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// LOGS_TAIL is the number of lines returned when not given
+const LOGS_TAIL = 100
+
+// LOGS_TAIL_MAX_BYTES bounds how much of the end of the log file is read for the tail
+const LOGS_TAIL_MAX_BYTES = 1 << 20
+
+// LOGS_FOLLOW_CYCLE is how often the followed log file is checked for new output
+const LOGS_FOLLOW_CYCLE = 500 * time.Millisecond
+
+// tailLogs writes the last lines of the instance output, then the new output while following it.
+// The output of an instance is kept after it stops, so its logs can be read also once it is gone.
+func tailLogs(w http.ResponseWriter, r *http.Request, sname string, instance int) {
+	lines := LOGS_TAIL
+	if tail := r.URL.Query().Get("tail"); tail != "" {
+		number, err := strconv.Atoi(tail)
+		if err != nil || number < 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid tail %s", tail))
+			return
+		}
+		lines = number
+	}
+	follow, _ := strconv.ParseBool(r.URL.Query().Get("follow"))
+
+	file, err := os.Open(virtualization.LogPath(sname, instance))
+	if errors.Is(err, os.ErrNotExist) {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no logs for %s instance %d", sname, instance))
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	offset, tail, err := lastLines(file, lines)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = w.Write(tail)
+	flusher, canFlush := w.(http.Flusher)
+	if !follow || !canFlush {
+		return
+	}
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(LOGS_FOLLOW_CYCLE):
+		}
+		stat, err := file.Stat()
+		if err != nil {
+			return
+		}
+		// the file was truncated, start over
+		if stat.Size() < offset {
+			offset = 0
+		}
+		if stat.Size() == offset {
+			continue
+		}
+		written, err := io.Copy(w, io.NewSectionReader(file, offset, stat.Size()-offset))
+		offset += written
+		if err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// lastLines returns the last lines of the file and the offset of its end
+func lastLines(file *os.File, lines int) (int64, []byte, error) {
+	stat, err := file.Stat()
+	if err != nil {
+		return 0, nil, err
+	}
+	size := stat.Size()
+	start := size - LOGS_TAIL_MAX_BYTES
+	if start < 0 {
+		start = 0
+	}
+	content := make([]byte, size-start)
+	if _, err := file.ReadAt(content, start); err != nil && !errors.Is(err, io.EOF) {
+		return 0, nil, err
+	}
+	// the last line counted is the one terminated by the last newline, if any
+	end := len(content)
+	if end > 0 && content[end-1] == '\n' {
+		end--
+	}
+	cut := end
+	for found := 0; found < lines && cut >= 0; found++ {
+		cut = bytes.LastIndexByte(content[:cut], '\n')
+	}
+	if lines == 0 {
+		cut = len(content) - 1
+	}
+	return size, content[cut+1:], nil
+}
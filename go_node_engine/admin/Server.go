@@ -0,0 +1,226 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go_node_engine/config"
+	"go_node_engine/deployment"
+	"go_node_engine/logger"
+	"go_node_engine/model"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var log = logger.Module("admin")
+
+var started = time.Now()
+
+// SOCKET_MODE restricts the admin API to the owner of the socket and its group
+const SOCKET_MODE = 0660
+
+// Actions on a deployed instance, requested with POST /v1/instances/<service>/<instance>/<action>
+const (
+	ACTION_UNDEPLOY = "undeploy"
+	ACTION_PAUSE    = "pause"
+	ACTION_RESUME   = "resume"
+)
+
+// ACTION_LOGS tails the output of an instance, GET /v1/instances/<service>/<instance>/logs
+const ACTION_LOGS = "logs"
+
+// Serve listens on the unix socket and serves the admin API in the background.
+// A socket left by a previous run is replaced, closing the returned server removes it.
+func Serve(socket string) (*http.Server, error) {
+	if err := os.MkdirAll(filepath.Dir(socket), 0755); err != nil {
+		return nil, err
+	}
+	if err := os.Remove(socket); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(socket, SOCKET_MODE); err != nil {
+		_ = listener.Close()
+		return nil, err
+	}
+	server := &http.Server{
+		Handler:           Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		log.Info("Serving the admin API", "socket", socket)
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error("Admin API stopped", logger.ERROR, err)
+		}
+	}()
+	return server, nil
+}
+
+// Handler serves the admin API:
+//
+//	GET  /v1/node                                 node info and registration state
+//	GET  /v1/events                               recent events, oldest first
+//	GET  /v1/config                               configuration in use, as YAML
+//	GET  /v1/instances                            deployed instances
+//	GET  /v1/instances/<service>/<instance>       a deployed instance
+//	GET  /v1/instances/<service>/<instance>/logs  instance output, ?tail=<lines>&follow=true
+//	POST /v1/instances/<service>/<instance>/<undeploy|pause|resume>
+func Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(API_PREFIX+"/node", get(nodeHandler))
+	mux.HandleFunc(API_PREFIX+"/events", get(eventsHandler))
+	mux.HandleFunc(API_PREFIX+"/config", get(configHandler))
+	mux.HandleFunc(API_PREFIX+"/instances", get(instancesHandler))
+	mux.HandleFunc(API_PREFIX+"/instances/", instanceHandler)
+	return mux
+}
+
+func nodeHandler(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, NodeInfo{
+		Node:         *model.GetNodeInfo(),
+		Registration: model.GetRegistrationState(),
+		Uptime:       time.Since(started),
+	})
+}
+
+func eventsHandler(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, model.GetEvents())
+}
+
+func configHandler(w http.ResponseWriter, _ *http.Request) {
+	body, err := config.Marshal(config.Get())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/yaml")
+	_, _ = w.Write(body)
+}
+
+func instancesHandler(w http.ResponseWriter, _ *http.Request) {
+	deployed := deployment.Instances()
+	list := make([]Instance, 0, len(deployed))
+	for _, service := range deployed {
+		list = append(list, instanceOf(service))
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// instanceHandler serves /v1/instances/<service>/<instance>[/<action>]
+func instanceHandler(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, API_PREFIX+"/instances/"), "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" {
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown path %s", r.URL.Path))
+		return
+	}
+	sname := parts[0]
+	instance, err := strconv.Atoi(parts[1])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid instance number %s", parts[1]))
+		return
+	}
+	action := ""
+	if len(parts) == 3 {
+		action = parts[2]
+	}
+
+	switch action {
+	case "":
+		get(func(w http.ResponseWriter, r *http.Request) {
+			service, deployed := deployment.GetInstance(sname, instance)
+			if !deployed {
+				writeError(w, http.StatusNotFound, deployment.ErrNotDeployed)
+				return
+			}
+			writeJSON(w, http.StatusOK, instanceOf(service))
+		})(w, r)
+	case ACTION_LOGS:
+		get(func(w http.ResponseWriter, r *http.Request) {
+			tailLogs(w, r, sname, instance)
+		})(w, r)
+	case ACTION_UNDEPLOY:
+		post(operate(sname, instance, action, deployment.Undeploy))(w, r)
+	case ACTION_PAUSE:
+		post(operate(sname, instance, action, deployment.Pause))(w, r)
+	case ACTION_RESUME:
+		post(operate(sname, instance, action, deployment.Resume))(w, r)
+	default:
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown action %s", action))
+	}
+}
+
+// operate performs the action requested by an operator, recording it among the events
+func operate(sname string, instance int, action string, operation func(string, int) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		serviceLog := log.Instance(sname, instance)
+		serviceLog.Info("Operator request", "action", action)
+		model.RecordEvent(model.Event{Type: model.EVENT_OPERATOR, Sname: sname, Instance: instance, Message: action + " requested"})
+		err := operation(sname, instance)
+		switch {
+		case errors.Is(err, deployment.ErrNotDeployed):
+			writeError(w, http.StatusNotFound, err)
+		case errors.Is(err, deployment.ErrPauseUnsupported):
+			writeError(w, http.StatusNotImplemented, err)
+		case err != nil:
+			serviceLog.Error("Operator request failed", "action", action, logger.ERROR, err)
+			writeError(w, http.StatusInternalServerError, err)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}
+}
+
+func instanceOf(service model.Service) Instance {
+	instance := Instance{
+		Sname:    service.Sname,
+		Instance: service.Instance,
+		Runtime:  service.Runtime,
+		Status:   service.Status,
+		Reason:   service.StatusReason,
+		Detail:   service.StatusDetail,
+		Spec:     service,
+	}
+	if resources, found := model.GetInstanceUsage(service.Sname, service.Instance); found {
+		instance.Resources = &resources
+	}
+	return instance
+}
+
+func get(handler http.HandlerFunc) http.HandlerFunc {
+	return method(http.MethodGet, handler)
+}
+
+func post(handler http.HandlerFunc) http.HandlerFunc {
+	return method(http.MethodPost, handler)
+}
+
+func method(allowed string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != allowed {
+			w.Header().Set("Allow", allowed)
+			writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+			return
+		}
+		handler(w, r)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Error("Unable to write the response", logger.ERROR, err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, Error{Error: err.Error()})
+}
@@ -0,0 +1,149 @@
+package admin
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"go_node_engine/model"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+)
+
+func serve(t *testing.T) *http.Client {
+	socket := serveSocket(t)
+	return &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		},
+	}}
+}
+
+func serveSocket(t *testing.T) string {
+	socket := filepath.Join(t.TempDir(), "admin", "node-engine.sock")
+	server, err := Serve(socket)
+	assert.NilError(t, err)
+	t.Cleanup(func() {
+		_ = server.Close()
+	})
+	stat, err := os.Stat(socket)
+	assert.NilError(t, err)
+	assert.Equal(t, stat.Mode().Perm(), os.FileMode(SOCKET_MODE))
+	return socket
+}
+
+func TestNodeAndInstances(t *testing.T) {
+	client := serve(t)
+
+	response, err := client.Get("http://admin" + API_PREFIX + "/node")
+	assert.NilError(t, err)
+	var node NodeInfo
+	assert.NilError(t, json.NewDecoder(response.Body).Decode(&node))
+	_ = response.Body.Close()
+	assert.Equal(t, node.Registration, model.GetRegistrationState())
+
+	response, err = client.Get("http://admin" + API_PREFIX + "/instances")
+	assert.NilError(t, err)
+	body, _ := io.ReadAll(response.Body)
+	_ = response.Body.Close()
+	assert.Equal(t, strings.TrimSpace(string(body)), "[]")
+
+	response, err = client.Get("http://admin" + API_PREFIX + "/instances/app/x")
+	assert.NilError(t, err)
+	_ = response.Body.Close()
+	assert.Equal(t, response.StatusCode, http.StatusBadRequest)
+
+	response, err = client.Get("http://admin" + API_PREFIX + "/config")
+	assert.NilError(t, err)
+	body, _ = io.ReadAll(response.Body)
+	_ = response.Body.Close()
+	assert.Assert(t, strings.Contains(string(body), "socket: /run/oakestra/node-engine.sock"))
+}
+
+func TestOperatorRequests(t *testing.T) {
+	client := serve(t)
+
+	response, err := client.Get("http://admin" + API_PREFIX + "/instances/app/0/pause")
+	assert.NilError(t, err)
+	_ = response.Body.Close()
+	assert.Equal(t, response.StatusCode, http.StatusMethodNotAllowed)
+
+	response, err = client.Post("http://admin"+API_PREFIX+"/instances/app/0/undeploy", "", nil)
+	assert.NilError(t, err)
+	var failure Error
+	assert.NilError(t, json.NewDecoder(response.Body).Decode(&failure))
+	_ = response.Body.Close()
+	assert.Equal(t, response.StatusCode, http.StatusNotFound)
+	assert.Equal(t, failure.Error, "instance not deployed")
+
+	response, err = client.Get("http://admin" + API_PREFIX + "/events")
+	assert.NilError(t, err)
+	var events []model.Event
+	assert.NilError(t, json.NewDecoder(response.Body).Decode(&events))
+	_ = response.Body.Close()
+	last := events[len(events)-1]
+	assert.Equal(t, last.Type, model.EVENT_OPERATOR)
+	assert.Equal(t, last.Sname, "app")
+	assert.Equal(t, last.Message, "undeploy requested")
+}
+
+func TestLogs(t *testing.T) {
+	client := serve(t)
+	directory := t.TempDir()
+	model.SetLogDirectory(directory)
+	logFile := filepath.Join(directory, "app.instance.0")
+	assert.NilError(t, os.WriteFile(logFile, []byte("one\ntwo\nthree\n"), 0644))
+
+	response, err := client.Get("http://admin" + API_PREFIX + "/instances/app/0/logs?tail=2")
+	assert.NilError(t, err)
+	body, _ := io.ReadAll(response.Body)
+	_ = response.Body.Close()
+	assert.Equal(t, string(body), "two\nthree\n")
+
+	response, err = client.Get("http://admin" + API_PREFIX + "/instances/app/1/logs")
+	assert.NilError(t, err)
+	_ = response.Body.Close()
+	assert.Equal(t, response.StatusCode, http.StatusNotFound)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	request, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://admin"+API_PREFIX+"/instances/app/0/logs?tail=1&follow=true", nil)
+	response, err = client.Do(request)
+	assert.NilError(t, err)
+	defer response.Body.Close()
+	reader := bufio.NewReader(response.Body)
+	line, err := reader.ReadString('\n')
+	assert.NilError(t, err)
+	assert.Equal(t, line, "three\n")
+
+	file, err := os.OpenFile(logFile, os.O_APPEND|os.O_WRONLY, 0644)
+	assert.NilError(t, err)
+	_, err = file.WriteString("four\n")
+	assert.NilError(t, err)
+	assert.NilError(t, file.Close())
+	line, err = reader.ReadString('\n')
+	assert.NilError(t, err)
+	assert.Equal(t, line, "four\n")
+}
+
+func TestLastLines(t *testing.T) {
+	file, err := os.CreateTemp(t.TempDir(), "logs")
+	assert.NilError(t, err)
+	defer file.Close()
+	_, err = file.WriteString("a\nb\nc")
+	assert.NilError(t, err)
+
+	for lines, expected := range map[int]string{0: "", 1: "c", 2: "b\nc", 5: "a\nb\nc"} {
+		offset, tail, err := lastLines(file, lines)
+		assert.NilError(t, err)
+		assert.Equal(t, offset, int64(5))
+		assert.Equal(t, string(tail), expected)
+	}
+}
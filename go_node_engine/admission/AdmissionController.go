@@ -0,0 +1,144 @@
+package admission
+
+import (
+	"fmt"
+	"go_node_engine/model"
+	"sync"
+)
+
+// Rejection reason codes, reported to the cluster together with the FAILED status
+const (
+	REASON_RUNTIME_UNAVAILABLE   = "RUNTIME_UNAVAILABLE"
+	REASON_ARCHITECTURE_MISMATCH = "ARCHITECTURE_MISMATCH"
+	REASON_IMAGE_POLICY          = "IMAGE_POLICY"
+	REASON_INSUFFICIENT_CPU      = "INSUFFICIENT_CPU"
+	REASON_INSUFFICIENT_MEMORY   = "INSUFFICIENT_MEMORY"
+	REASON_INSUFFICIENT_DISK     = "INSUFFICIENT_DISK"
+	REASON_INSUFFICIENT_GPU      = "INSUFFICIENT_GPU"
+	REASON_INSUFFICIENT_TPU      = "INSUFFICIENT_TPU"
+	REASON_PORT_CONFLICT         = "PORT_CONFLICT"
+)
+
+// Rejection describes why a deployment has not been admitted on this node
+type Rejection struct {
+	Reason string
+	Detail string
+}
+
+func (r *Rejection) Error() string {
+	return fmt.Sprintf("%s: %s", r.Reason, r.Detail)
+}
+
+type check func(c *Controller, service model.Service) *Rejection
+
+// Controller runs the admission checks and reserves the resources of the admitted services
+type Controller struct {
+	node   func() *model.Node
+	ledger *model.Ledger
+	policy ImagePolicy
+	ports  map[string]string
+	checks []check
+	lock   sync.Mutex
+	// replaced is the instance being replaced during Replace, the replacement may reuse its accelerators
+	replaced model.Service
+}
+
+var controller *Controller
+var controllerOnce sync.Once
+
+// GetController returns the node admission controller
+func GetController() *Controller {
+	controllerOnce.Do(func() {
+		controller = NewController(model.GetCachedNodeInfo, model.GetLedger())
+	})
+	return controller
+}
+
+// NewController creates an admission controller checking the deployments against the node returned by node, and the ledger
+func NewController(node func() *model.Node, ledger *model.Ledger) *Controller {
+	return &Controller{
+		node:   node,
+		ledger: ledger,
+		ports:  make(map[string]string),
+		checks: []check{
+			checkRuntime,
+			checkArchitecture,
+			checkImagePolicy,
+			checkResources,
+			checkPorts,
+		},
+	}
+}
+
+// SetImagePolicy sets the registries and images allowed on this node
+func (c *Controller) SetImagePolicy(policy ImagePolicy) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.policy = policy
+}
+
+// Admit runs all the checks against the service, in order, stopping at the first rejection.
+// On success the service resources and host ports are reserved until Release is called.
+// reserved is false when the instance already holds a reservation, in that case no check is performed
+// and the runtime is in charge of rejecting the duplicate.
+func (c *Controller) Admit(service model.Service) (reserved bool, rejection *Rejection) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.ledger.Reserved(service.Sname, service.Instance) {
+		return false, nil
+	}
+	for _, check := range c.checks {
+		if rejection := check(c, service); rejection != nil {
+			return false, rejection
+		}
+	}
+
+	c.reserve(service)
+	return true, nil
+}
+
+// Replace swaps the reservation of the current instance for the one of its replacement, in a single step.
+// The replacement is checked as if the current instance was gone, its accelerators count as free since the
+// runtime reuses them. If the replacement is rejected, the current reservation is kept.
+func (c *Controller) Replace(current model.Service, replacement model.Service) *Rejection {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.release(current.Sname, current.Instance)
+	c.replaced = current
+	defer func() { c.replaced = model.Service{} }()
+	for _, check := range c.checks {
+		if rejection := check(c, replacement); rejection != nil {
+			c.reserve(current)
+			return rejection
+		}
+	}
+	c.reserve(replacement)
+	return nil
+}
+
+// Release frees the resources and host ports reserved by the given instance
+func (c *Controller) Release(sname string, instance int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.release(sname, instance)
+}
+
+func (c *Controller) reserve(service model.Service) {
+	c.ledger.Reserve(service)
+	instance := model.InstanceKey(service.Sname, service.Instance)
+	for _, port := range hostPorts(service.Ports) {
+		c.ports[port] = instance
+	}
+}
+
+func (c *Controller) release(sname string, instance int) {
+	c.ledger.Release(sname, instance)
+	key := model.InstanceKey(sname, instance)
+	for port, owner := range c.ports {
+		if owner == key {
+			delete(c.ports, port)
+		}
+	}
+}
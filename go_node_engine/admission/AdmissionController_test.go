@@ -0,0 +1,121 @@
+package admission
+
+import (
+	"go_node_engine/model"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func testController() *Controller {
+	node := &model.Node{CpuArch: "amd64", Technology: []model.RuntimeType{model.CONTAINER_RUNTIME}}
+	return NewController(func() *model.Node { return node }, model.NewLedger(model.ResourceAmount{Cpu: 2, Memory: 1024, Disk: 1000}))
+}
+
+func testService(instance int) model.Service {
+	return model.Service{
+		Sname:    "app.app.nginx.test",
+		Instance: instance,
+		Image:    "docker.io/library/nginx:latest",
+		Runtime:  string(model.CONTAINER_RUNTIME),
+		Vcpus:    1,
+		Memory:   256,
+	}
+}
+
+func TestAdmitReservesResources(t *testing.T) {
+	c := testController()
+	reserved, rejection := c.Admit(testService(0))
+	assert.Assert(t, rejection == nil)
+	assert.Assert(t, reserved)
+	assert.Equal(t, c.ledger.Allocatable().Memory, 768)
+
+	c.Release("app.app.nginx.test", 0)
+	assert.Equal(t, c.ledger.Allocatable().Memory, 1024)
+}
+
+func TestAdmitDuplicateSkipsChecks(t *testing.T) {
+	c := testController()
+	_, _ = c.Admit(testService(0))
+	reserved, rejection := c.Admit(testService(0))
+	assert.Assert(t, rejection == nil)
+	assert.Assert(t, !reserved)
+}
+
+func TestAdmitRejections(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(s *model.Service, c *Controller)
+		reason string
+	}{
+		{"runtime", func(s *model.Service, _ *Controller) { s.Runtime = string(model.UNIKERNEL_RUNTIME) }, REASON_RUNTIME_UNAVAILABLE},
+		{"architecture", func(s *model.Service, _ *Controller) { s.Architectures = []string{"arm64"} }, REASON_ARCHITECTURE_MISMATCH},
+		{"denied image", func(s *model.Service, c *Controller) {
+			c.SetImagePolicy(ImagePolicy{Deny: []string{"docker.io/library/"}})
+		}, REASON_IMAGE_POLICY},
+		{"image not allowed", func(s *model.Service, c *Controller) {
+			c.SetImagePolicy(ImagePolicy{Allow: []string{"ghcr.io/oakestra/"}})
+		}, REASON_IMAGE_POLICY},
+		{"cpu", func(s *model.Service, _ *Controller) { s.Vcpus = 3 }, REASON_INSUFFICIENT_CPU},
+		{"memory", func(s *model.Service, _ *Controller) { s.Memory = 2048 }, REASON_INSUFFICIENT_MEMORY},
+		{"disk", func(s *model.Service, _ *Controller) { s.Storage = 2000 }, REASON_INSUFFICIENT_DISK},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := testController()
+			service := testService(0)
+			test.mutate(&service, c)
+			reserved, rejection := c.Admit(service)
+			assert.Assert(t, !reserved)
+			assert.Assert(t, rejection != nil)
+			assert.Equal(t, rejection.Reason, test.reason)
+			assert.DeepEqual(t, c.ledger.Allocated(), model.ResourceAmount{})
+		})
+	}
+}
+
+func TestAdmitPortConflict(t *testing.T) {
+	c := testController()
+	first := testService(0)
+	first.Ports = "8080:80;9000:9000/udp"
+	_, rejection := c.Admit(first)
+	assert.Assert(t, rejection == nil)
+
+	second := testService(1)
+	second.Ports = "9000:90/udp"
+	_, rejection = c.Admit(second)
+	assert.Assert(t, rejection != nil)
+	assert.Equal(t, rejection.Reason, REASON_PORT_CONFLICT)
+
+	second.Ports = "9000:90"
+	_, rejection = c.Admit(second)
+	assert.Assert(t, rejection == nil)
+}
+
+func TestReplace(t *testing.T) {
+	c := testController()
+	current := testService(0)
+	current.Ports = "8080:80"
+	_, _ = c.Admit(current)
+
+	// the replacement may use what the current instance holds
+	replacement := current
+	replacement.Vcpus = 2
+	assert.Assert(t, c.Replace(current, replacement) == nil)
+	assert.Equal(t, c.ledger.Allocated().Cpu, 2.0)
+	assert.Equal(t, c.ports["8080/tcp"], model.InstanceKey(current.Sname, 0))
+
+	// a rejected replacement keeps the current reservation
+	rejected := replacement
+	rejected.Memory = 2048
+	rejection := c.Replace(replacement, rejected)
+	assert.Assert(t, rejection != nil)
+	assert.Equal(t, rejection.Reason, REASON_INSUFFICIENT_MEMORY)
+	assert.Equal(t, c.ledger.Allocated().Cpu, 2.0)
+	assert.Equal(t, c.ports["8080/tcp"], model.InstanceKey(current.Sname, 0))
+}
+
+func TestHostPorts(t *testing.T) {
+	assert.DeepEqual(t, hostPorts("8080:80/UDP; 9000,7000:70/tcp"), []string{"8080/udp", "9000/tcp", "7000/tcp"})
+	assert.DeepEqual(t, hostPorts(""), []string{})
+}
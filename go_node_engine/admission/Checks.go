@@ -0,0 +1,139 @@
+package admission
+
+import (
+	"fmt"
+	"go_node_engine/model"
+	"go_node_engine/model/gpu"
+	"strings"
+)
+
+// ImagePolicy restricts the images that can be deployed on the node.
+// Entries are image reference prefixes, e.g. "docker.io/library/" or "ghcr.io/oakestra/".
+// Deny has precedence over Allow, an empty Allow list allows every image not denied.
+type ImagePolicy struct {
+	Allow []string
+	Deny  []string
+}
+
+func checkRuntime(c *Controller, service model.Service) *Rejection {
+	supported := c.node().GetSupportedTechnologyList()
+	for _, tech := range supported {
+		if string(tech) == service.Runtime {
+			return nil
+		}
+	}
+	return &Rejection{
+		Reason: REASON_RUNTIME_UNAVAILABLE,
+		Detail: fmt.Sprintf("runtime %s not available, node supports %v", service.Runtime, supported),
+	}
+}
+
+func checkArchitecture(c *Controller, service model.Service) *Rejection {
+	if len(service.Architectures) == 0 {
+		return nil
+	}
+	cpuArch := c.node().CpuArch
+	for _, arch := range service.Architectures {
+		if arch == cpuArch {
+			return nil
+		}
+	}
+	return &Rejection{
+		Reason: REASON_ARCHITECTURE_MISMATCH,
+		Detail: fmt.Sprintf("node architecture %s not in %v", cpuArch, service.Architectures),
+	}
+}
+
+func checkImagePolicy(c *Controller, service model.Service) *Rejection {
+	for _, denied := range c.policy.Deny {
+		if strings.HasPrefix(service.Image, denied) {
+			return &Rejection{
+				Reason: REASON_IMAGE_POLICY,
+				Detail: fmt.Sprintf("image %s denied by rule %s", service.Image, denied),
+			}
+		}
+	}
+	if len(c.policy.Allow) == 0 {
+		return nil
+	}
+	for _, allowed := range c.policy.Allow {
+		if strings.HasPrefix(service.Image, allowed) {
+			return nil
+		}
+	}
+	return &Rejection{
+		Reason: REASON_IMAGE_POLICY,
+		Detail: fmt.Sprintf("image %s not in the allowed list", service.Image),
+	}
+}
+
+func checkResources(c *Controller, service model.Service) *Rejection {
+	requested := model.RequestedResources(service)
+	allocatable := c.ledger.Allocatable()
+	if requested.Cpu > allocatable.Cpu {
+		return &Rejection{
+			Reason: REASON_INSUFFICIENT_CPU,
+			Detail: fmt.Sprintf("requested %.2f cores, %.2f allocatable", requested.Cpu, allocatable.Cpu),
+		}
+	}
+	if requested.Memory > allocatable.Memory {
+		return &Rejection{
+			Reason: REASON_INSUFFICIENT_MEMORY,
+			Detail: fmt.Sprintf("requested %d MB, %d MB allocatable", requested.Memory, allocatable.Memory),
+		}
+	}
+	if requested.Disk > allocatable.Disk {
+		return &Rejection{
+			Reason: REASON_INSUFFICIENT_DISK,
+			Detail: fmt.Sprintf("requested %d MB, %d MB allocatable", requested.Disk, allocatable.Disk),
+		}
+	}
+	if service.Vgpus > 0 {
+		if free := gpu.GetAllocator(gpu.GPU).Free() + c.replaced.Vgpus; service.Vgpus > free {
+			return &Rejection{
+				Reason: REASON_INSUFFICIENT_GPU,
+				Detail: fmt.Sprintf("requested %d GPUs, %d free", service.Vgpus, free),
+			}
+		}
+	}
+	if service.Vtpus > 0 {
+		if free := gpu.GetAllocator(gpu.TPU).Free() + c.replaced.Vtpus; service.Vtpus > free {
+			return &Rejection{
+				Reason: REASON_INSUFFICIENT_TPU,
+				Detail: fmt.Sprintf("requested %d TPUs, %d free", service.Vtpus, free),
+			}
+		}
+	}
+	return nil
+}
+
+func checkPorts(c *Controller, service model.Service) *Rejection {
+	for _, port := range hostPorts(service.Ports) {
+		if owner, used := c.ports[port]; used {
+			return &Rejection{
+				Reason: REASON_PORT_CONFLICT,
+				Detail: fmt.Sprintf("host port %s already used by %s", port, owner),
+			}
+		}
+	}
+	return nil
+}
+
+// hostPorts extracts the host side of the port mappings, e.g. "8080:80/udp;9000" becomes ["8080/udp", "9000/tcp"]
+func hostPorts(mappings string) []string {
+	ports := make([]string, 0)
+	for _, mapping := range strings.FieldsFunc(mappings, func(r rune) bool { return r == ';' || r == ',' }) {
+		mapping = strings.TrimSpace(mapping)
+		if mapping == "" {
+			continue
+		}
+		protocol := "tcp"
+		if index := strings.Index(mapping, "/"); index >= 0 {
+			protocol = strings.ToLower(mapping[index+1:])
+			mapping = mapping[:index]
+		}
+		host := strings.Split(mapping, ":")[0]
+		ports = append(ports, fmt.Sprintf("%s/%s", host, protocol))
+	}
+	return ports
+}
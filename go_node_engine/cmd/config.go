@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"fmt"
+	"go_node_engine/config"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	configCmd.AddCommand(configPrintCmd)
+	rootCmd.AddCommand(configCmd)
+}
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect the NodeEngine configuration",
+}
+
+var configPrintCmd = &cobra.Command{
+	Use:   "print",
+	Short: "Print the effective configuration, after file and environment overrides",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig(cmd)
+		if err != nil {
+			return err
+		}
+		out, err := config.Marshal(cfg)
+		if err != nil {
+			return err
+		}
+		fmt.Print(string(out))
+		return nil
+	},
+}
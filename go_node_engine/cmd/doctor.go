@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"fmt"
+	"go_node_engine/doctor"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	doctorCmd.Flags().StringVarP(&outputFormat, "output", "o", OUTPUT_TABLE, "Output format, table or json")
+	startupFlags(doctorCmd.Flags())
+	rootCmd.AddCommand(doctorCmd)
+}
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check that the node is ready to run the NodeEngine",
+	Long: `Check the configuration, the runtimes and the connectivity the NodeEngine needs, with the same
+configuration file, NODE_ENGINE_* variables and flags used to start it.
+
+Exit codes: 0 the node is ready, 1 a check failed, 2 a check warned.`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// the configuration check reports the validation errors along with the other checks
+		cfg, err := readConfig(cmd)
+		if err != nil {
+			return err
+		}
+		results := doctor.Run(cfg, doctor.Checks())
+		err = printOutput(cmd.OutOrStdout(), results, func(w *tabwriter.Writer) {
+			fmt.Fprintln(w, "STATUS\tCHECK\tDETAIL")
+			for _, result := range results {
+				fmt.Fprintf(w, "%s\t%s\t%s\n", strings.ToUpper(string(result.Status)), result.Check, result.Detail)
+				if result.Hint != "" {
+					fmt.Fprintf(w, "\t\t↳ %s\n", result.Hint)
+				}
+			}
+		})
+		if err != nil {
+			return err
+		}
+		if code := doctor.ExitCode(results); code != doctor.EXIT_READY {
+			os.Exit(code)
+		}
+		return nil
+	},
+}
@@ -0,0 +1,189 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"go_node_engine/config"
+	"go_node_engine/deployment"
+	"go_node_engine/e2e"
+	"go_node_engine/jobs"
+	"go_node_engine/model"
+	"go_node_engine/mqtt"
+	"go_node_engine/schema"
+	"go_node_engine/virtualization"
+	"net/http"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+)
+
+const E2E_TIMEOUT = 10 * time.Second
+
+// TestEndToEnd registers the node to the cluster stub, then deploys, monitors and undeploys instances
+// on the fake runtime through the commands published on the embedded broker
+func TestEndToEnd(t *testing.T) {
+	broker, err := e2e.NewBroker()
+	assert.NilError(t, err)
+	defer broker.Close()
+	broker.RequireCredentials("node-e2e", "issued-secret")
+	cluster := e2e.NewCluster("node-e2e", broker.Port())
+	defer cluster.Close()
+	cluster.SetCredentials("node-e2e", "issued-secret")
+	netManager := e2e.NewNetManager()
+	defer netManager.Close()
+	fake := e2e.NewFakeRuntime()
+	virtualization.RegisterRuntime(model.CONTAINER_RUNTIME, fake)
+
+	cfg := config.Default()
+	cfg.Cluster.Address = cluster.Address()
+	cfg.Cluster.Port = cluster.Port()
+	cfg.Cluster.RetryInitial = 10 * time.Millisecond
+	cfg.Cluster.RetryTimeout = E2E_TIMEOUT
+	cfg.Mqtt.Version = "5"
+	cfg.Node.StateDirectory = t.TempDir()
+	cfg.Node.LogDirectory = t.TempDir()
+	cfg.Network.NetManagerHost = netManager.Host()
+	cfg.Network.NetManagerPort = netManager.Port()
+	cfg.Monitoring.Cycle = 100 * time.Millisecond
+	assert.NilError(t, cfg.Validate())
+	config.Set(cfg)
+
+	presence := broker.Subscribe("nodes/node-e2e/presence")
+	acks := broker.Subscribe("nodes/node-e2e/ack")
+	statuses := broker.Subscribe("nodes/node-e2e/job")
+	resources := broker.Subscribe("nodes/node-e2e/jobs/resources")
+
+	// handshake, NetManager registration and broker connection with the issued credentials
+	assert.NilError(t, register(cfg))
+	awaitMessage(t, presence, func(message e2e.Message) bool {
+		return string(message.Payload) == mqtt.PRESENCE_ONLINE
+	})
+	assert.Equal(t, len(cluster.Handshakes()), 1)
+	assert.Equal(t, model.GetRegistrationState(), model.REGISTRATION_REGISTERED)
+	assert.DeepEqual(t, netManager.Paths(), []string{"register"})
+	jobs.StartServicesMonitoring(config.MonitoringCycle, mqtt.ReportServiceResources)
+
+	// deploy
+	web := model.Service{Sname: "app.web", Image: "docker.io/library/nginx:latest", Runtime: string(model.CONTAINER_RUNTIME), Ports: "8080:80"}
+	sendCommand(t, broker, mqtt.COMMAND_DEPLOY, "deploy-web", web)
+	awaitAck(t, acks, "deploy-web", true)
+	awaitStatus(t, statuses, "app.web", model.SERVICE_CREATED)
+	assert.Equal(t, len(fake.Instances()), 1)
+	assert.DeepEqual(t, netManager.Paths(), []string{"register", "container/deploy"})
+
+	// resources
+	assert.NilError(t, fake.SetUsage("app.web", 0, 12.5, 1<<20))
+	awaitMessage(t, resources, func(message e2e.Message) bool {
+		// the cluster announced the latest schema version with its commands, the numbers are not strings
+		assert.Equal(t, message.SchemaVersion, schema.VERSION)
+		report := schema.ServiceResources{}
+		assert.NilError(t, json.Unmarshal(message.Payload, &report))
+		return len(report.Services) == 1 && report.Services[0].Cpu == 12.5
+	})
+	usage, found := model.GetInstanceUsage("app.web", 0)
+	assert.Assert(t, found)
+	assert.Equal(t, usage.Memory, float64(1<<20))
+
+	// failed deployment and unexpected exit
+	fake.FailDeploy("app.broken", errors.New("image pull failed"))
+	broken := model.Service{Sname: "app.broken", Image: "docker.io/library/missing:latest", Runtime: string(model.CONTAINER_RUNTIME)}
+	sendCommand(t, broker, mqtt.COMMAND_DEPLOY, "deploy-broken", broken)
+	awaitAck(t, acks, "deploy-broken", true)
+	status := awaitStatus(t, statuses, "app.broken", model.SERVICE_FAILED)
+	assert.Equal(t, status.Detail, "image pull failed")
+
+	worker := model.Service{Sname: "app.worker", Image: "docker.io/library/busybox:latest", Runtime: string(model.CONTAINER_RUNTIME)}
+	sendCommand(t, broker, mqtt.COMMAND_DEPLOY, "deploy-worker", worker)
+	awaitStatus(t, statuses, "app.worker", model.SERVICE_CREATED)
+	assert.NilError(t, fake.Exit("app.worker", 0, model.SERVICE_DEAD, "exit code 137"))
+	status = awaitStatus(t, statuses, "app.worker", model.SERVICE_DEAD)
+	assert.Equal(t, status.Detail, "exit code 137")
+
+	// undeploy
+	sendCommand(t, broker, mqtt.COMMAND_DELETE, "delete-web", web)
+	awaitAck(t, acks, "delete-web", true)
+	awaitStatus(t, statuses, "app.web", model.SERVICE_UNDEPLOYED)
+	assert.Equal(t, len(fake.Instances()), 0)
+	assert.Equal(t, len(deployment.Instances()), 0)
+	assert.DeepEqual(t, netManager.Paths(), []string{"register", "container/deploy", "container/deploy", "container/undeploy"})
+
+	// a lost connection flags the node offline through its will, until it reconnects
+	broker.DropClients()
+	awaitMessage(t, presence, func(message e2e.Message) bool {
+		return string(message.Payload) == mqtt.PRESENCE_OFFLINE
+	})
+	awaitMessage(t, presence, func(message e2e.Message) bool {
+		return string(message.Payload) == mqtt.PRESENCE_ONLINE
+	})
+
+	// a failed re-registration keeps the node registering and retries on the next backoff cycle
+	retrying := cfg
+	retrying.Cluster.RetryTimeout = 50 * time.Millisecond
+	retrying.Cluster.RetryMax = 20 * time.Millisecond
+	config.Set(retrying)
+	mqtt.SetReRegistrationHandler(reRegister)
+	cluster.FailHandshakes(http.StatusServiceUnavailable)
+	handshakes := len(cluster.Handshakes())
+	mqtt.ReRegister("e2e")
+	awaitMessage(t, presence, func(message e2e.Message) bool {
+		return string(message.Payload) == mqtt.PRESENCE_OFFLINE
+	})
+	deadline := time.Now().Add(E2E_TIMEOUT)
+	for len(cluster.Handshakes()) < handshakes+5 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Assert(t, len(cluster.Handshakes()) >= handshakes+5)
+	assert.Equal(t, model.GetRegistrationState(), model.REGISTRATION_REGISTERING)
+	cluster.FailHandshakes(0)
+	awaitMessage(t, presence, func(message e2e.Message) bool {
+		return string(message.Payload) == mqtt.PRESENCE_ONLINE
+	})
+	assert.Equal(t, model.GetRegistrationState(), model.REGISTRATION_REGISTERED)
+
+	mqtt.Shutdown()
+	awaitMessage(t, presence, func(message e2e.Message) bool {
+		return string(message.Payload) == mqtt.PRESENCE_OFFLINE
+	})
+}
+
+func sendCommand(t *testing.T, broker *e2e.Broker, command string, requestID string, service model.Service) {
+	payload, contentType, err := schema.Marshal(schema.ServiceCommand{RequestID: requestID, Service: service}, schema.ENCODING_JSON)
+	assert.NilError(t, err)
+	broker.PublishVersion("nodes/node-e2e/control/"+command, payload, contentType, schema.VERSION)
+}
+
+func awaitAck(t *testing.T, acks <-chan e2e.Message, requestID string, accepted bool) schema.Ack {
+	ack := schema.Ack{}
+	awaitMessage(t, acks, func(message e2e.Message) bool {
+		assert.NilError(t, json.Unmarshal(message.Payload, &ack))
+		return ack.RequestID == requestID
+	})
+	assert.Equal(t, ack.Accepted, accepted, "ack %+v", ack)
+	return ack
+}
+
+func awaitStatus(t *testing.T, statuses <-chan e2e.Message, sname string, expected string) schema.ServiceStatus {
+	status := schema.ServiceStatus{}
+	awaitMessage(t, statuses, func(message e2e.Message) bool {
+		assert.NilError(t, json.Unmarshal(message.Payload, &status))
+		return status.Sname == sname && status.Status == expected
+	})
+	return status
+}
+
+// awaitMessage waits for a message matching, skipping the other ones
+func awaitMessage(t *testing.T, messages <-chan e2e.Message, matches func(message e2e.Message) bool) e2e.Message {
+	t.Helper()
+	timeout := time.After(E2E_TIMEOUT)
+	for {
+		select {
+		case message := <-messages:
+			if matches(message) {
+				return message
+			}
+		case <-timeout:
+			t.Fatal("no matching message received")
+		}
+	}
+}
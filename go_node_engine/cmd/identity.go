@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"go_node_engine/config"
+	"go_node_engine/logger"
+	"go_node_engine/model"
+	"go_node_engine/requests"
+	"time"
+)
+
+// loadIdentity returns the identity persisted by a previous run for the configured cluster, setting it as the node ID.
+// An identity issued by a different cluster is not presented.
+func loadIdentity(cfg config.Config) model.Identity {
+	identity, err := model.LoadIdentity(cfg.Node.StateDirectory)
+	if err != nil {
+		log.Error("Unable to load the node identity, registering as a new node", logger.ERROR, err)
+		return model.Identity{}
+	}
+	if identity.NodeId == "" {
+		return identity
+	}
+	if !identity.BelongsTo(cfg.Cluster.Address, cfg.Cluster.Port) {
+		log.Info("Node identity issued by another cluster, registering as a new node", "cluster_address", identity.ClusterAddress, "cluster_port", identity.ClusterPort)
+		return model.Identity{}
+	}
+	log.Info("Presenting the node ID", logger.NODE_ID, identity.NodeId, "registered_at", identity.RegisteredAt.Format(time.RFC3339))
+	model.SetNodeId(identity.NodeId)
+	return identity
+}
+
+// saveIdentity persists the identity received at handshake. Credentials previously issued are kept if the cluster
+// confirmed the node ID without handing out new ones.
+func saveIdentity(cfg config.Config, previous model.Identity, answer requests.HandshakeAnswer) model.Identity {
+	identity := model.Identity{
+		NodeId:         answer.NodeId,
+		ClusterAddress: cfg.Cluster.Address,
+		ClusterPort:    cfg.Cluster.Port,
+		MqttUsername:   answer.MqttUsername,
+		MqttPassword:   answer.MqttPassword,
+		RegisteredAt:   time.Now(),
+	}
+	if previous.NodeId != "" && previous.NodeId != answer.NodeId {
+		log.Info("Cluster did not recognize the node ID", "previous_node_id", previous.NodeId, logger.NODE_ID, answer.NodeId)
+	}
+	if previous.NodeId == answer.NodeId {
+		identity.RegisteredAt = previous.RegisteredAt
+		if answer.MqttUsername == "" && answer.MqttPassword == "" {
+			identity.MqttUsername = previous.MqttUsername
+			identity.MqttPassword = previous.MqttPassword
+		}
+	}
+	if err := model.SaveIdentity(cfg.Node.StateDirectory, identity); err != nil {
+		log.Error("Unable to persist the node identity, the node will register as new after a restart", logger.ERROR, err)
+	}
+	return identity
+}
@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(operatorCommand(inspectCmd, false))
+}
+
+var inspectCmd = &cobra.Command{
+	Use:   "inspect <service> <instance> | inspect <service>.instance.<instance>",
+	Short: "Print the spec, status and resource usage of an instance as JSON",
+	Args:  cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sname, instance, err := instanceArgs(args)
+		if err != nil {
+			return err
+		}
+		client, err := adminClient(cmd)
+		if err != nil {
+			return err
+		}
+		deployed, err := client.Instance(context.Background(), sname, instance)
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(cmd.OutOrStdout())
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(deployed)
+	},
+}
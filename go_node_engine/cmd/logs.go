@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+)
+
+var followLogs bool
+var tailLines int
+
+func init() {
+	logsCmd.Flags().BoolVarP(&followLogs, "follow", "f", false, "Keep printing the new output until interrupted")
+	logsCmd.Flags().IntVarP(&tailLines, "tail", "n", 100, "Number of lines printed from the end of the output")
+	rootCmd.AddCommand(operatorCommand(logsCmd, false))
+}
+
+var logsCmd = &cobra.Command{
+	Use:   "logs <service> <instance> | logs <service>.instance.<instance>",
+	Short: "Print the output of an instance, also once it has stopped",
+	Args:  cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sname, instance, err := instanceArgs(args)
+		if err != nil {
+			return err
+		}
+		client, err := adminClient(cmd)
+		if err != nil {
+			return err
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		return client.Logs(ctx, sname, instance, tailLines, followLogs, cmd.OutOrStdout())
+	},
+}
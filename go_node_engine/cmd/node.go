@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"go_node_engine/model"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	nodeCmd.AddCommand(operatorCommand(nodeInfoCmd, true))
+	nodeCmd.AddCommand(operatorCommand(nodeEventsCmd, true))
+	rootCmd.AddCommand(nodeCmd)
+}
+
+var nodeCmd = &cobra.Command{
+	Use:   "node",
+	Short: "Inspect the node as seen by the running NodeEngine",
+}
+
+var nodeInfoCmd = &cobra.Command{
+	Use:   "info",
+	Short: "Print the node identity, registration state, resources and labels",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := adminClient(cmd)
+		if err != nil {
+			return err
+		}
+		info, err := client.Node(context.Background())
+		if err != nil {
+			return err
+		}
+		node := info.Node
+		return printOutput(cmd.OutOrStdout(), info, func(w *tabwriter.Writer) {
+			runtimes := make([]string, 0, len(node.Technology))
+			for _, runtime := range node.Technology {
+				runtimes = append(runtimes, string(runtime))
+			}
+			rows := [][2]string{
+				{"Node ID", valueOr(node.Id, "-")},
+				{"Registration", string(info.Registration)},
+				{"Uptime", info.Uptime.Round(time.Second).String()},
+				{"Host", fmt.Sprintf("%s (%s)", node.Host, node.Ip)},
+				{"Architecture", node.CpuArch},
+				{"Runtimes", valueOr(strings.Join(runtimes, ", "), "-")},
+				{"Overlay network", fmt.Sprintf("%t", node.Overlay)},
+				{"CPU", fmt.Sprintf("%d cores, %.1f%% used", node.CpuCores, node.CpuUsage)},
+				{"Memory", fmt.Sprintf("%.1f%% used, %dMiB available", node.MemoryUsed, node.MemoryMB)},
+				{"Capacity", formatAmount(node.Capacity)},
+				{"Allocated", formatAmount(node.Allocated)},
+				{"Allocatable", formatAmount(node.Allocatable)},
+				{"Labels", formatMap(node.Labels)},
+				{"Capabilities", formatMap(node.Capabilities)},
+			}
+			for _, taint := range node.Taints {
+				rows = append(rows, [2]string{"Taint", fmt.Sprintf("%s=%s:%s", taint.Key, taint.Value, taint.Effect)})
+			}
+			for _, row := range rows {
+				fmt.Fprintf(w, "%s:\t%s\n", row[0], row[1])
+			}
+		})
+	},
+}
+
+var nodeEventsCmd = &cobra.Command{
+	Use:   "events",
+	Short: "Print the recent events of the node: status changes, registration, connection and operator requests",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := adminClient(cmd)
+		if err != nil {
+			return err
+		}
+		events, err := client.Events(context.Background())
+		if err != nil {
+			return err
+		}
+		return printOutput(cmd.OutOrStdout(), events, func(w *tabwriter.Writer) {
+			fmt.Fprintln(w, "TIME\tTYPE\tINSTANCE\tMESSAGE")
+			for _, event := range events {
+				instance := "-"
+				if event.Sname != "" {
+					instance = model.InstanceKey(event.Sname, event.Instance)
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", event.Time.Local().Format(time.RFC3339), event.Type, instance, event.Message)
+			}
+		})
+	},
+}
+
+func formatAmount(amount model.ResourceAmount) string {
+	return fmt.Sprintf("cpu %.2f, memory %dMiB, gpu %d, disk %dMiB", amount.Cpu, amount.Memory, amount.Gpu, amount.Disk)
+}
+
+func formatMap(values map[string]string) string {
+	if len(values) == 0 {
+		return "-"
+	}
+	pairs := make([]string, 0, len(values))
+	for key, value := range values {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ", ")
+}
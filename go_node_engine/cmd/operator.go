@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"go_node_engine/admin"
+	"go_node_engine/config"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+// The operator subcommands talk to the NodeEngine running on the node through its admin socket
+
+// Output formats of the operator subcommands
+const (
+	OUTPUT_TABLE = "table"
+	OUTPUT_JSON  = "json"
+)
+
+var adminSocket string
+var outputFormat string
+
+// operatorCommand adds the flags shared by the operator subcommands
+func operatorCommand(command *cobra.Command, formats bool) *cobra.Command {
+	command.Flags().StringVar(&adminSocket, "socket", "", "Admin socket of the running NodeEngine. Default: admin.socket of the configuration")
+	if formats {
+		command.Flags().StringVarP(&outputFormat, "output", "o", OUTPUT_TABLE, "Output format, table or json")
+	}
+	command.SilenceUsage = true
+	return command
+}
+
+// adminClient returns the client of the admin socket given by flag, or else by the configuration
+func adminClient(cmd *cobra.Command) (*admin.Client, error) {
+	socket := adminSocket
+	if !cmd.Flags().Changed("socket") {
+		cfg, err := config.Load(configFile, cmd.Flags().Changed("config"))
+		if err != nil {
+			return nil, err
+		}
+		socket = cfg.Admin.Socket
+	}
+	if socket == "" {
+		return nil, fmt.Errorf("the admin API is disabled, set admin.socket or --socket")
+	}
+	return admin.NewClient(socket), nil
+}
+
+// instanceArgs parses the instance given as <service> <instance>, or as <service>.instance.<instance> as listed by ps
+func instanceArgs(args []string) (string, int, error) {
+	sname, number := "", ""
+	switch len(args) {
+	case 1:
+		separator := strings.LastIndex(args[0], ".instance.")
+		if separator < 0 {
+			return "", 0, fmt.Errorf("expected <service> <instance> or <service>.instance.<instance>, got %s", args[0])
+		}
+		sname, number = args[0][:separator], args[0][separator+len(".instance."):]
+	case 2:
+		sname, number = args[0], args[1]
+	default:
+		return "", 0, fmt.Errorf("expected <service> <instance> or <service>.instance.<instance>")
+	}
+	instance, err := strconv.Atoi(number)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid instance number %s", number)
+	}
+	return sname, instance, nil
+}
+
+// printOutput prints the value as JSON or, with the table format, as the table written by table
+func printOutput(w io.Writer, value interface{}, table func(w *tabwriter.Writer)) error {
+	switch outputFormat {
+	case OUTPUT_JSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(value)
+	case OUTPUT_TABLE:
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		table(tw)
+		return tw.Flush()
+	}
+	return fmt.Errorf("unknown output format %s, expected table or json", outputFormat)
+}
+
+// formatBytes prints a size in bytes with a binary unit, e.g. 12.5MiB
+func formatBytes(size float64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB"}
+	unit := 0
+	for size >= 1024 && unit < len(units)-1 {
+		size /= 1024
+		unit++
+	}
+	if unit == 0 {
+		return fmt.Sprintf("%.0f%s", size, units[unit])
+	}
+	return fmt.Sprintf("%.1f%s", size, units[unit])
+}
+
+func valueOr(value string, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
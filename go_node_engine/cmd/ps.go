@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"go_node_engine/model"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(operatorCommand(psCmd, true))
+}
+
+var psCmd = &cobra.Command{
+	Use:   "ps",
+	Short: "List the instances deployed on the node, with status and resource usage",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := adminClient(cmd)
+		if err != nil {
+			return err
+		}
+		instances, err := client.Instances(context.Background())
+		if err != nil {
+			return err
+		}
+		return printOutput(cmd.OutOrStdout(), instances, func(w *tabwriter.Writer) {
+			fmt.Fprintln(w, "INSTANCE\tRUNTIME\tSTATUS\tREASON\tCPU\tMEMORY\tIMAGE")
+			for _, instance := range instances {
+				cpu, memory := "-", "-"
+				if instance.Resources != nil {
+					cpu = fmt.Sprintf("%.1f%%", instance.Resources.Cpu)
+					memory = formatBytes(instance.Resources.Memory)
+				}
+				image := instance.Spec.Image
+				if len(instance.Spec.UnikernelImages) > 0 {
+					image = instance.Spec.UnikernelImages[0]
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+					model.InstanceKey(instance.Sname, instance.Instance),
+					valueOr(instance.Runtime, "-"),
+					valueOr(instance.Status, "-"),
+					valueOr(instance.Reason, "-"),
+					cpu,
+					memory,
+					valueOr(image, "-"))
+			}
+		})
+	},
+}
@@ -1,11 +1,20 @@
 package cmd
 
 import (
+	"context"
+	"fmt"
+	"go_node_engine/admin"
+	"go_node_engine/admission"
+	"go_node_engine/config"
 	"go_node_engine/jobs"
 	"go_node_engine/logger"
+	"go_node_engine/metrics"
 	"go_node_engine/model"
+	"go_node_engine/model/gpu"
 	"go_node_engine/mqtt"
 	"go_node_engine/requests"
+	"go_node_engine/standalone"
+	"go_node_engine/tracing"
 	"go_node_engine/virtualization"
 	"os"
 	"os/signal"
@@ -13,6 +22,7 @@ import (
 	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 var (
@@ -20,65 +30,224 @@ var (
 		Use:   "NodeEngine",
 		Short: "Start a NoderEngine",
 		Long:  `Start a New Oakestra Worker Node`,
-		RunE: func(_ *cobra.Command, _ []string) error {
-			return startNodeEngine()
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			cfg, err := loadConfig(cmd)
+			if err != nil {
+				return err
+			}
+			config.Set(cfg)
+			return startNodeEngine(cmd)
 		},
 	}
-	clusterAddress   string
-	clusterPort      int
-	overlayNetwork   int
-	unikernelSupport bool
-	logDirectory     string
+	configFile         string
+	clusterAddress     string
+	clusterPort        int
+	overlayNetwork     int
+	unikernelSupport   bool
+	logDirectory       string
+	reservedCpu        float64
+	reservedMemory     int
+	reservedDisk       int
+	allowedImages      []string
+	deniedImages       []string
+	labelsFile         string
+	metricsAddress     string
+	standaloneManifest string
 )
 
-// MONITORING_CYCLE defines the interval at which the system should perform monitoring tasks.
-const MONITORING_CYCLE = time.Second * 2
+var log = logger.Module("cmd")
+
+// CONFIG_WATCH_CYCLE defines the interval at which the configuration file is checked for changes.
+const CONFIG_WATCH_CYCLE = time.Second * 5
+
+// MANIFEST_WATCH_CYCLE defines the interval at which the standalone manifest is checked for changes.
+const MANIFEST_WATCH_CYCLE = time.Second * 5
+
+// TRACING_SHUTDOWN_TIMEOUT bounds the export of the pending spans at termination.
+const TRACING_SHUTDOWN_TIMEOUT = time.Second * 5
 
 // Execute is the entry point of the NodeEngine
 func Execute() error {
 	rootCmd.CompletionOptions.DisableDefaultCmd = true
-	return rootCmd.Execute()
+	command, err := rootCmd.ExecuteC()
+	if err != nil && command != rootCmd {
+		// the subcommands are run by operators in a shell, cobra already printed the error
+		os.Exit(1)
+	}
+	return err
 }
 
 func init() {
-	rootCmd.Flags().StringVarP(&clusterAddress, "clusterAddr", "a", "localhost", "Address of the cluster orchestrator without port")
-	rootCmd.Flags().IntVarP(&clusterPort, "clusterPort", "p", 10100, "Port of the cluster orchestrator")
-	rootCmd.Flags().IntVarP(&overlayNetwork, "netmanagerPort", "n", 6000, "Port of the NetManager component, if any. This enables the overlay network across nodes. Use -1 to disable Overlay Network Mode.")
-	rootCmd.Flags().BoolVarP(&unikernelSupport, "unikernel", "u", false, "Enable Unikernel support. [qemu/kvm required]")
-	rootCmd.Flags().StringVarP(&logDirectory, "logs", "l", "/tmp", "Directory for application's logs")
+	rootCmd.PersistentFlags().StringVarP(&configFile, "config", "c", config.DEFAULT_CONFIG_FILE, "NodeEngine configuration file (YAML), settings are overridden by NODE_ENGINE_* env variables and flags")
+	startupFlags(rootCmd.Flags())
+}
+
+// startupFlags adds the flags overriding the configuration of the NodeEngine
+func startupFlags(flags *pflag.FlagSet) {
+	defaults := config.Default()
+	flags.StringVarP(&clusterAddress, "clusterAddr", "a", defaults.Cluster.Address, "Address of the cluster orchestrator without port")
+	flags.IntVarP(&clusterPort, "clusterPort", "p", defaults.Cluster.Port, "Port of the cluster orchestrator")
+	flags.IntVarP(&overlayNetwork, "netmanagerPort", "n", defaults.Network.NetManagerPort, "Port of the NetManager component, if any. This enables the overlay network across nodes. Use -1 to disable Overlay Network Mode.")
+	flags.BoolVarP(&unikernelSupport, "unikernel", "u", defaults.Runtime.Unikernel, "Enable Unikernel support. [qemu/kvm required]")
+	flags.StringVarP(&logDirectory, "logs", "l", defaults.Node.LogDirectory, "Directory for application's logs")
+	flags.Float64Var(&reservedCpu, "reservedCpu", defaults.Reserved.Cpu, "CPU cores reserved to the system, never allocated to services")
+	flags.IntVar(&reservedMemory, "reservedMemory", defaults.Reserved.Memory, "Memory in MB reserved to the system, never allocated to services")
+	flags.IntVar(&reservedDisk, "reservedDisk", defaults.Reserved.Disk, "Disk space in MB reserved to the system, never allocated to services")
+	flags.StringSliceVar(&allowedImages, "allowImages", defaults.Images.Allow, "Image reference prefixes allowed on this node, e.g. docker.io/library/. Default: all")
+	flags.StringSliceVar(&deniedImages, "denyImages", defaults.Images.Deny, "Image reference prefixes never deployed on this node")
+	flags.StringVar(&labelsFile, "labels", defaults.Node.LabelsFile, "JSON file with the node labels and taints, watched for changes")
+	flags.StringVar(&metricsAddress, "metrics", defaults.Metrics.Address, "Address of the Prometheus metrics listener, e.g. :9100. Default: disabled")
+	flags.StringVar(&standaloneManifest, "standalone", defaults.Standalone.Manifest, "Run the services listed in this manifest without a cluster, watched for changes")
+}
+
+// loadConfig builds the configuration from file, environment and flags, then validates it
+func loadConfig(cmd *cobra.Command) (config.Config, error) {
+	cfg, err := readConfig(cmd)
+	if err != nil {
+		return cfg, err
+	}
+	return cfg, cfg.Validate()
+}
+
+// readConfig builds the configuration from file and environment, then applies the flags explicitly set by the user
+func readConfig(cmd *cobra.Command) (config.Config, error) {
+	cfg, err := config.Load(configFile, cmd.Flags().Changed("config"))
+	if err != nil {
+		return cfg, err
+	}
+	flags := cmd.Flags()
+	if flags.Changed("clusterAddr") {
+		cfg.Cluster.Address = clusterAddress
+	}
+	if flags.Changed("clusterPort") {
+		cfg.Cluster.Port = clusterPort
+	}
+	if flags.Changed("netmanagerPort") {
+		cfg.Network.NetManagerPort = overlayNetwork
+	}
+	if flags.Changed("unikernel") {
+		cfg.Runtime.Unikernel = unikernelSupport
+	}
+	if flags.Changed("logs") {
+		cfg.Node.LogDirectory = logDirectory
+	}
+	if flags.Changed("reservedCpu") {
+		cfg.Reserved.Cpu = reservedCpu
+	}
+	if flags.Changed("reservedMemory") {
+		cfg.Reserved.Memory = reservedMemory
+	}
+	if flags.Changed("reservedDisk") {
+		cfg.Reserved.Disk = reservedDisk
+	}
+	if flags.Changed("allowImages") {
+		cfg.Images.Allow = allowedImages
+	}
+	if flags.Changed("denyImages") {
+		cfg.Images.Deny = deniedImages
+	}
+	if flags.Changed("labels") {
+		cfg.Node.LabelsFile = labelsFile
+	}
+	if flags.Changed("metrics") {
+		cfg.Metrics.Address = metricsAddress
+	}
+	if flags.Changed("standalone") {
+		cfg.Standalone.Manifest = standaloneManifest
+	}
+	return cfg, nil
 }
 
-func startNodeEngine() error {
+func startNodeEngine(cmd *cobra.Command) error {
+	cfg := config.Get()
+
+	// apply the hot-reloadable settings now and at every configuration change
+	applyRuntimeSettings(cfg)
+	config.OnChange(applyRuntimeSettings)
+	config.Watch(configFile, CONFIG_WATCH_CYCLE, func() (config.Config, error) {
+		return loadConfig(cmd)
+	})
+
 	// set log directory
-	model.GetNodeInfo().SetLogDirectory(logDirectory)
+	model.SetLogDirectory(cfg.Node.LogDirectory)
+
+	// resources kept aside for the system when computing the allocatable capacity
+	model.GetLedger().SetSystemReserved(model.ResourceAmount{
+		Cpu:    cfg.Reserved.Cpu,
+		Memory: cfg.Reserved.Memory,
+		Disk:   cfg.Reserved.Disk,
+	})
+
+	// images allowed to be deployed, checked before pulling
+	admission.GetController().SetImagePolicy(admission.ImagePolicy{
+		Allow: cfg.Images.Allow,
+		Deny:  cfg.Images.Deny,
+	})
+
+	// expose the node and engine metrics to Prometheus
+	if cfg.Metrics.Address != "" {
+		metrics.Serve(cfg.Metrics.Address)
+	}
+
+	// serve the node-local admin API to the operators, also while the cluster is unreachable
+	if cfg.Admin.Socket != "" {
+		adminServer, err := admin.Serve(cfg.Admin.Socket)
+		if err != nil {
+			log.Error("Unable to serve the admin API", "socket", cfg.Admin.Socket, logger.ERROR, err)
+		} else {
+			defer adminServer.Close() //nolint:errcheck // the socket is removed on close
+		}
+	}
+
+	// export the deployment traces, flushing the pending spans on termination
+	shutdownTracing, err := tracing.Init(cfg.Tracing)
+	if err != nil {
+		log.Fatal("Unable to start the trace exporter", logger.ERROR, err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), TRACING_SHUTDOWN_TIMEOUT)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			log.Error("Unable to flush the traces", logger.ERROR, err)
+		}
+	}()
+
+	// leave the cluster once the services are stopped, deferred first to run last
+	standaloneMode := cfg.Standalone.Manifest != ""
+	if !standaloneMode {
+		defer mqtt.Shutdown()
+	}
 
 	// connect to container runtime
 	runtime := virtualization.GetContainerdClient()
 	defer runtime.StopContainerdClient()
 
-	if unikernelSupport {
+	if cfg.Runtime.Unikernel {
 		unikernelRuntime := virtualization.GetUnikernelRuntime()
 		defer unikernelRuntime.StopUnikernelRuntime()
 	}
-	// hadshake with the cluster orchestrator to get mqtt port and node id
-	handshakeResult := clusterHandshake()
+	// load labels and taints, and probe the node capabilities advertised at registration
+	if err := model.SetLabelsFile(cfg.Node.LabelsFile); err != nil {
+		log.Fatal("Unable to load node labels", logger.ERROR, err)
+	}
 
-	// enable overlay network if required
-	if overlayNetwork > 0 {
-		model.EnableOverlay(overlayNetwork)
-		err := requests.RegisterSelfToNetworkComponent()
-		if err != nil {
-			logger.ErrorLogger().Fatalf("Unable to register to NetManager: %v", err)
+	if standaloneMode {
+		// run the services of the local manifest, reporting to the logs and the admin API only
+		startStandalone(cfg)
+	} else {
+		// register to the cluster orchestrator, and again whenever the cluster forgets this node
+		if err := register(cfg); err != nil {
+			log.Fatal("Unable to register to the cluster orchestrator", logger.ERROR, err)
 		}
+		mqtt.SetReRegistrationHandler(reRegister)
 	}
 
-	// binding the node MQTT client
-	mqtt.InitMqtt(handshakeResult.NodeId, clusterAddress, handshakeResult.MqttPort)
-
 	// starting node status background job.
-	jobs.NodeStatusUpdater(MONITORING_CYCLE, mqtt.ReportNodeInformation)
+	jobs.NodeStatusUpdater(config.MonitoringCycle, mqtt.ReportNodeInformation)
 	// starting container resources background monitor.
-	jobs.StartServicesMonitoring(MONITORING_CYCLE, mqtt.ReportServiceResources)
+	jobs.StartServicesMonitoring(config.MonitoringCycle, mqtt.ReportServiceResources)
+	// starting labels and capabilities background watcher.
+	jobs.NodeLabelsUpdater(config.LabelsCycle, mqtt.ReportNodeLabels)
 
 	// catch SIGETRM or SIGINTERRUPT
 	termination := make(chan os.Signal, 1)
@@ -86,24 +255,93 @@ func startNodeEngine() error {
 	signal.Notify(termination, syscall.SIGTERM, syscall.SIGINT)
 	select {
 	case ossignal := <-termination:
-		logger.InfoLogger().Printf("Terminating the NodeEngine, signal:%v", ossignal)
+		log.Info("Terminating the NodeEngine", "signal", ossignal.String())
+		if !standaloneMode {
+			mqtt.PublishPresence(mqtt.PRESENCE_DRAINING)
+		}
+	}
+
+	return nil
+}
+
+// applyRuntimeSettings applies the settings that can change while the NodeEngine is running
+func applyRuntimeSettings(cfg config.Config) {
+	if err := logger.Configure(logger.Settings{Level: cfg.Log.Level, Format: cfg.Log.Format, Modules: cfg.Log.Modules}); err != nil {
+		log.Error("Unable to configure the logs", logger.ERROR, err)
+	}
+	// accelerators metrics are sampled once per monitoring cycle
+	gpu.GetCollector().SetCacheTTL(cfg.Monitoring.Cycle)
+}
+
+// register performs the handshake with the cluster orchestrator, then registers to the NetManager and binds the MQTT client
+// with the node ID received. Returns an error once the handshake retries are exhausted.
+func register(cfg config.Config) error {
+	model.SetRegistrationState(model.REGISTRATION_REGISTERING)
+
+	// hadshake with the cluster orchestrator to get mqtt port and node id, presenting the identity of the previous run
+	identity := loadIdentity(cfg)
+	handshakeResult, err := clusterHandshake(cfg.Cluster, identity)
+	if err != nil {
+		return err
 	}
+	identity = saveIdentity(cfg, identity, handshakeResult)
 
+	// enable overlay network if required
+	if cfg.Network.NetManagerPort > 0 {
+		model.EnableOverlay(cfg.Network.NetManagerPort)
+		err := requests.RegisterSelfToNetworkComponent()
+		if err != nil {
+			return fmt.Errorf("unable to register to NetManager: %w", err)
+		}
+	}
+
+	// binding the node MQTT client
+	mqtt.InitMqtt(identity.NodeId, cfg.Cluster.Address, handshakeResult.MqttPort, mqtt.BrokerCredentials{
+		Username: identity.MqttUsername,
+		Password: identity.MqttPassword,
+	})
 	return nil
 }
 
-func clusterHandshake() requests.HandshakeAnswer {
-	logger.InfoLogger().Printf("INIT: Starting handshake with cluster orchestrator %s:%d", clusterAddress, clusterPort)
+// reRegister registers the node again while it is running. The node stays registering and keeps retrying,
+// waiting for the next backoff cycle after each failed attempt, since the services keep running meanwhile.
+func reRegister() {
+	for attempt := 0; ; attempt++ {
+		cfg := config.Get()
+		err := register(cfg)
+		if err == nil {
+			return
+		}
+		wait := requests.ClusterBackoff(cfg.Cluster).Delay(attempt)
+		log.Error("Unable to register again to the cluster orchestrator, retrying", "attempt", attempt+1, "wait", wait, logger.ERROR, err)
+		model.SetRegistrationState(model.REGISTRATION_REGISTERING)
+		time.Sleep(wait)
+	}
+}
+
+// startStandalone deploys the services of the manifest and watches it, instead of registering to the cluster.
+// The overlay network needs the cluster, it is not enabled.
+func startStandalone(cfg config.Config) {
+	mqtt.SetStandalone()
+	model.SetRegistrationState(model.REGISTRATION_STANDALONE)
+	if cfg.Network.NetManagerPort > 0 {
+		log.Warn("The overlay network is not available in standalone mode", "netmanager_port", cfg.Network.NetManagerPort)
+	}
+	if err := standalone.Run(context.Background(), cfg.Standalone.Manifest, MANIFEST_WATCH_CYCLE); err != nil {
+		log.Fatal("Unable to run the standalone manifest", logger.ERROR, err)
+	}
+}
+
+func clusterHandshake(cluster config.ClusterConfig, identity model.Identity) (requests.HandshakeAnswer, error) {
+	log.Info("Starting handshake with cluster orchestrator", "cluster_address", cluster.Address, "cluster_port", cluster.Port)
 	node := model.GetNodeInfo()
-	logger.InfoLogger().Printf("Node Statistics: \n__________________")
-	logger.InfoLogger().Printf("CPU Cores: %d", node.CpuCores)
-	logger.InfoLogger().Printf("CPU Usage: %f", node.CpuUsage)
-	logger.InfoLogger().Printf("Mem Usage: %f", node.MemoryUsed)
-	logger.InfoLogger().Printf("GPU Driver: %s", node.GpuDriver)
-	logger.InfoLogger().Printf("\n________________")
-	clusterReponse := requests.ClusterHandshake(clusterAddress, clusterPort)
-	logger.InfoLogger().Printf("Got cluster response with MQTT port %s and node ID %s", clusterReponse.MqttPort, clusterReponse.NodeId)
+	log.Info("Node statistics", "cpu_cores", node.CpuCores, "cpu_usage", node.CpuUsage, "memory_usage", node.MemoryUsed, "gpu_driver", node.GpuDriver)
+	clusterReponse, err := requests.ClusterHandshake(cluster, identity, requests.ClusterBackoff(cluster))
+	if err != nil {
+		return clusterReponse, err
+	}
+	log.Info("Got cluster response", "mqtt_port", clusterReponse.MqttPort, logger.NODE_ID, clusterReponse.NodeId)
 
 	model.SetNodeId(clusterReponse.NodeId)
-	return clusterReponse
+	return clusterReponse, nil
 }
@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"go_node_engine/admin"
+	"go_node_engine/model"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(operatorCommand(instanceActionCommand(
+		"stop", "Undeploy an instance, releasing its resources and reporting it to the cluster", "undeployed", (*admin.Client).Undeploy), false))
+	rootCmd.AddCommand(operatorCommand(instanceActionCommand(
+		"pause", "Freeze an instance, which keeps its resources", "paused", (*admin.Client).Pause), false))
+	rootCmd.AddCommand(operatorCommand(instanceActionCommand(
+		"resume", "Let a paused instance continue", "resumed", (*admin.Client).Resume), false))
+}
+
+// instanceActionCommand returns the subcommand performing the action on the instance given as argument
+func instanceActionCommand(
+	name string,
+	short string,
+	done string,
+	action func(client *admin.Client, ctx context.Context, sname string, instance int) error,
+) *cobra.Command {
+	return &cobra.Command{
+		Use:   fmt.Sprintf("%[1]s <service> <instance> | %[1]s <service>.instance.<instance>", name),
+		Short: short,
+		Args:  cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			sname, instance, err := instanceArgs(args)
+			if err != nil {
+				return err
+			}
+			client, err := adminClient(cmd)
+			if err != nil {
+				return err
+			}
+			if err := action(client, context.Background(), sname, instance); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "%s %s\n", model.InstanceKey(sname, instance), done)
+			return nil
+		},
+	}
+}
@@ -0,0 +1,241 @@
+package config
+
+import (
+	"sync"
+	"time"
+)
+
+// DEFAULT_CONFIG_FILE is the configuration file used when --config is not given
+const DEFAULT_CONFIG_FILE = "/etc/oakestra/node-engine.yaml"
+
+// ENV_PREFIX prefixes the environment variables overriding the configuration file,
+// e.g. NODE_ENGINE_CLUSTER_ADDRESS overrides cluster.address
+const ENV_PREFIX = "NODE_ENGINE"
+
+// Config is the NodeEngine configuration
+type Config struct {
+	Cluster    ClusterConfig    `yaml:"cluster"`
+	Mqtt       MqttConfig       `yaml:"mqtt"`
+	Node       NodeConfig       `yaml:"node"`
+	Runtime    RuntimeConfig    `yaml:"runtime"`
+	Network    NetworkConfig    `yaml:"network"`
+	Monitoring MonitoringConfig `yaml:"monitoring"`
+	Reserved   ReservedConfig   `yaml:"reserved"`
+	Images     ImagesConfig     `yaml:"images"`
+	Log        LogConfig        `yaml:"log"`
+	Metrics    MetricsConfig    `yaml:"metrics"`
+	Tracing    TracingConfig    `yaml:"tracing"`
+	Admin      AdminConfig      `yaml:"admin"`
+	Standalone StandaloneConfig `yaml:"standalone"`
+}
+
+// ClusterConfig is the cluster orchestrator the node registers to. Scheme is http or https,
+// with https the server is verified against the CA file, or the system CAs, and the client certificate enables mutual TLS.
+// The handshake is retried with a jittered exponential backoff from RetryInitial up to RetryMax, for at most RetryTimeout.
+type ClusterConfig struct {
+	Address      string        `yaml:"address"`
+	Port         int           `yaml:"port"`
+	Scheme       string        `yaml:"scheme"`
+	CaFile       string        `yaml:"ca_file"`
+	CertFile     string        `yaml:"cert_file"`
+	KeyFile      string        `yaml:"key_file"`
+	ServerName   string        `yaml:"server_name"`
+	RetryInitial time.Duration `yaml:"retry_initial"`
+	RetryMax     time.Duration `yaml:"retry_max"`
+	RetryTimeout time.Duration `yaml:"retry_timeout"`
+}
+
+// MqttConfig secures the connection to the cluster MQTT broker. Scheme is one of tcp, ssl, ws or wss.
+// The CA file pins the broker certificate authority, the client certificate enables mutual TLS.
+// A token is sent as password, with the username defaulting to the node ID.
+// Credentials handed out by the cluster at handshake take precedence over the configured ones.
+// OutboxLimit bounds the status reports queued on disk while the broker is unreachable.
+// Version is auto, 5 or 3.1.1, with auto MQTT 5 is preferred and 3.1.1 used if the broker does not support it.
+// Control commands are deduplicated by request ID for DedupWindow.
+// Encoding is json or protobuf, the latter requires MQTT 5 to tell the content type of every message.
+type MqttConfig struct {
+	Version       string        `yaml:"version"`
+	Scheme        string        `yaml:"scheme"`
+	WebsocketPath string        `yaml:"websocket_path"`
+	CaFile        string        `yaml:"ca_file"`
+	CertFile      string        `yaml:"cert_file"`
+	KeyFile       string        `yaml:"key_file"`
+	ServerName    string        `yaml:"server_name"`
+	Username      string        `yaml:"username"`
+	PasswordFile  string        `yaml:"password_file"`
+	TokenFile     string        `yaml:"token_file"`
+	OutboxLimit   int           `yaml:"outbox_limit"`
+	DedupWindow   time.Duration `yaml:"dedup_window"`
+	Encoding      string        `yaml:"encoding"`
+}
+
+// NodeConfig describes the node itself. The state directory keeps what must survive a restart, like the node identity.
+type NodeConfig struct {
+	Port           int    `yaml:"port"`
+	LogDirectory   string `yaml:"log_directory"`
+	LabelsFile     string `yaml:"labels_file"`
+	StateDirectory string `yaml:"state_directory"`
+}
+
+// RuntimeConfig configures the virtualization runtimes.
+// The replacement of an instance updated in place is ready if it is still running UpdateReadiness after it started,
+// there is no health probe; only then the previous container is stopped.
+type RuntimeConfig struct {
+	ContainerdSocket   string        `yaml:"containerd_socket"`
+	Namespace          string        `yaml:"namespace"`
+	Unikernel          bool          `yaml:"unikernel"`
+	UnikernelDirectory string        `yaml:"unikernel_directory"`
+	UpdateReadiness    time.Duration `yaml:"update_readiness"`
+}
+
+// NetworkConfig configures the NetManager and the services DNS. NetManagerPort -1 disables the overlay network.
+// When NetManagerSocket is set the NetManager is reached over that unix domain socket instead of host and port.
+type NetworkConfig struct {
+	NetManagerHost   string   `yaml:"netmanager_host"`
+	NetManagerPort   int      `yaml:"netmanager_port"`
+	NetManagerSocket string   `yaml:"netmanager_socket"`
+	Nameservers      []string `yaml:"nameservers"`
+}
+
+// MonitoringConfig sets the cadence of the background jobs, hot-reloadable
+type MonitoringConfig struct {
+	Cycle       time.Duration `yaml:"cycle"`
+	LabelsCycle time.Duration `yaml:"labels_cycle"`
+}
+
+// ReservedConfig are the resources kept aside for the system. Cpu is in cores, Memory and Disk in MB.
+type ReservedConfig struct {
+	Cpu    float64 `yaml:"cpu"`
+	Memory int     `yaml:"memory"`
+	Disk   int     `yaml:"disk"`
+}
+
+// ImagesConfig is the image policy enforced by the admission control
+type ImagesConfig struct {
+	Allow []string `yaml:"allow"`
+	Deny  []string `yaml:"deny"`
+}
+
+// LogConfig sets the log verbosity and format, hot-reloadable. Modules overrides the level of single modules,
+// e.g. mqtt: debug, and is set from the environment as mqtt=debug,virtualization=error
+type LogConfig struct {
+	Level   string            `yaml:"level"`
+	Format  string            `yaml:"format"`
+	Modules map[string]string `yaml:"modules,omitempty"`
+}
+
+// MetricsConfig enables the Prometheus metrics listener on Address, e.g. :9100. Empty disables it.
+type MetricsConfig struct {
+	Address string `yaml:"address"`
+}
+
+// TracingConfig exports the deployment traces to the OTLP/HTTP collector at Endpoint (host:port). Empty disables it.
+// Insecure sends them over plain http, SampleRatio is the fraction of the new traces recorded.
+type TracingConfig struct {
+	Endpoint    string  `yaml:"endpoint"`
+	Insecure    bool    `yaml:"insecure"`
+	SampleRatio float64 `yaml:"sample_ratio"`
+}
+
+// AdminConfig enables the node-local admin API on the unix domain socket at Socket. Empty disables it.
+type AdminConfig struct {
+	Socket string `yaml:"socket"`
+}
+
+// StandaloneConfig runs the services listed in the Manifest file without a cluster, watching it for changes.
+// Empty registers the node to the cluster orchestrator as usual.
+type StandaloneConfig struct {
+	Manifest string `yaml:"manifest"`
+}
+
+// Default returns the configuration used when no file, environment variable or flag overrides it
+func Default() Config {
+	return Config{
+		Cluster: ClusterConfig{
+			Address:      "localhost",
+			Port:         10100,
+			Scheme:       "http",
+			RetryInitial: time.Second,
+			RetryMax:     time.Minute,
+			RetryTimeout: time.Minute * 10,
+		},
+		Mqtt: MqttConfig{
+			Version:       "auto",
+			Scheme:        "tcp",
+			WebsocketPath: "/mqtt",
+			OutboxLimit:   1000,
+			DedupWindow:   time.Minute * 10,
+			Encoding:      "json",
+		},
+		Node: NodeConfig{
+			Port:           3000,
+			LogDirectory:   "/tmp",
+			StateDirectory: "/var/lib/oakestra/node_engine",
+		},
+		Runtime: RuntimeConfig{
+			ContainerdSocket:   "/run/containerd/containerd.sock",
+			Namespace:          "oakestra",
+			Unikernel:          false,
+			UnikernelDirectory: "/tmp/node_engine",
+			UpdateReadiness:    time.Second * 10,
+		},
+		Network: NetworkConfig{
+			NetManagerHost: "localhost",
+			NetManagerPort: 6000,
+			Nameservers:    []string{"8.8.8.8"},
+		},
+		Monitoring: MonitoringConfig{
+			Cycle:       time.Second * 2,
+			LabelsCycle: time.Second * 10,
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: "text",
+		},
+		Tracing: TracingConfig{
+			SampleRatio: 1,
+		},
+		Admin: AdminConfig{
+			Socket: "/run/oakestra/node-engine.sock",
+		},
+	}
+}
+
+var current = Default()
+var currentLock sync.RWMutex
+var subscribers = make([]func(Config), 0)
+
+// Get returns the configuration currently in use
+func Get() Config {
+	currentLock.RLock()
+	defer currentLock.RUnlock()
+	return current
+}
+
+// Set replaces the configuration in use and notifies the subscribers
+func Set(cfg Config) {
+	currentLock.Lock()
+	current = cfg
+	notify := append([]func(Config){}, subscribers...)
+	currentLock.Unlock()
+	for _, subscriber := range notify {
+		subscriber(cfg)
+	}
+}
+
+// OnChange registers a handler invoked every time the configuration is replaced
+func OnChange(handler func(Config)) {
+	currentLock.Lock()
+	defer currentLock.Unlock()
+	subscribers = append(subscribers, handler)
+}
+
+// MonitoringCycle returns the current resources monitoring cadence
+func MonitoringCycle() time.Duration {
+	return Get().Monitoring.Cycle
+}
+
+// LabelsCycle returns the current labels and capabilities probing cadence
+func LabelsCycle() time.Duration {
+	return Get().Monitoring.LabelsCycle
+}
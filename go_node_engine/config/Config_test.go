@@ -0,0 +1,114 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+)
+
+func TestLoadFileAndEnv(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "node-engine.yaml")
+	content := `
+cluster:
+  address: 10.0.0.1
+monitoring:
+  cycle: 5s
+network:
+  nameservers: [1.1.1.1]
+`
+	assert.NilError(t, os.WriteFile(path, []byte(content), 0644))
+	t.Setenv("NODE_ENGINE_CLUSTER_PORT", "10200")
+	t.Setenv("NODE_ENGINE_NETWORK_NAMESERVERS", "9.9.9.9, 1.1.1.1")
+	t.Setenv("MY_PORT", "3005")
+	t.Setenv("NODE_ENGINE_LOG_MODULES", "mqtt=debug, virtualization=error")
+
+	cfg, err := Load(path, true)
+	assert.NilError(t, err)
+	assert.Equal(t, cfg.Cluster.Address, "10.0.0.1")
+	assert.Equal(t, cfg.Cluster.Port, 10200)
+	assert.Equal(t, cfg.Monitoring.Cycle, 5*time.Second)
+	assert.Equal(t, cfg.Monitoring.LabelsCycle, Default().Monitoring.LabelsCycle)
+	assert.DeepEqual(t, cfg.Network.Nameservers, []string{"9.9.9.9", "1.1.1.1"})
+	assert.Equal(t, cfg.Node.Port, 3005)
+	assert.DeepEqual(t, cfg.Log.Modules, map[string]string{"mqtt": "debug", "virtualization": "error"})
+	assert.NilError(t, cfg.Validate())
+}
+
+func TestLoadErrors(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing.yaml")
+	_, err := Load(missing, false)
+	assert.NilError(t, err)
+	_, err = Load(missing, true)
+	assert.ErrorContains(t, err, "unable to read configuration file")
+
+	path := filepath.Join(t.TempDir(), "node-engine.yaml")
+	assert.NilError(t, os.WriteFile(path, []byte("cluster:\n  adress: 10.0.0.1\n"), 0644))
+	_, err = Load(path, true)
+	assert.ErrorContains(t, err, "field adress not found")
+
+	t.Setenv("NODE_ENGINE_MONITORING_CYCLE", "often")
+	_, err = Load(missing, false)
+	assert.ErrorContains(t, err, "invalid NODE_ENGINE_MONITORING_CYCLE")
+}
+
+func TestValidate(t *testing.T) {
+	assert.NilError(t, Default().Validate())
+
+	cfg := Default()
+	cfg.Cluster.Port = 0
+	cfg.Network.Nameservers = []string{"dns.google"}
+	cfg.Log.Level = "verbose"
+	cfg.Metrics.Address = "9100"
+	cfg.Tracing.SampleRatio = 2
+	cfg.Log.Modules = map[string]string{"mqtt": "trace"}
+	cfg.Admin.Socket = "node-engine.sock"
+	err := cfg.Validate()
+	assert.ErrorContains(t, err, "cluster.port 0 out of range")
+	assert.ErrorContains(t, err, `"dns.google" is not an IP address`)
+	assert.ErrorContains(t, err, `log.level "verbose"`)
+	assert.ErrorContains(t, err, `metrics.address "9100" must be host:port`)
+	assert.ErrorContains(t, err, "tracing.sample_ratio must be between 0 and 1")
+	assert.ErrorContains(t, err, `log.modules.mqtt "trace"`)
+	assert.ErrorContains(t, err, `admin.socket "node-engine.sock" must be an absolute path`)
+
+	cfg = Default()
+	cfg.Network.NetManagerPort = -1
+	cfg.Metrics.Address = ":9100"
+	assert.NilError(t, cfg.Validate())
+}
+
+func TestHotReload(t *testing.T) {
+	running := Default()
+	loaded := Default()
+	loaded.Monitoring.Cycle = 10 * time.Second
+	loaded.Log.Level = "debug"
+	loaded.Runtime.Namespace = "other"
+
+	reloaded, ignored := hotReload(running, loaded)
+	assert.Equal(t, reloaded.Monitoring.Cycle, 10*time.Second)
+	assert.Equal(t, reloaded.Log.Level, "debug")
+	assert.Equal(t, reloaded.Runtime.Namespace, running.Runtime.Namespace)
+	assert.DeepEqual(t, ignored, []string{"runtime"})
+}
+
+func TestValidateMqtt(t *testing.T) {
+	cfg := Default()
+	cfg.Mqtt.CaFile = "/nonexistent/ca.pem"
+	cfg.Mqtt.CertFile = "/nonexistent/cert.pem"
+	err := cfg.Validate()
+	assert.ErrorContains(t, err, "mqtt TLS settings require the ssl or wss scheme")
+	assert.ErrorContains(t, err, "mqtt.cert_file and mqtt.key_file must be set together")
+	assert.ErrorContains(t, err, "no such file or directory")
+
+	cfg = Default()
+	cfg.Mqtt.Scheme = "wss"
+	assert.NilError(t, cfg.Validate())
+
+	cfg.Mqtt.Encoding = "protobuf"
+	assert.ErrorContains(t, cfg.Validate(), "mqtt.encoding protobuf requires mqtt.version 5")
+	cfg.Mqtt.Version = "5"
+	assert.NilError(t, cfg.Validate())
+}
@@ -0,0 +1,317 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Load builds the configuration from the defaults, the given YAML file and the NODE_ENGINE_* environment variables,
+// in increasing order of precedence. A missing file is an error only if required is true.
+func Load(path string, required bool) (Config, error) {
+	cfg := Default()
+
+	content, err := os.ReadFile(path)
+	if err != nil && (required || !errors.Is(err, fs.ErrNotExist)) {
+		return cfg, fmt.Errorf("unable to read configuration file: %v", err)
+	}
+	if err == nil {
+		decoder := yaml.NewDecoder(bytes.NewReader(content))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+			return cfg, fmt.Errorf("invalid configuration file %s: %v", path, err)
+		}
+	}
+
+	if err := applyEnv(reflect.ValueOf(&cfg).Elem(), ENV_PREFIX, os.LookupEnv); err != nil {
+		return cfg, err
+	}
+	// MY_PORT is kept for backward compatibility with older deployments
+	if port, found := os.LookupEnv("MY_PORT"); found {
+		if _, overridden := os.LookupEnv(ENV_PREFIX + "_NODE_PORT"); !overridden {
+			cfg.Node.Port, err = strconv.Atoi(port)
+			if err != nil {
+				return cfg, fmt.Errorf("invalid MY_PORT: %v", err)
+			}
+		}
+	}
+	return cfg, nil
+}
+
+// Marshal encodes the configuration as YAML
+func Marshal(cfg Config) ([]byte, error) {
+	return yaml.Marshal(cfg)
+}
+
+// Validate checks the configuration values, reporting all the problems at once
+func (cfg Config) Validate() error {
+	problems := make([]string, 0)
+	if cfg.Cluster.Address == "" {
+		problems = append(problems, "cluster.address must not be empty")
+	}
+	if !validPort(cfg.Cluster.Port) {
+		problems = append(problems, fmt.Sprintf("cluster.port %d out of range", cfg.Cluster.Port))
+	}
+	problems = append(problems, cfg.Cluster.validate()...)
+	problems = append(problems, cfg.Mqtt.validate()...)
+	if !validPort(cfg.Node.Port) {
+		problems = append(problems, fmt.Sprintf("node.port %d out of range", cfg.Node.Port))
+	}
+	if cfg.Node.LogDirectory == "" {
+		problems = append(problems, "node.log_directory must not be empty")
+	}
+	if !filepath.IsAbs(cfg.Node.StateDirectory) {
+		problems = append(problems, "node.state_directory must be an absolute path")
+	}
+	if cfg.Runtime.ContainerdSocket == "" {
+		problems = append(problems, "runtime.containerd_socket must not be empty")
+	}
+	if cfg.Runtime.Namespace == "" {
+		problems = append(problems, "runtime.namespace must not be empty")
+	}
+	if !filepath.IsAbs(cfg.Runtime.UnikernelDirectory) {
+		problems = append(problems, "runtime.unikernel_directory must be an absolute path")
+	}
+	if cfg.Runtime.UpdateReadiness <= 0 {
+		problems = append(problems, "runtime.update_readiness must be positive")
+	}
+	if cfg.Network.NetManagerPort != -1 && !validPort(cfg.Network.NetManagerPort) {
+		problems = append(problems, fmt.Sprintf("network.netmanager_port %d out of range, use -1 to disable the overlay", cfg.Network.NetManagerPort))
+	}
+	if cfg.Network.NetManagerHost == "" {
+		problems = append(problems, "network.netmanager_host must not be empty")
+	}
+	if cfg.Network.NetManagerSocket != "" && !filepath.IsAbs(cfg.Network.NetManagerSocket) {
+		problems = append(problems, "network.netmanager_socket must be an absolute path")
+	}
+	if len(cfg.Network.Nameservers) == 0 {
+		problems = append(problems, "network.nameservers must contain at least one address")
+	}
+	for _, nameserver := range cfg.Network.Nameservers {
+		if net.ParseIP(nameserver) == nil {
+			problems = append(problems, fmt.Sprintf("network.nameservers: %q is not an IP address", nameserver))
+		}
+	}
+	if cfg.Monitoring.Cycle <= 0 {
+		problems = append(problems, "monitoring.cycle must be positive")
+	}
+	if cfg.Monitoring.LabelsCycle <= 0 {
+		problems = append(problems, "monitoring.labels_cycle must be positive")
+	}
+	if cfg.Reserved.Cpu < 0 || cfg.Reserved.Memory < 0 || cfg.Reserved.Disk < 0 {
+		problems = append(problems, "reserved resources must not be negative")
+	}
+	if !validLevel(cfg.Log.Level) {
+		problems = append(problems, fmt.Sprintf("log.level %q must be one of debug, info, warn, error", cfg.Log.Level))
+	}
+	if cfg.Log.Format != "text" && cfg.Log.Format != "json" {
+		problems = append(problems, fmt.Sprintf("log.format %q must be text or json", cfg.Log.Format))
+	}
+	for module, level := range cfg.Log.Modules {
+		if !validLevel(level) {
+			problems = append(problems, fmt.Sprintf("log.modules.%s %q must be one of debug, info, warn, error", module, level))
+		}
+	}
+	if cfg.Metrics.Address != "" {
+		if _, port, err := net.SplitHostPort(cfg.Metrics.Address); err != nil {
+			problems = append(problems, fmt.Sprintf("metrics.address %q must be host:port", cfg.Metrics.Address))
+		} else if number, err := strconv.Atoi(port); err != nil || !validPort(number) {
+			problems = append(problems, fmt.Sprintf("metrics.address %q has an invalid port", cfg.Metrics.Address))
+		}
+	}
+	if cfg.Tracing.Endpoint != "" {
+		if _, _, err := net.SplitHostPort(cfg.Tracing.Endpoint); err != nil {
+			problems = append(problems, fmt.Sprintf("tracing.endpoint %q must be host:port", cfg.Tracing.Endpoint))
+		}
+	}
+	if cfg.Tracing.SampleRatio < 0 || cfg.Tracing.SampleRatio > 1 {
+		problems = append(problems, "tracing.sample_ratio must be between 0 and 1")
+	}
+	if cfg.Admin.Socket != "" && !filepath.IsAbs(cfg.Admin.Socket) {
+		problems = append(problems, fmt.Sprintf("admin.socket %q must be an absolute path", cfg.Admin.Socket))
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid configuration: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func (mqtt MqttConfig) validate() []string {
+	problems := make([]string, 0)
+	switch mqtt.Version {
+	case "auto", "5", "3.1.1":
+	default:
+		problems = append(problems, fmt.Sprintf("mqtt.version %q must be one of auto, 5, 3.1.1", mqtt.Version))
+	}
+	secure := false
+	switch mqtt.Scheme {
+	case "tcp", "ws":
+	case "ssl", "wss":
+		secure = true
+	default:
+		problems = append(problems, fmt.Sprintf("mqtt.scheme %q must be one of tcp, ssl, ws, wss", mqtt.Scheme))
+	}
+	if !secure && (mqtt.CaFile != "" || mqtt.CertFile != "" || mqtt.ServerName != "") {
+		problems = append(problems, "mqtt TLS settings require the ssl or wss scheme")
+	}
+	if mqtt.OutboxLimit <= 0 {
+		problems = append(problems, "mqtt.outbox_limit must be positive")
+	}
+	if mqtt.DedupWindow <= 0 {
+		problems = append(problems, "mqtt.dedup_window must be positive")
+	}
+	switch mqtt.Encoding {
+	case "json":
+	case "protobuf":
+		if mqtt.Version != "5" {
+			problems = append(problems, "mqtt.encoding protobuf requires mqtt.version 5")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("mqtt.encoding %q must be one of json, protobuf", mqtt.Encoding))
+	}
+	if mqtt.PasswordFile != "" && mqtt.TokenFile != "" {
+		problems = append(problems, "mqtt.password_file and mqtt.token_file are mutually exclusive")
+	}
+	return append(problems, validateFiles("mqtt", mqtt.CertFile, mqtt.KeyFile, mqtt.CaFile, mqtt.PasswordFile, mqtt.TokenFile)...)
+}
+
+func (cluster ClusterConfig) validate() []string {
+	problems := make([]string, 0)
+	switch cluster.Scheme {
+	case "http":
+		if cluster.CaFile != "" || cluster.CertFile != "" || cluster.ServerName != "" {
+			problems = append(problems, "cluster TLS settings require the https scheme")
+		}
+	case "https":
+	default:
+		problems = append(problems, fmt.Sprintf("cluster.scheme %q must be http or https", cluster.Scheme))
+	}
+	if cluster.RetryInitial <= 0 || cluster.RetryMax < cluster.RetryInitial || cluster.RetryTimeout < 0 {
+		problems = append(problems, "cluster retries require 0 < retry_initial <= retry_max and retry_timeout >= 0")
+	}
+	return append(problems, validateFiles("cluster", cluster.CertFile, cluster.KeyFile, cluster.CaFile)...)
+}
+
+// validateFiles checks that the client certificate and key are set together and that every given file exists
+func validateFiles(section string, certFile string, keyFile string, others ...string) []string {
+	problems := make([]string, 0)
+	if (certFile == "") != (keyFile == "") {
+		problems = append(problems, section+".cert_file and "+section+".key_file must be set together")
+	}
+	for _, file := range append([]string{certFile, keyFile}, others...) {
+		if file == "" {
+			continue
+		}
+		if _, err := os.Stat(file); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", section, err))
+		}
+	}
+	return problems
+}
+
+func validPort(port int) bool {
+	return port > 0 && port < 65536
+}
+
+func validLevel(level string) bool {
+	switch level {
+	case "debug", "info", "warn", "error":
+		return true
+	}
+	return false
+}
+
+// applyEnv walks the configuration fields, overriding each one with the variable named after its YAML path,
+// e.g. Monitoring.Cycle is overridden by NODE_ENGINE_MONITORING_CYCLE
+func applyEnv(value reflect.Value, prefix string, lookup func(string) (string, bool)) error {
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Field(i)
+		tag := strings.Split(value.Type().Field(i).Tag.Get("yaml"), ",")[0]
+		name := prefix + "_" + strings.ToUpper(tag)
+
+		if field.Kind() == reflect.Struct {
+			if err := applyEnv(field, name, lookup); err != nil {
+				return err
+			}
+			continue
+		}
+		env, found := lookup(name)
+		if !found {
+			continue
+		}
+		if err := setField(field, env); err != nil {
+			return fmt.Errorf("invalid %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+func setField(field reflect.Value, env string) error {
+	if field.Type() == durationType {
+		duration, err := time.ParseDuration(env)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(duration))
+		return nil
+	}
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(env)
+	case reflect.Int:
+		number, err := strconv.Atoi(env)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(number))
+	case reflect.Float64:
+		number, err := strconv.ParseFloat(env, 64)
+		if err != nil {
+			return err
+		}
+		field.SetFloat(number)
+	case reflect.Bool:
+		flag, err := strconv.ParseBool(env)
+		if err != nil {
+			return err
+		}
+		field.SetBool(flag)
+	case reflect.Slice:
+		items := make([]string, 0)
+		for _, item := range strings.Split(env, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		field.Set(reflect.ValueOf(items))
+	case reflect.Map:
+		items := make(map[string]string)
+		for _, item := range strings.Split(env, ",") {
+			if item = strings.TrimSpace(item); item == "" {
+				continue
+			}
+			key, value, found := strings.Cut(item, "=")
+			if !found {
+				return fmt.Errorf("%q is not key=value", item)
+			}
+			items[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+		field.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+	return nil
+}
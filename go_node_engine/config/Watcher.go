@@ -0,0 +1,67 @@
+package config
+
+import (
+	"go_node_engine/logger"
+	"os"
+	"reflect"
+	"time"
+)
+
+var log = logger.Module("config")
+
+// Watch polls the configuration file every interval and, when modified, reloads it through the given loader.
+// Only the hot-reloadable settings (monitoring cadence and log level) are applied, the others require a restart.
+func Watch(path string, every time.Duration, load func() (Config, error)) {
+	go func() {
+		lastModTime := modTime(path)
+		for {
+			<-time.After(every)
+			currentModTime := modTime(path)
+			if currentModTime.Equal(lastModTime) {
+				continue
+			}
+			lastModTime = currentModTime
+
+			loaded, err := load()
+			if err == nil {
+				err = loaded.Validate()
+			}
+			if err != nil {
+				log.Error("Configuration reload discarded", logger.ERROR, err)
+				continue
+			}
+			reloaded, ignored := hotReload(Get(), loaded)
+			for _, section := range ignored {
+				log.Warn("Configuration section changed, restart the NodeEngine to apply it", "section", section)
+			}
+			Set(reloaded)
+			log.Info("Configuration reloaded", "path", path)
+		}
+	}()
+}
+
+// hotReload applies the hot-reloadable settings of loaded over running,
+// returning the name of the changed sections that cannot be applied at runtime
+func hotReload(running Config, loaded Config) (Config, []string) {
+	reloaded := running
+	reloaded.Monitoring = loaded.Monitoring
+	reloaded.Log = loaded.Log
+
+	ignored := make([]string, 0)
+	runningValue := reflect.ValueOf(reloaded)
+	loadedValue := reflect.ValueOf(loaded)
+	for i := 0; i < runningValue.NumField(); i++ {
+		if !reflect.DeepEqual(runningValue.Field(i).Interface(), loadedValue.Field(i).Interface()) {
+			ignored = append(ignored, runningValue.Type().Field(i).Tag.Get("yaml"))
+		}
+	}
+	return reloaded, ignored
+}
+
+func modTime(path string) time.Time {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return stat.ModTime()
+}
@@ -0,0 +1,90 @@
+package deployment
+
+import (
+	"context"
+	"go_node_engine/admission"
+	"go_node_engine/logger"
+	"go_node_engine/metrics"
+	"go_node_engine/model"
+	"go_node_engine/tracing"
+	"go_node_engine/virtualization"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// The instances deployed by the node, whether requested by the cluster, a local manifest or an operator.
+// The status of the instances is tracked here and handed to the status listeners, e.g. the MQTT reports.
+
+var log = logger.Module("deployment")
+
+var listeners []func(service model.Service)
+var listenersLock sync.RWMutex
+
+// OnStatus registers a listener called with every status reported, after the instance tracking is updated
+func OnStatus(listener func(service model.Service)) {
+	listenersLock.Lock()
+	defer listenersLock.Unlock()
+	listeners = append(listeners, listener)
+}
+
+// Run deploys an admitted instance through its runtime and reports the outcome, returned as the instance status
+func Run(ctx context.Context, service model.Service, reserved bool, received time.Time) model.Service {
+	serviceLog := log.Instance(service.Sname, service.Instance)
+	ctx, deploySpan := tracing.Start(ctx, tracing.SPAN_DEPLOY, tracing.Instance(service.Sname, service.Instance, service.Runtime)...)
+	runtime := virtualization.GetRuntime(model.RuntimeType(service.Runtime))
+	err := runtime.Deploy(ctx, service, ReportStatusAndReleaseResources)
+	service.Status = model.SERVICE_CREATED
+	if err != nil {
+		serviceLog.Error("Deployment failed", logger.ERROR, err)
+		service.StatusDetail = err.Error()
+		service.Status = model.SERVICE_FAILED
+		// a reservation held by an already running instance must survive a rejected duplicate deploy
+		if reserved {
+			admission.GetController().Release(service.Sname, service.Instance)
+		}
+	}
+	result := metrics.RESULT_SUCCESS
+	if err != nil {
+		result = metrics.RESULT_FAILURE
+	}
+	metrics.DeployDuration.WithLabelValues(service.Runtime, result).Observe(time.Since(received).Seconds())
+	_, reportSpan := tracing.Start(ctx, tracing.SPAN_REPORT, attribute.String("oakestra.status", service.Status))
+	ReportStatus(service)
+	reportSpan.End()
+	tracing.End(deploySpan, err)
+	return service
+}
+
+// ReportStatusAndReleaseResources reports the status, releasing the admitted resources of the instances gone
+func ReportStatusAndReleaseResources(service model.Service) {
+	if Gone(service) {
+		admission.GetController().Release(service.Sname, service.Instance)
+	}
+	ReportStatus(service)
+}
+
+// ReportStatus records the status of the instance and hands it to the status listeners
+func ReportStatus(service model.Service) {
+	instances.update(service)
+	model.RecordEvent(model.Event{Type: model.EVENT_STATUS, Sname: service.Sname, Instance: service.Instance, Message: StatusMessage(service)})
+	listenersLock.RLock()
+	registered := listeners
+	listenersLock.RUnlock()
+	for _, listener := range registered {
+		listener(service)
+	}
+}
+
+// StatusMessage describes the status of the instance in a line, e.g. CREATED (UPDATE_COMPLETED)
+func StatusMessage(service model.Service) string {
+	message := service.Status
+	if service.StatusReason != "" {
+		message += " (" + service.StatusReason + ")"
+	}
+	if service.StatusDetail != "" {
+		message += ": " + service.StatusDetail
+	}
+	return message
+}
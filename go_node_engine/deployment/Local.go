@@ -0,0 +1,95 @@
+package deployment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go_node_engine/admission"
+	"go_node_engine/model"
+	"go_node_engine/virtualization"
+	"time"
+)
+
+// The operations requested on the node itself, e.g. through the admin API while the cluster is unreachable.
+// Their outcome is reported to the status listeners like the one of the control commands.
+
+// ErrNotDeployed is returned for an instance not deployed on the node
+var ErrNotDeployed = errors.New("instance not deployed")
+
+// ErrAlreadyDeployed is returned when deploying an instance already deployed on the node
+var ErrAlreadyDeployed = errors.New("instance already deployed")
+
+// ErrPauseUnsupported is returned if the runtime of the instance cannot pause it
+var ErrPauseUnsupported = errors.New("the runtime cannot pause instances")
+
+// Instances returns the instances deployed on the node with their last status, sorted by service and instance
+func Instances() []model.Service {
+	return instances.list()
+}
+
+// GetInstance returns the instance with its last status, if deployed on the node
+func GetInstance(sname string, instance int) (model.Service, bool) {
+	return instances.get(sname, instance)
+}
+
+// Deploy admits the instance and deploys it in background, as the deploy command does.
+// The outcome of the deployment is reported as the instance status.
+func Deploy(service model.Service) error {
+	if _, fresh := instances.track(service); !fresh {
+		return ErrAlreadyDeployed
+	}
+	reserved, rejection := admission.GetController().Admit(service)
+	if rejection != nil {
+		service.Status = model.SERVICE_FAILED
+		service.StatusReason = rejection.Reason
+		service.StatusDetail = rejection.Detail
+		ReportStatus(service)
+		return fmt.Errorf("deployment rejected, %s: %s", rejection.Reason, rejection.Detail)
+	}
+	go Run(context.Background(), service, reserved, time.Now())
+	return nil
+}
+
+// Undeploy stops the instance and releases its resources, as the delete command does
+func Undeploy(sname string, instance int) error {
+	service, deployed := instances.get(sname, instance)
+	if !deployed {
+		return ErrNotDeployed
+	}
+	if err := virtualization.GetRuntime(model.RuntimeType(service.Runtime)).Undeploy(sname, instance); err != nil {
+		return err
+	}
+	service.Status = model.SERVICE_UNDEPLOYED
+	service.StatusReason = ""
+	service.StatusDetail = ""
+	ReportStatusAndReleaseResources(service)
+	return nil
+}
+
+// Pause freezes the instance, which keeps its status and resources
+func Pause(sname string, instance int) error {
+	return pauseOrResume(sname, instance, model.OPERATOR_PAUSED, virtualization.RuntimePauser.Pause)
+}
+
+// Resume lets a paused instance continue
+func Resume(sname string, instance int) error {
+	return pauseOrResume(sname, instance, model.OPERATOR_RESUMED, virtualization.RuntimePauser.Resume)
+}
+
+func pauseOrResume(sname string, instance int, reason string, operation func(virtualization.RuntimePauser, string, int) error) error {
+	service, deployed := instances.get(sname, instance)
+	if !deployed {
+		return ErrNotDeployed
+	}
+	pauser, supported := virtualization.GetRuntime(model.RuntimeType(service.Runtime)).(virtualization.RuntimePauser)
+	if !supported {
+		return ErrPauseUnsupported
+	}
+	if err := operation(pauser, sname, instance); err != nil {
+		return err
+	}
+	service.StatusReason = reason
+	service.StatusDetail = ""
+	ReportStatus(service)
+	return nil
+}
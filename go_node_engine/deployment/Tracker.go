@@ -0,0 +1,139 @@
+package deployment
+
+import (
+	"go_node_engine/model"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// instanceTracker keeps the spec and last reported status of the instances deployed by the node,
+// so that a deploy repeated with an identical spec is answered with the current status instead of failing.
+type instanceTracker struct {
+	instances map[string]model.Service
+	updating  map[string]bool
+	lock      sync.Mutex
+}
+
+var instances = newInstanceTracker()
+
+func newInstanceTracker() *instanceTracker {
+	return &instanceTracker{instances: make(map[string]model.Service), updating: make(map[string]bool)}
+}
+
+// Track records an instance being deployed. Returns the tracked instance and false if it is already deployed.
+func Track(service model.Service) (model.Service, bool) {
+	return instances.track(service)
+}
+
+// BeginUpdate marks a tracked instance as being updated. Returns false if not deployed or already being updated.
+func BeginUpdate(sname string, instance int) (model.Service, bool) {
+	return instances.beginUpdate(sname, instance)
+}
+
+// EndUpdate records the spec the instance runs after an update, unless it is gone in the meantime
+func EndUpdate(service model.Service) {
+	instances.endUpdate(service)
+}
+
+func (t *instanceTracker) track(service model.Service) (model.Service, bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	key := model.InstanceKey(service.Sname, service.Instance)
+	if current, found := t.instances[key]; found {
+		return current, false
+	}
+	service.Status = model.SERVICE_CREATING
+	t.instances[key] = service
+	return service, true
+}
+
+func (t *instanceTracker) get(sname string, instance int) (model.Service, bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	service, found := t.instances[model.InstanceKey(sname, instance)]
+	return service, found
+}
+
+// list returns the tracked instances, sorted by service and instance
+func (t *instanceTracker) list() []model.Service {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	tracked := make([]model.Service, 0, len(t.instances))
+	for _, service := range t.instances {
+		tracked = append(tracked, service)
+	}
+	sort.Slice(tracked, func(i, j int) bool {
+		if tracked[i].Sname != tracked[j].Sname {
+			return tracked[i].Sname < tracked[j].Sname
+		}
+		return tracked[i].Instance < tracked[j].Instance
+	})
+	return tracked
+}
+
+// update records the status of a tracked instance, forgetting it once it is gone
+func (t *instanceTracker) update(service model.Service) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	key := model.InstanceKey(service.Sname, service.Instance)
+	current, found := t.instances[key]
+	if !found {
+		return
+	}
+	if Gone(service) {
+		delete(t.instances, key)
+		return
+	}
+	current.Status = service.Status
+	current.StatusReason = service.StatusReason
+	current.StatusDetail = service.StatusDetail
+	t.instances[key] = current
+}
+
+func (t *instanceTracker) beginUpdate(sname string, instance int) (model.Service, bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	key := model.InstanceKey(sname, instance)
+	current, found := t.instances[key]
+	if !found || t.updating[key] {
+		return current, false
+	}
+	t.updating[key] = true
+	return current, true
+}
+
+func (t *instanceTracker) endUpdate(service model.Service) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	key := model.InstanceKey(service.Sname, service.Instance)
+	delete(t.updating, key)
+	if current, found := t.instances[key]; found {
+		service.Status = current.Status
+		service.StatusReason = current.StatusReason
+		service.StatusDetail = current.StatusDetail
+		t.instances[key] = service
+	}
+}
+
+// Gone tells if the status is a final one, the instance no longer runs and holds no resources
+func Gone(service model.Service) bool {
+	switch service.Status {
+	case model.SERVICE_FAILED, model.SERVICE_DEAD, model.SERVICE_COMPLETED, model.SERVICE_UNDEPLOYED:
+		return true
+	}
+	return false
+}
+
+// SameSpec compares two deployment requests, ignoring the status fields
+func SameSpec(a model.Service, b model.Service) bool {
+	return reflect.DeepEqual(specOf(a), specOf(b))
+}
+
+func specOf(service model.Service) model.Service {
+	service.Status = ""
+	service.StatusReason = ""
+	service.StatusDetail = ""
+	service.Pid = 0
+	return service
+}
@@ -0,0 +1,46 @@
+package deployment
+
+import (
+	"go_node_engine/model"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestInstanceTracker(t *testing.T) {
+	tracker := newInstanceTracker()
+	service := model.Service{Sname: "app", Instance: 0, Image: "nginx", Env: []string{"A=1"}}
+
+	_, fresh := tracker.track(service)
+	assert.Assert(t, fresh)
+	tracker.update(model.Service{Sname: "app", Instance: 0, Status: model.SERVICE_CREATED})
+
+	current, fresh := tracker.track(service)
+	assert.Assert(t, !fresh)
+	assert.Equal(t, current.Status, model.SERVICE_CREATED)
+	assert.Assert(t, SameSpec(current, service))
+	changed := service
+	changed.Image = "nginx:2"
+	assert.Assert(t, !SameSpec(current, changed))
+
+	_, updatable := tracker.beginUpdate("app", 0)
+	assert.Assert(t, updatable)
+	_, updatable = tracker.beginUpdate("app", 0)
+	assert.Assert(t, !updatable)
+	tracker.endUpdate(changed)
+	current, _ = tracker.get("app", 0)
+	assert.Equal(t, current.Image, "nginx:2")
+	assert.Equal(t, current.Status, model.SERVICE_CREATED)
+
+	tracker.track(model.Service{Sname: "app", Instance: 2})
+	tracker.track(model.Service{Sname: "api", Instance: 1})
+	listed := tracker.list()
+	assert.Equal(t, len(listed), 3)
+	assert.Equal(t, listed[0].Sname, "api")
+	assert.Equal(t, listed[1].Instance, 0)
+	assert.Equal(t, listed[2].Instance, 2)
+
+	tracker.update(model.Service{Sname: "app", Instance: 0, Status: model.SERVICE_UNDEPLOYED})
+	_, deployed := tracker.get("app", 0)
+	assert.Assert(t, !deployed)
+}
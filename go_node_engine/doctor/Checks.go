@@ -0,0 +1,254 @@
+package doctor
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"go_node_engine/config"
+	"go_node_engine/model"
+	"go_node_engine/requests"
+	"go_node_engine/standalone"
+	"go_node_engine/virtualization"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/containerd/containerd"
+	"github.com/shirou/gopsutil/disk"
+)
+
+// Free disk space below which the disk space check warns, or fails, in MB
+const (
+	DISK_WARN_MB = 2048
+	DISK_FAIL_MB = 512
+)
+
+// KVM_DEVICE is opened by qemu to run the unikernels with hardware acceleration
+const KVM_DEVICE = "/dev/kvm"
+
+func checkConfiguration(cfg config.Config) Result {
+	if err := cfg.Validate(); err != nil {
+		return fail(err.Error(), "fix the configuration file or the NODE_ENGINE_* variables, see NodeEngine config print")
+	}
+	return pass("valid")
+}
+
+func checkArchitecture(config.Config) Result {
+	detail := fmt.Sprintf("%s, %d cores, cgroup %s", runtime.GOARCH, runtime.NumCPU(), model.CgroupVersion())
+	if runtime.GOARCH != "amd64" && runtime.GOARCH != "arm64" {
+		return warn(detail, "only amd64 and arm64 run unikernels, the deployed images must be built for "+runtime.GOARCH)
+	}
+	return pass(detail)
+}
+
+func checkLogDirectory(cfg config.Config) Result {
+	dir := cfg.Node.LogDirectory
+	if err := writableDirectory(dir, false); err != nil {
+		return fail(err.Error(), fmt.Sprintf("create %s writable by the NodeEngine user, or set node.log_directory (--logs)", dir))
+	}
+	return pass(dir + " is writable")
+}
+
+func checkStateDirectory(cfg config.Config) Result {
+	dir := cfg.Node.StateDirectory
+	if err := writableDirectory(dir, true); err != nil {
+		return fail(err.Error(), fmt.Sprintf("make %s writable by the NodeEngine user, or set node.state_directory", dir))
+	}
+	return pass(dir + " is writable")
+}
+
+func checkUnikernelDirectory(cfg config.Config) Result {
+	if !cfg.Runtime.Unikernel {
+		return skip("unikernel runtime disabled")
+	}
+	dir := cfg.Runtime.UnikernelDirectory
+	if err := writableDirectory(dir, true); err != nil {
+		return fail(err.Error(), fmt.Sprintf("make %s writable by the NodeEngine user, or set runtime.unikernel_directory", dir))
+	}
+	return pass(dir + " is writable")
+}
+
+func checkDiskSpace(cfg config.Config) Result {
+	usage, err := disk.Usage("/")
+	if err != nil {
+		return warn(fmt.Sprintf("unable to read the disk usage: %v", err), "")
+	}
+	free := usage.Free >> 20
+	detail := fmt.Sprintf("%d MB free of %d MB", free, usage.Total>>20)
+	hint := "free some disk space, e.g. remove the unused images with ctr -n " + cfg.Runtime.Namespace + " images rm"
+	switch {
+	case free < DISK_FAIL_MB:
+		return fail(detail, hint)
+	case free < DISK_WARN_MB:
+		return warn(detail, hint)
+	}
+	return pass(detail)
+}
+
+func checkContainerd(cfg config.Config) Result {
+	socket := cfg.Runtime.ContainerdSocket
+	hint := "install and start containerd (systemctl enable --now containerd), or set runtime.containerd_socket"
+	if _, err := os.Stat(socket); err != nil {
+		return fail(fmt.Sprintf("socket %s not found", socket), hint)
+	}
+	client, err := containerd.New(socket, containerd.WithTimeout(CHECK_TIMEOUT))
+	if errors.Is(err, os.ErrPermission) {
+		return fail(err.Error(), "run the NodeEngine as root")
+	}
+	if err != nil {
+		return fail(err.Error(), hint)
+	}
+	defer client.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), CHECK_TIMEOUT)
+	defer cancel()
+	version, err := client.Version(ctx)
+	if err != nil {
+		return fail(fmt.Sprintf("containerd not answering on %s: %v", socket, err), hint)
+	}
+	return pass(fmt.Sprintf("containerd %s on %s", version.Version, socket))
+}
+
+func checkCgroups(config.Config) Result {
+	hint := "enable the cpu and memory cgroup controllers, e.g. add cgroup_enable=memory to the kernel command line"
+	if model.CgroupVersion() == "v2" {
+		controllers, err := os.ReadFile(filepath.Join(virtualization.CGROUPV2_BASE_MEM, "cgroup.controllers"))
+		if err != nil {
+			return fail(err.Error(), hint)
+		}
+		enabled := strings.Fields(string(controllers))
+		for _, required := range []string{"cpu", "memory"} {
+			if !contains(enabled, required) {
+				return fail(fmt.Sprintf("cgroup v2 without the %s controller", required), hint)
+			}
+		}
+		return pass("cgroup v2, controllers " + strings.Join(enabled, " "))
+	}
+	if _, err := os.Stat(virtualization.CGROUPV1_BASE_MEM); err != nil {
+		return fail(fmt.Sprintf("cgroup v1 memory controller not mounted at %s", virtualization.CGROUPV1_BASE_MEM), hint)
+	}
+	return pass("cgroup v1")
+}
+
+func checkKvm(cfg config.Config) Result {
+	if !cfg.Runtime.Unikernel {
+		return skip("unikernel runtime disabled")
+	}
+	kvm, err := os.OpenFile(KVM_DEVICE, os.O_RDWR, 0)
+	if errors.Is(err, os.ErrNotExist) {
+		return fail(KVM_DEVICE+" not found", "enable the virtualization extensions in the firmware and load the kvm module (modprobe kvm_intel or kvm_amd)")
+	}
+	if err != nil {
+		return fail(err.Error(), "run the NodeEngine as root, or add its user to the kvm group")
+	}
+	_ = kvm.Close()
+	return pass(KVM_DEVICE + " is accessible")
+}
+
+func checkQemu(cfg config.Config) Result {
+	if !cfg.Runtime.Unikernel {
+		return skip("unikernel runtime disabled")
+	}
+	command := virtualization.QemuCommand()
+	path, err := exec.LookPath(command)
+	if err != nil {
+		return fail(command+" not found", "install qemu, e.g. apt install qemu-system, or disable the unikernel runtime")
+	}
+	return pass(path)
+}
+
+func checkNetManager(cfg config.Config) Result {
+	network := cfg.Network
+	if network.NetManagerPort <= 0 {
+		return skip("overlay network disabled")
+	}
+	if cfg.Standalone.Manifest != "" {
+		return skip("no overlay network in standalone mode")
+	}
+	hint := "start the NetManager, or disable the overlay network with network.netmanager_port -1 (--netmanagerPort -1)"
+	transport, address := "tcp", net.JoinHostPort(network.NetManagerHost, fmt.Sprint(network.NetManagerPort))
+	if network.NetManagerSocket != "" {
+		transport, address = "unix", network.NetManagerSocket
+	}
+	conn, err := net.DialTimeout(transport, address, CHECK_TIMEOUT)
+	if err != nil {
+		return fail(fmt.Sprintf("NetManager not listening on %s: %v", address, err), hint)
+	}
+	_ = conn.Close()
+	return pass("listening on " + address)
+}
+
+func checkCluster(cfg config.Config) Result {
+	if cfg.Standalone.Manifest != "" {
+		return skip("standalone mode")
+	}
+	cluster := cfg.Cluster
+	address := net.JoinHostPort(cluster.Address, fmt.Sprint(cluster.Port))
+	conn, err := net.DialTimeout("tcp", address, CHECK_TIMEOUT)
+	if err != nil {
+		return fail(fmt.Sprintf("cluster orchestrator unreachable at %s: %v", address, err),
+			"check cluster.address and cluster.port (--clusterAddr, --clusterPort), the DNS and the firewall")
+	}
+	defer conn.Close()
+	if cluster.Scheme != "https" {
+		return pass("reachable at " + address)
+	}
+	tlsCfg, err := requests.NewTLSConfig(cluster.CaFile, cluster.CertFile, cluster.KeyFile, cluster.ServerName)
+	if err != nil {
+		return fail(err.Error(), "check cluster.ca_file, cluster.cert_file and cluster.key_file")
+	}
+	if tlsCfg.ServerName == "" {
+		tlsCfg.ServerName = cluster.Address
+	}
+	_ = conn.SetDeadline(time.Now().Add(CHECK_TIMEOUT))
+	if err := tls.Client(conn, tlsCfg).Handshake(); err != nil {
+		return fail(fmt.Sprintf("TLS handshake with %s failed: %v", address, err),
+			"check cluster.ca_file, cluster.cert_file, cluster.key_file and cluster.server_name")
+	}
+	return pass("reachable at " + address + " over TLS")
+}
+
+func checkManifest(cfg config.Config) Result {
+	if cfg.Standalone.Manifest == "" {
+		return skip("not in standalone mode")
+	}
+	manifest, err := standalone.LoadManifest(cfg.Standalone.Manifest)
+	if err != nil {
+		return fail(err.Error(), "fix the manifest, or set standalone.manifest (--standalone)")
+	}
+	return pass(fmt.Sprintf("%d services in %s", len(manifest.Services), cfg.Standalone.Manifest))
+}
+
+// writableDirectory checks that a file can be created in the directory. If create is set, a missing directory
+// is fine as long as it can be created, as the NodeEngine creates it at startup.
+func writableDirectory(dir string, create bool) error {
+	stat, err := os.Stat(dir)
+	if errors.Is(err, os.ErrNotExist) && create {
+		return writableDirectory(filepath.Dir(dir), true)
+	}
+	if err != nil {
+		return err
+	}
+	if !stat.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	probe, err := os.CreateTemp(dir, ".node-engine-doctor-")
+	if err != nil {
+		return err
+	}
+	_ = probe.Close()
+	return os.Remove(probe.Name())
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
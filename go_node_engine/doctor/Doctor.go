@@ -0,0 +1,104 @@
+package doctor
+
+import (
+	"go_node_engine/config"
+	"time"
+)
+
+// Status is the outcome of a check
+type Status string
+
+const (
+	// STATUS_PASS the node is ready as far as the check is concerned
+	STATUS_PASS Status = "pass"
+	// STATUS_WARN the NodeEngine starts, but something may not work as expected
+	STATUS_WARN Status = "warn"
+	// STATUS_FAIL the NodeEngine does not start, or cannot deploy anything
+	STATUS_FAIL Status = "fail"
+	// STATUS_SKIP the check does not apply to the configuration, e.g. KVM without the unikernel runtime
+	STATUS_SKIP Status = "skip"
+)
+
+// Exit codes of the doctor command, for provisioning scripts
+const (
+	EXIT_READY     = 0
+	EXIT_NOT_READY = 1
+	EXIT_WARNINGS  = 2
+)
+
+// CHECK_TIMEOUT bounds the checks connecting to a socket or a remote host
+const CHECK_TIMEOUT = 3 * time.Second
+
+// Result is the outcome of a check, with a remediation hint unless it passed
+type Result struct {
+	Check  string `json:"check"`
+	Status Status `json:"status"`
+	Detail string `json:"detail"`
+	Hint   string `json:"hint,omitempty"`
+}
+
+// Check verifies one requirement of the node
+type Check struct {
+	Name string
+	Run  func(cfg config.Config) Result
+}
+
+// Checks are the readiness checks, in the order the NodeEngine needs them at startup
+func Checks() []Check {
+	return []Check{
+		{"configuration", checkConfiguration},
+		{"architecture", checkArchitecture},
+		{"log directory", checkLogDirectory},
+		{"state directory", checkStateDirectory},
+		{"disk space", checkDiskSpace},
+		{"containerd", checkContainerd},
+		{"cgroups", checkCgroups},
+		{"kvm", checkKvm},
+		{"qemu", checkQemu},
+		{"unikernel directory", checkUnikernelDirectory},
+		{"netmanager", checkNetManager},
+		{"cluster", checkCluster},
+		{"standalone manifest", checkManifest},
+	}
+}
+
+// Run performs the checks against the configuration
+func Run(cfg config.Config, checks []Check) []Result {
+	results := make([]Result, 0, len(checks))
+	for _, check := range checks {
+		result := check.Run(cfg)
+		result.Check = check.Name
+		results = append(results, result)
+	}
+	return results
+}
+
+// ExitCode returns EXIT_NOT_READY if any check failed, EXIT_WARNINGS if any warned, EXIT_READY otherwise
+func ExitCode(results []Result) int {
+	code := EXIT_READY
+	for _, result := range results {
+		switch result.Status {
+		case STATUS_FAIL:
+			return EXIT_NOT_READY
+		case STATUS_WARN:
+			code = EXIT_WARNINGS
+		}
+	}
+	return code
+}
+
+func pass(detail string) Result {
+	return Result{Status: STATUS_PASS, Detail: detail}
+}
+
+func warn(detail string, hint string) Result {
+	return Result{Status: STATUS_WARN, Detail: detail, Hint: hint}
+}
+
+func fail(detail string, hint string) Result {
+	return Result{Status: STATUS_FAIL, Detail: detail, Hint: hint}
+}
+
+func skip(detail string) Result {
+	return Result{Status: STATUS_SKIP, Detail: detail}
+}
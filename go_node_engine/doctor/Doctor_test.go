@@ -0,0 +1,99 @@
+package doctor
+
+import (
+	"go_node_engine/config"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestExitCode(t *testing.T) {
+	assert.Equal(t, ExitCode(nil), EXIT_READY)
+	assert.Equal(t, ExitCode([]Result{pass(""), skip("")}), EXIT_READY)
+	assert.Equal(t, ExitCode([]Result{pass(""), warn("", "")}), EXIT_WARNINGS)
+	assert.Equal(t, ExitCode([]Result{warn("", ""), fail("", ""), pass("")}), EXIT_NOT_READY)
+}
+
+func TestRun(t *testing.T) {
+	checks := []Check{
+		{"first", func(config.Config) Result { return pass("ok") }},
+		{"second", func(config.Config) Result { return fail("broken", "fix it") }},
+	}
+	results := Run(config.Default(), checks)
+	assert.DeepEqual(t, results, []Result{
+		{Check: "first", Status: STATUS_PASS, Detail: "ok"},
+		{Check: "second", Status: STATUS_FAIL, Detail: "broken", Hint: "fix it"},
+	})
+}
+
+func TestDirectories(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.Default()
+	cfg.Node.LogDirectory = filepath.Join(dir, "logs")
+	cfg.Node.StateDirectory = filepath.Join(dir, "state", "node-engine")
+
+	// the NodeEngine creates the state directory, not the log directory
+	assert.Equal(t, checkLogDirectory(cfg).Status, STATUS_FAIL)
+	assert.Equal(t, checkStateDirectory(cfg).Status, STATUS_PASS)
+	assert.NilError(t, os.Mkdir(cfg.Node.LogDirectory, 0755))
+	assert.Equal(t, checkLogDirectory(cfg).Status, STATUS_PASS)
+
+	file := filepath.Join(dir, "file")
+	assert.NilError(t, os.WriteFile(file, nil, 0644))
+	assert.ErrorContains(t, writableDirectory(file, true), "not a directory")
+}
+
+func TestUnikernelDisabled(t *testing.T) {
+	cfg := config.Default()
+	cfg.Runtime.Unikernel = false
+	for _, check := range []func(config.Config) Result{checkKvm, checkQemu, checkUnikernelDirectory} {
+		assert.Equal(t, check(cfg).Status, STATUS_SKIP)
+	}
+}
+
+func TestConnectivity(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NilError(t, err)
+	address := listener.Addr().(*net.TCPAddr)
+	cfg := config.Default()
+	cfg.Cluster.Address = "127.0.0.1"
+	cfg.Cluster.Port = address.Port
+	cfg.Network.NetManagerHost = "127.0.0.1"
+	cfg.Network.NetManagerPort = address.Port
+	cfg.Network.NetManagerSocket = ""
+
+	assert.Equal(t, checkCluster(cfg).Status, STATUS_PASS)
+	assert.Equal(t, checkNetManager(cfg).Status, STATUS_PASS)
+
+	assert.NilError(t, listener.Close())
+	result := checkCluster(cfg)
+	assert.Equal(t, result.Status, STATUS_FAIL)
+	assert.Assert(t, result.Hint != "")
+	assert.Equal(t, checkNetManager(cfg).Status, STATUS_FAIL)
+
+	cfg.Network.NetManagerPort = -1
+	assert.Equal(t, checkNetManager(cfg).Status, STATUS_SKIP)
+}
+
+func TestContainerdMissing(t *testing.T) {
+	cfg := config.Default()
+	cfg.Runtime.ContainerdSocket = filepath.Join(t.TempDir(), "containerd.sock")
+	result := checkContainerd(cfg)
+	assert.Equal(t, result.Status, STATUS_FAIL)
+	assert.Assert(t, result.Hint != "")
+}
+
+func TestStandalone(t *testing.T) {
+	cfg := config.Default()
+	assert.Equal(t, checkManifest(cfg).Status, STATUS_SKIP)
+
+	cfg.Standalone.Manifest = filepath.Join(t.TempDir(), "manifest.yaml")
+	assert.Equal(t, checkCluster(cfg).Status, STATUS_SKIP)
+	assert.Equal(t, checkNetManager(cfg).Status, STATUS_SKIP)
+	assert.Equal(t, checkManifest(cfg).Status, STATUS_FAIL)
+	assert.NilError(t, os.WriteFile(cfg.Standalone.Manifest, []byte("services:\n  - {job_name: app, image: nginx}\n"), 0644))
+	assert.Equal(t, checkManifest(cfg).Status, STATUS_PASS)
+}
@@ -0,0 +1,344 @@
+package e2e
+
+import (
+	"errors"
+	"fmt"
+	"go_node_engine/mqtt"
+	"net"
+	"sync"
+
+	"github.com/eclipse/paho.golang/packets"
+)
+
+// BROKER_BUFFER is the number of messages a subscription of the test keeps until received
+const BROKER_BUFFER = 1000
+
+// Broker is an in-process MQTT 5 broker listening on a random local port. It supports QoS 0 and 1, wildcards,
+// retained and will messages and a single set of credentials, without persistent sessions. The test publishes
+// and subscribes through the broker itself.
+type Broker struct {
+	listener      net.Listener
+	clients       map[*brokerClient]bool
+	retained      map[string]*packets.Publish
+	subscriptions []*subscription
+	username      string
+	password      string
+	lock          sync.Mutex
+}
+
+// Message is a message published to the broker
+type Message struct {
+	Topic       string
+	Payload     []byte
+	ContentType string
+	// SchemaVersion is the schema_version user property of the message, if any
+	SchemaVersion string
+	Retained      bool
+}
+
+type subscription struct {
+	filter   string
+	messages chan Message
+}
+
+type brokerClient struct {
+	conn     net.Conn
+	id       string
+	will     *packets.Publish
+	filters  map[string]byte
+	packetID uint16
+}
+
+// NewBroker starts a broker on a random local port
+func NewBroker() (*Broker, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	b := &Broker{
+		listener: listener,
+		clients:  make(map[*brokerClient]bool),
+		retained: make(map[string]*packets.Publish),
+	}
+	go b.accept()
+	return b, nil
+}
+
+// Port returns the port of the broker, as handed out at handshake
+func (b *Broker) Port() string {
+	return fmt.Sprint(b.listener.Addr().(*net.TCPAddr).Port)
+}
+
+// RequireCredentials refuses the clients connecting without the given username and password
+func (b *Broker) RequireCredentials(username string, password string) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.username = username
+	b.password = password
+}
+
+// Publish delivers a message to the subscribed clients, like a client of the broker would
+func (b *Broker) Publish(topic string, payload []byte, contentType string) {
+	b.PublishVersion(topic, payload, contentType, "")
+}
+
+// PublishVersion publishes a message tagged with the given schema version, as a cluster orchestrator does
+func (b *Broker) PublishVersion(topic string, payload []byte, contentType string, schemaVersion string) {
+	properties := &packets.Properties{ContentType: contentType}
+	if schemaVersion != "" {
+		properties.User = []packets.User{{Key: mqtt.PROPERTY_SCHEMA_VERSION, Value: schemaVersion}}
+	}
+	b.route(&packets.Publish{Topic: topic, Payload: payload, QoS: 1, Properties: properties})
+}
+
+// Subscribe returns the messages published on the topics matching the filter, starting with the retained ones
+func (b *Broker) Subscribe(filter string) <-chan Message {
+	s := &subscription{filter: filter, messages: make(chan Message, BROKER_BUFFER)}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.subscriptions = append(b.subscriptions, s)
+	for topic, retained := range b.retained {
+		if mqtt.TopicMatches(filter, topic) {
+			s.deliver(retained, true)
+		}
+	}
+	return s.messages
+}
+
+// Connected returns the ID of the connected clients
+func (b *Broker) Connected() []string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	ids := make([]string, 0, len(b.clients))
+	for client := range b.clients {
+		ids = append(ids, client.id)
+	}
+	return ids
+}
+
+// DropClients closes the connection of every client without a disconnect, as if the network failed.
+// Their will messages are published.
+func (b *Broker) DropClients() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for client := range b.clients {
+		_ = client.conn.Close()
+	}
+}
+
+// Close stops the broker and closes the connection of every client, without publishing their will messages
+func (b *Broker) Close() error {
+	err := b.listener.Close()
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for client := range b.clients {
+		client.will = nil
+		_ = client.conn.Close()
+	}
+	return err
+}
+
+func (b *Broker) accept() {
+	for {
+		conn, err := b.listener.Accept()
+		if err != nil {
+			return
+		}
+		go b.serve(packets.NewThreadSafeConn(conn))
+	}
+}
+
+// serve handles a client connection from the connect to the disconnect
+func (b *Broker) serve(conn net.Conn) {
+	defer conn.Close()
+	client, err := b.connect(conn)
+	if err != nil {
+		return
+	}
+	defer b.disconnect(client)
+	for {
+		packet, err := packets.ReadPacket(conn)
+		if err != nil {
+			return
+		}
+		switch content := packet.Content.(type) {
+		case *packets.Publish:
+			switch content.QoS {
+			case 1:
+				_, err = (&packets.Puback{PacketID: content.PacketID, Properties: &packets.Properties{}}).WriteTo(conn)
+			case 2:
+				_, err = (&packets.Pubrec{PacketID: content.PacketID, Properties: &packets.Properties{}}).WriteTo(conn)
+			}
+			b.route(content)
+		case *packets.Pubrel:
+			_, err = (&packets.Pubcomp{PacketID: content.PacketID, Properties: &packets.Properties{}}).WriteTo(conn)
+		case *packets.Subscribe:
+			err = b.subscribe(client, content)
+		case *packets.Unsubscribe:
+			b.lock.Lock()
+			reasons := make([]byte, 0, len(content.Topics))
+			for _, filter := range content.Topics {
+				delete(client.filters, filter)
+				reasons = append(reasons, packets.UnsubackSuccess)
+			}
+			b.lock.Unlock()
+			_, err = (&packets.Unsuback{PacketID: content.PacketID, Reasons: reasons, Properties: &packets.Properties{}}).WriteTo(conn)
+		case *packets.Pingreq:
+			_, err = packets.NewControlPacket(packets.PINGRESP).WriteTo(conn)
+		case *packets.Disconnect:
+			b.lock.Lock()
+			client.will = nil
+			b.lock.Unlock()
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// connect answers the connect packet, registering the client if accepted
+func (b *Broker) connect(conn net.Conn) (*brokerClient, error) {
+	packet, err := packets.ReadPacket(conn)
+	if err != nil {
+		return nil, err
+	}
+	connect, ok := packet.Content.(*packets.Connect)
+	if !ok {
+		return nil, errors.New("expected a connect packet")
+	}
+	connack := &packets.Connack{Properties: &packets.Properties{}}
+	b.lock.Lock()
+	username, password := b.username, b.password
+	b.lock.Unlock()
+	switch {
+	case connect.ProtocolVersion != 5:
+		connack.ReasonCode = packets.ConnackUnsupportedProtocolVersion
+	case username != "" && (connect.Username != username || string(connect.Password) != password):
+		connack.ReasonCode = packets.ConnackBadUsernameOrPassword
+	}
+	if _, err := connack.WriteTo(conn); err != nil || connack.ReasonCode != packets.ConnackSuccess {
+		return nil, fmt.Errorf("connection refused, reason code %d", connack.ReasonCode)
+	}
+
+	client := &brokerClient{conn: conn, id: connect.ClientID, filters: make(map[string]byte)}
+	if connect.WillFlag {
+		client.will = &packets.Publish{
+			Topic:      connect.WillTopic,
+			Payload:    connect.WillMessage,
+			QoS:        connect.WillQOS,
+			Retain:     connect.WillRetain,
+			Properties: connect.WillProperties,
+		}
+	}
+	b.lock.Lock()
+	b.clients[client] = true
+	b.lock.Unlock()
+	return client, nil
+}
+
+// disconnect forgets the client, publishing its will message unless it disconnected gracefully
+func (b *Broker) disconnect(client *brokerClient) {
+	b.lock.Lock()
+	delete(b.clients, client)
+	will := client.will
+	b.lock.Unlock()
+	if will != nil {
+		b.route(will)
+	}
+}
+
+// subscribe grants the subscriptions up to QoS 1, then sends the retained messages matching them
+func (b *Broker) subscribe(client *brokerClient, request *packets.Subscribe) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	reasons := make([]byte, 0, len(request.Subscriptions))
+	for filter, options := range request.Subscriptions {
+		qos := options.QoS
+		if qos > 1 {
+			qos = 1
+		}
+		client.filters[filter] = qos
+		reasons = append(reasons, qos)
+	}
+	if _, err := (&packets.Suback{PacketID: request.PacketID, Reasons: reasons, Properties: &packets.Properties{}}).WriteTo(client.conn); err != nil {
+		return err
+	}
+	for topic, retained := range b.retained {
+		for filter, qos := range request.Subscriptions {
+			if mqtt.TopicMatches(filter, topic) {
+				b.send(client, retained, qos.QoS, true)
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// route keeps the message if retained, then delivers it to every matching client and subscription of the test
+func (b *Broker) route(publish *packets.Publish) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if publish.Retain {
+		if len(publish.Payload) == 0 {
+			delete(b.retained, publish.Topic)
+		} else {
+			b.retained[publish.Topic] = publish
+		}
+	}
+	for client := range b.clients {
+		granted, matched := -1, false
+		for filter, qos := range client.filters {
+			if mqtt.TopicMatches(filter, publish.Topic) && int(qos) > granted {
+				granted, matched = int(qos), true
+			}
+		}
+		if matched {
+			b.send(client, publish, byte(granted), false)
+		}
+	}
+	for _, s := range b.subscriptions {
+		if mqtt.TopicMatches(s.filter, publish.Topic) {
+			s.deliver(publish, false)
+		}
+	}
+}
+
+// send writes the message to the client, without waiting for the acknowledgement. Called with the lock held.
+func (b *Broker) send(client *brokerClient, publish *packets.Publish, qos byte, retained bool) {
+	if publish.QoS < qos {
+		qos = publish.QoS
+	}
+	if qos > 1 {
+		qos = 1
+	}
+	delivered := &packets.Publish{Topic: publish.Topic, Payload: publish.Payload, QoS: qos, Retain: retained, Properties: publish.Properties}
+	if qos > 0 {
+		client.packetID++
+		if client.packetID == 0 {
+			client.packetID++
+		}
+		delivered.PacketID = client.packetID
+	}
+	if delivered.Properties == nil {
+		delivered.Properties = &packets.Properties{}
+	}
+	_, _ = delivered.WriteTo(client.conn)
+}
+
+// deliver queues the message for the test, dropping it if the test does not keep up
+func (s *subscription) deliver(publish *packets.Publish, retained bool) {
+	message := Message{Topic: publish.Topic, Payload: publish.Payload, Retained: retained}
+	if publish.Properties != nil {
+		message.ContentType = publish.Properties.ContentType
+		for _, property := range publish.Properties.User {
+			if property.Key == mqtt.PROPERTY_SCHEMA_VERSION {
+				message.SchemaVersion = property.Value
+			}
+		}
+	}
+	select {
+	case s.messages <- message:
+	default:
+	}
+}
@@ -0,0 +1,50 @@
+package e2e
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eclipse/paho.golang/packets"
+	"gotest.tools/assert"
+)
+
+func retainedMessage(topic string, payload string) *packets.Publish {
+	return &packets.Publish{Topic: topic, Payload: []byte(payload), QoS: 1, Retain: true}
+}
+
+func receive(t *testing.T, messages <-chan Message) Message {
+	select {
+	case message := <-messages:
+		return message
+	case <-time.After(time.Second):
+		t.Fatal("no message received")
+	}
+	return Message{}
+}
+
+func TestBrokerRouting(t *testing.T) {
+	broker, err := NewBroker()
+	assert.NilError(t, err)
+	defer broker.Close()
+
+	nodes := broker.Subscribe("nodes/+/presence")
+	all := broker.Subscribe("nodes/#")
+	broker.route(retainedMessage("nodes/n1/presence", "online"))
+	broker.Publish("nodes/n1/job", []byte(`{}`), "application/json")
+
+	assert.Equal(t, string(receive(t, nodes).Payload), "online")
+	assert.Equal(t, receive(t, all).Topic, "nodes/n1/presence")
+	job := receive(t, all)
+	assert.Equal(t, job.Topic, "nodes/n1/job")
+	assert.Equal(t, job.ContentType, "application/json")
+
+	// the retained messages are delivered to the later subscriptions, until cleared
+	retained := receive(t, broker.Subscribe("nodes/n1/presence"))
+	assert.Assert(t, retained.Retained)
+	broker.route(retainedMessage("nodes/n1/presence", ""))
+	select {
+	case message := <-broker.Subscribe("nodes/n1/presence"):
+		t.Fatalf("unexpected retained message %v", message)
+	default:
+	}
+}
@@ -0,0 +1,164 @@
+package e2e
+
+import (
+	"encoding/json"
+	"go_node_engine/model"
+	"go_node_engine/requests"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+)
+
+// Cluster is a stub of the cluster orchestrator HTTP API, answering the handshake of the node with the node ID,
+// the port of the broker and, if set, the broker credentials
+type Cluster struct {
+	server     *httptest.Server
+	answer     requests.HandshakeAnswer
+	failStatus int
+	handshakes []Handshake
+	lock       sync.Mutex
+}
+
+// Handshake is a handshake request received by the cluster stub
+type Handshake struct {
+	model.Node
+	MqttUsername string `json:"mqtt_username"`
+	Token        string `json:"token"`
+}
+
+// NewCluster starts a cluster stub on a random local port, handing out the given node ID and broker port
+func NewCluster(nodeID string, mqttPort string) *Cluster {
+	c := &Cluster{answer: requests.HandshakeAnswer{NodeId: nodeID, MqttPort: mqttPort}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/node/register", c.register)
+	c.server = httptest.NewServer(mux)
+	return c
+}
+
+// Address returns the host of the cluster stub, without port
+func (c *Cluster) Address() string {
+	return c.server.Listener.Addr().(*net.TCPAddr).IP.String()
+}
+
+// Port returns the port of the cluster stub
+func (c *Cluster) Port() int {
+	return c.server.Listener.Addr().(*net.TCPAddr).Port
+}
+
+// SetCredentials hands out the broker credentials at the next handshakes, empty hands out none
+func (c *Cluster) SetCredentials(username string, password string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.answer.MqttUsername = username
+	c.answer.MqttPassword = password
+}
+
+// FailHandshakes answers the next handshakes with the given HTTP status, 0 answers them again
+func (c *Cluster) FailHandshakes(status int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.failStatus = status
+}
+
+// Handshakes returns the handshake requests received, oldest first
+func (c *Cluster) Handshakes() []Handshake {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return append([]Handshake{}, c.handshakes...)
+}
+
+// Close stops the cluster stub
+func (c *Cluster) Close() {
+	c.server.Close()
+}
+
+func (c *Cluster) register(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	handshake := Handshake{}
+	if err := json.NewDecoder(r.Body).Decode(&handshake); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	c.lock.Lock()
+	c.handshakes = append(c.handshakes, handshake)
+	answer, failStatus := c.answer, c.failStatus
+	c.lock.Unlock()
+	if failStatus != 0 {
+		w.WriteHeader(failStatus)
+		return
+	}
+	writeJSON(w, answer)
+}
+
+// NetManager is a stub of the NetManager HTTP API, accepting and recording every request
+type NetManager struct {
+	server   *httptest.Server
+	requests []NetManagerRequest
+	lock     sync.Mutex
+}
+
+// NetManagerRequest is a request received by the NetManager stub, e.g. container/deploy
+type NetManagerRequest struct {
+	Path string
+	Body map[string]interface{}
+}
+
+// NewNetManager starts a NetManager stub on a random local port
+func NewNetManager() *NetManager {
+	n := &NetManager{}
+	n.server = httptest.NewServer(http.HandlerFunc(n.handle))
+	return n
+}
+
+// Host returns the host of the NetManager stub
+func (n *NetManager) Host() string {
+	return n.server.Listener.Addr().(*net.TCPAddr).IP.String()
+}
+
+// Port returns the port of the NetManager stub
+func (n *NetManager) Port() int {
+	return n.server.Listener.Addr().(*net.TCPAddr).Port
+}
+
+// Requests returns the requests received, oldest first
+func (n *NetManager) Requests() []NetManagerRequest {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	return append([]NetManagerRequest{}, n.requests...)
+}
+
+// Paths returns the paths of the requests received, oldest first
+func (n *NetManager) Paths() []string {
+	paths := make([]string, 0)
+	for _, request := range n.Requests() {
+		paths = append(paths, request.Path)
+	}
+	return paths
+}
+
+// Close stops the NetManager stub
+func (n *NetManager) Close() {
+	n.server.Close()
+}
+
+func (n *NetManager) handle(w http.ResponseWriter, r *http.Request) {
+	body := make(map[string]interface{})
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	n.lock.Lock()
+	n.requests = append(n.requests, NetManagerRequest{Path: strings.TrimPrefix(r.URL.Path, "/"), Body: body})
+	n.lock.Unlock()
+	writeJSON(w, map[string]string{})
+}
+
+func writeJSON(w http.ResponseWriter, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,172 @@
+package e2e
+
+import (
+	"context"
+	"fmt"
+	"go_node_engine/model"
+	"go_node_engine/requests"
+	"sort"
+	"sync"
+	"time"
+)
+
+// FakeRuntime runs no workload: the instances are only recorded, and their failures and exits are scripted by
+// the test. It replaces a built-in runtime through virtualization.RegisterRuntime. As the container runtime,
+// it attaches the instances to the NetManager when the overlay network is enabled.
+type FakeRuntime struct {
+	instances      map[string]*fakeInstance
+	deployErrors   map[string]error
+	undeployErrors map[string]error
+	deployDelay    time.Duration
+	lock           sync.Mutex
+}
+
+type fakeInstance struct {
+	service model.Service
+	usage   model.Resources
+	notify  func(service model.Service)
+}
+
+// NewFakeRuntime returns a fake runtime without instances
+func NewFakeRuntime() *FakeRuntime {
+	return &FakeRuntime{
+		instances:      make(map[string]*fakeInstance),
+		deployErrors:   make(map[string]error),
+		undeployErrors: make(map[string]error),
+	}
+}
+
+// Deploy records the instance as running, unless a failure is scripted for its service
+func (r *FakeRuntime) Deploy(ctx context.Context, service model.Service, statusChangeNotificationHandler func(service model.Service)) error {
+	r.lock.Lock()
+	delay, err := r.deployDelay, r.deployErrors[service.Sname]
+	r.lock.Unlock()
+	select {
+	case <-time.After(delay):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if err != nil {
+		return err
+	}
+	if model.GetCachedNodeInfo().Overlay {
+		if err := requests.AttachNetworkToTask(0, service.Sname, service.Instance, service.Ports); err != nil {
+			return fmt.Errorf("unable to attach the network: %v", err)
+		}
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	key := model.InstanceKey(service.Sname, service.Instance)
+	if _, found := r.instances[key]; found {
+		return fmt.Errorf("instance %s already running", key)
+	}
+	r.instances[key] = &fakeInstance{
+		service: service,
+		usage:   model.Resources{Sname: service.Sname, Instance: service.Instance, Runtime: service.Runtime},
+		notify:  statusChangeNotificationHandler,
+	}
+	return nil
+}
+
+// Undeploy forgets the instance, unless a failure is scripted for its service
+func (r *FakeRuntime) Undeploy(sname string, instance int) error {
+	r.lock.Lock()
+	key := model.InstanceKey(sname, instance)
+	if err := r.undeployErrors[sname]; err != nil {
+		r.lock.Unlock()
+		return err
+	}
+	if _, found := r.instances[key]; !found {
+		r.lock.Unlock()
+		return fmt.Errorf("instance %s not running", key)
+	}
+	delete(r.instances, key)
+	r.lock.Unlock()
+	if model.GetCachedNodeInfo().Overlay {
+		_ = requests.DetachNetworkFromTask(sname, instance)
+	}
+	return nil
+}
+
+// ResourceMonitoring reports the usage of the running instances, as set by SetUsage, every cycle
+func (r *FakeRuntime) ResourceMonitoring(every func() time.Duration, notifyHandler func(res []model.Resources)) {
+	for {
+		<-time.After(every())
+		r.lock.Lock()
+		usage := make([]model.Resources, 0, len(r.instances))
+		for _, instance := range r.instances {
+			usage = append(usage, instance.usage)
+		}
+		r.lock.Unlock()
+		notifyHandler(usage)
+	}
+}
+
+// FailDeploy makes the deployments of the service fail with err, nil deploys them again
+func (r *FakeRuntime) FailDeploy(sname string, err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.deployErrors[sname] = err
+}
+
+// FailUndeploy makes the undeployments of the service fail with err, nil undeploys them again
+func (r *FakeRuntime) FailUndeploy(sname string, err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.undeployErrors[sname] = err
+}
+
+// SetDeployDelay makes every deployment take the given time, e.g. to simulate an image pull
+func (r *FakeRuntime) SetDeployDelay(delay time.Duration) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.deployDelay = delay
+}
+
+// SetUsage sets the resources reported for the running instance
+func (r *FakeRuntime) SetUsage(sname string, instance int, cpu float64, memory float64) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	running, found := r.instances[model.InstanceKey(sname, instance)]
+	if !found {
+		return fmt.Errorf("instance %s not running", model.InstanceKey(sname, instance))
+	}
+	running.usage.Cpu = cpu
+	running.usage.Memory = memory
+	return nil
+}
+
+// Exit ends the running instance with the given status, e.g. model.SERVICE_DEAD, as if its process ended
+func (r *FakeRuntime) Exit(sname string, instance int, status string, detail string) error {
+	r.lock.Lock()
+	key := model.InstanceKey(sname, instance)
+	running, found := r.instances[key]
+	delete(r.instances, key)
+	r.lock.Unlock()
+	if !found {
+		return fmt.Errorf("instance %s not running", key)
+	}
+	service := running.service
+	service.Status = status
+	service.StatusDetail = detail
+	running.notify(service)
+	return nil
+}
+
+// Instances returns the running instances, sorted by service and instance
+func (r *FakeRuntime) Instances() []model.Service {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	running := make([]model.Service, 0, len(r.instances))
+	for _, instance := range r.instances {
+		running = append(running, instance.service)
+	}
+	sort.Slice(running, func(i, j int) bool {
+		if running[i].Sname != running[j].Sname {
+			return running[i].Sname < running[j].Sname
+		}
+		return running[i].Instance < running[j].Instance
+	})
+	return running
+}
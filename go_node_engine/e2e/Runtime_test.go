@@ -0,0 +1,53 @@
+package e2e
+
+import (
+	"context"
+	"errors"
+	"go_node_engine/model"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+)
+
+func TestFakeRuntime(t *testing.T) {
+	fake := NewFakeRuntime()
+	reported := make(chan model.Service, 1)
+	notify := func(service model.Service) { reported <- service }
+	web := model.Service{Sname: "app.web", Runtime: string(model.CONTAINER_RUNTIME)}
+
+	assert.NilError(t, fake.Deploy(context.Background(), web, notify))
+	assert.ErrorContains(t, fake.Deploy(context.Background(), web, notify), "already running")
+	assert.DeepEqual(t, fake.Instances(), []model.Service{web})
+
+	assert.NilError(t, fake.SetUsage("app.web", 0, 50, 1024))
+	usage := make(chan []model.Resources, 1)
+	go fake.ResourceMonitoring(func() time.Duration { return time.Millisecond }, func(res []model.Resources) {
+		select {
+		case usage <- res:
+		default:
+		}
+	})
+	assert.DeepEqual(t, <-usage, []model.Resources{{Sname: "app.web", Runtime: "docker", Cpu: 50, Memory: 1024}})
+
+	fake.FailUndeploy("app.web", errors.New("stuck"))
+	assert.ErrorContains(t, fake.Undeploy("app.web", 0), "stuck")
+	fake.FailUndeploy("app.web", nil)
+	assert.NilError(t, fake.Undeploy("app.web", 0))
+	assert.ErrorContains(t, fake.Undeploy("app.web", 0), "not running")
+
+	fake.FailDeploy("app.web", errors.New("pull failed"))
+	assert.ErrorContains(t, fake.Deploy(context.Background(), web, notify), "pull failed")
+	fake.FailDeploy("app.web", nil)
+
+	fake.SetDeployDelay(time.Hour)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	assert.Equal(t, fake.Deploy(ctx, web, notify), context.DeadlineExceeded)
+	fake.SetDeployDelay(0)
+
+	assert.NilError(t, fake.Deploy(context.Background(), web, notify))
+	assert.NilError(t, fake.Exit("app.web", 0, model.SERVICE_COMPLETED, ""))
+	assert.Equal(t, (<-reported).Status, model.SERVICE_COMPLETED)
+	assert.Equal(t, len(fake.Instances()), 0)
+}
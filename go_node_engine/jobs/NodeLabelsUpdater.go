@@ -0,0 +1,25 @@
+package jobs
+
+import (
+	"go_node_engine/model"
+	"sync"
+	"time"
+)
+
+var labelsOnce sync.Once
+
+// NodeLabelsUpdater periodically reloads the node labels and probes its capabilities, notifying only the changes.
+func NodeLabelsUpdater(cadence func() time.Duration, labelsUpdateHandler func(labels model.NodeLabels)) {
+	labelsOnce.Do(func() {
+		go labelsUpdateRoutine(cadence, labelsUpdateHandler)
+	})
+}
+
+func labelsUpdateRoutine(cadence func() time.Duration, labelsUpdateHandler func(labels model.NodeLabels)) {
+	for {
+		<-time.After(cadence())
+		if model.RefreshLabels() {
+			labelsUpdateHandler(model.GetNodeLabels())
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package jobs
 
 import (
+	"go_node_engine/metrics"
 	"go_node_engine/model"
 	"sync"
 	"time"
@@ -8,18 +9,20 @@ import (
 
 var once sync.Once
 
-// NodeStatusUpdater updates the status of the node.
-func NodeStatusUpdater(cadence time.Duration, statusUpdateHandler func(node model.Node)) {
+// NodeStatusUpdater updates the status of the node. The cadence is read at every cycle, so that it can change at runtime.
+func NodeStatusUpdater(cadence func() time.Duration, statusUpdateHandler func(node model.Node)) {
 	once.Do(func() {
 		go updateRoutine(cadence, statusUpdateHandler)
 	})
 }
 
-func updateRoutine(cadence time.Duration, statusUpdateHandler func(node model.Node)) {
+func updateRoutine(cadence func() time.Duration, statusUpdateHandler func(node model.Node)) {
 	for true {
 		select {
-		case <-time.After(cadence):
-			statusUpdateHandler(model.GetDynamicInfo())
+		case <-time.After(cadence()):
+			node := model.GetDynamicInfo()
+			metrics.ObserveNode(node)
+			statusUpdateHandler(node)
 		}
 	}
 }
@@ -1,15 +1,21 @@
 package jobs
 
 import (
+	"go_node_engine/metrics"
 	"go_node_engine/model"
 	"go_node_engine/virtualization"
 	"time"
 )
 
 // StartServicesMonitoring starts the monitoring of the services
-func StartServicesMonitoring(every time.Duration, notifyHandler func(res []model.Resources)) {
-	node := model.GetNodeInfo()
+func StartServicesMonitoring(every func() time.Duration, notifyHandler func(res []model.Resources)) {
+	node := model.GetCachedNodeInfo()
 	for _, runtime := range node.Technology {
-		go virtualization.GetRuntimeMonitoring(runtime).ResourceMonitoring(every, notifyHandler)
+		runtime := runtime
+		go virtualization.GetRuntimeMonitoring(runtime).ResourceMonitoring(every, func(res []model.Resources) {
+			metrics.ObserveInstances(string(runtime), res)
+			model.SetInstancesUsage(runtime, res)
+			notifyHandler(res)
+		})
 	}
 }
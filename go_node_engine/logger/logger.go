@@ -1,28 +1,209 @@
 package logger
 
 import (
-	"log"
+	"context"
+	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"runtime"
 	"sync"
+	"time"
+
+	"golang.org/x/exp/slog"
+)
+
+// Standard fields of the log records
+const (
+	NODE_ID  = "node_id"
+	MODULE   = "module"
+	SERVICE  = "service"
+	INSTANCE = "instance"
+	ERROR    = "error"
 )
 
-var infologger *log.Logger
-var errorlogger *log.Logger
-var infoonce sync.Once
-var erroronce sync.Once
-
-// InfoLogger returns a logger for info messages
-func InfoLogger() *log.Logger {
-	infoonce.Do(func() {
-		infologger = log.New(os.Stdout, "INFO-", log.Ldate|log.Ltime|log.Lshortfile)
-	})
-	return infologger
-}
-
-// ErrorLogger returns a logger for error messages
-func ErrorLogger() *log.Logger {
-	erroronce.Do(func() {
-		errorlogger = log.New(os.Stderr, "ERROR-", log.Ldate|log.Ltime|log.Lshortfile)
-	})
-	return errorlogger
+// Output formats
+const (
+	FORMAT_TEXT = "text"
+	FORMAT_JSON = "json"
+)
+
+// Settings of the logs, they can be changed at any time
+type Settings struct {
+	// Level is the minimum level printed, one of debug, info, warn or error
+	Level string
+	// Format is text or json
+	Format string
+	// Modules overrides the level of single modules, e.g. mqtt: debug
+	Modules map[string]string
+}
+
+// Logger is the logger of a module of the NodeEngine
+type Logger struct {
+	*slog.Logger
+}
+
+// state is the current configuration of the logs, replaced as a whole when it changes
+type state struct {
+	handler slog.Handler
+	level   slog.Level
+	modules map[string]slog.Level
+}
+
+var output io.Writer = os.Stderr
+var settings = Settings{Level: "info", Format: FORMAT_TEXT}
+var nodeID = ""
+var current *state
+var lock sync.RWMutex
+
+func init() {
+	current = newState(settings, nodeID, output)
+}
+
+// Module returns the logger of the given module, following the settings as they change
+func Module(name string) *Logger {
+	return &Logger{slog.New(&moduleHandler{module: name})}
+}
+
+// Instance returns the logger with the standard fields of a service instance
+func (l *Logger) Instance(sname string, instance int) *Logger {
+	return &Logger{l.With(SERVICE, sname, INSTANCE, instance)}
+}
+
+// Fatal logs the message at the error level, then exits
+func (l *Logger) Fatal(msg string, args ...any) {
+	if l.Enabled(context.Background(), slog.LevelError) {
+		var pcs [1]uintptr
+		runtime.Callers(2, pcs[:])
+		record := slog.NewRecord(time.Now(), slog.LevelError, msg, pcs[0])
+		record.Add(args...)
+		_ = l.Handler().Handle(context.Background(), record)
+	}
+	os.Exit(1)
+}
+
+// Configure applies the settings to every logger
+func Configure(s Settings) error {
+	if _, err := ParseLevel(s.Level); err != nil {
+		return err
+	}
+	if s.Format != FORMAT_TEXT && s.Format != FORMAT_JSON {
+		return fmt.Errorf("unknown log format %s", s.Format)
+	}
+	for module, level := range s.Modules {
+		if _, err := ParseLevel(level); err != nil {
+			return fmt.Errorf("module %s: %v", module, err)
+		}
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	settings = s
+	current = newState(settings, nodeID, output)
+	return nil
+}
+
+// SetNodeID adds the node ID to every record
+func SetNodeID(id string) {
+	lock.Lock()
+	defer lock.Unlock()
+	nodeID = id
+	current = newState(settings, nodeID, output)
+}
+
+// SetOutput sets where the records are written, os.Stderr by default
+func SetOutput(w io.Writer) {
+	lock.Lock()
+	defer lock.Unlock()
+	output = w
+	current = newState(settings, nodeID, output)
+}
+
+// ParseLevel returns the level named debug, info, warn or error
+func ParseLevel(level string) (slog.Level, error) {
+	switch level {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info":
+		return slog.LevelInfo, nil
+	case "warn":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return slog.LevelInfo, fmt.Errorf("unknown log level %s", level)
+}
+
+func newState(s Settings, id string, w io.Writer) *state {
+	options := slog.HandlerOptions{
+		AddSource:   true,
+		Level:       slog.LevelDebug,
+		ReplaceAttr: shortSource,
+	}
+	var handler slog.Handler = options.NewTextHandler(w)
+	if s.Format == FORMAT_JSON {
+		handler = options.NewJSONHandler(w)
+	}
+	if id != "" {
+		handler = handler.WithAttrs([]slog.Attr{slog.String(NODE_ID, id)})
+	}
+	level, _ := ParseLevel(s.Level)
+	modules := make(map[string]slog.Level, len(s.Modules))
+	for module, name := range s.Modules {
+		modules[module], _ = ParseLevel(name)
+	}
+	return &state{handler: handler, level: level, modules: modules}
+}
+
+// shortSource keeps the file name of the source, as log.Lshortfile did
+func shortSource(groups []string, attr slog.Attr) slog.Attr {
+	if attr.Key == slog.SourceKey && len(groups) == 0 {
+		return slog.String(slog.SourceKey, filepath.Base(attr.Value.String()))
+	}
+	return attr
+}
+
+func getState() *state {
+	lock.RLock()
+	defer lock.RUnlock()
+	return current
+}
+
+func (s *state) levelOf(module string) slog.Level {
+	if level, found := s.modules[module]; found {
+		return level
+	}
+	return s.level
+}
+
+// moduleHandler filters the records with the level of its module and writes them with the current handler,
+// so that the loggers created at startup follow the settings changed afterwards
+type moduleHandler struct {
+	module string
+	wraps  []func(slog.Handler) slog.Handler
+}
+
+func (h *moduleHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= getState().levelOf(h.module)
+}
+
+func (h *moduleHandler) Handle(ctx context.Context, record slog.Record) error {
+	handler := getState().handler.WithAttrs([]slog.Attr{slog.String(MODULE, h.module)})
+	for _, wrap := range h.wraps {
+		handler = wrap(handler)
+	}
+	return handler.Handle(ctx, record)
+}
+
+func (h *moduleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.with(func(handler slog.Handler) slog.Handler { return handler.WithAttrs(attrs) })
+}
+
+func (h *moduleHandler) WithGroup(name string) slog.Handler {
+	return h.with(func(handler slog.Handler) slog.Handler { return handler.WithGroup(name) })
+}
+
+func (h *moduleHandler) with(wrap func(slog.Handler) slog.Handler) *moduleHandler {
+	wraps := make([]func(slog.Handler) slog.Handler, len(h.wraps), len(h.wraps)+1)
+	copy(wraps, h.wraps)
+	return &moduleHandler{module: h.module, wraps: append(wraps, wrap)}
 }
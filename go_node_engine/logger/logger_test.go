@@ -0,0 +1,72 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func capture(t *testing.T, s Settings) *bytes.Buffer {
+	buffer := &bytes.Buffer{}
+	SetOutput(buffer)
+	assert.NilError(t, Configure(s))
+	t.Cleanup(func() {
+		SetOutput(os.Stderr)
+		SetNodeID("")
+		_ = Configure(Settings{Level: "info", Format: FORMAT_TEXT})
+	})
+	return buffer
+}
+
+func TestModuleLevels(t *testing.T) {
+	buffer := capture(t, Settings{Level: "warn", Format: FORMAT_TEXT, Modules: map[string]string{"mqtt": "debug"}})
+
+	Module("mqtt").Debug("mqtt message")
+	Module("config").Info("config message")
+	Module("config").Warn("config warning")
+
+	out := buffer.String()
+	assert.Assert(t, strings.Contains(out, "mqtt message"))
+	assert.Assert(t, strings.Contains(out, "module=mqtt"))
+	assert.Assert(t, !strings.Contains(out, "config message"))
+	assert.Assert(t, strings.Contains(out, "config warning"))
+}
+
+func TestJSONFields(t *testing.T) {
+	buffer := capture(t, Settings{Level: "info", Format: FORMAT_JSON})
+	SetNodeID("node-1")
+
+	Module("virtualization").Instance("app.demo", 2).Info("started", "runtime", "docker")
+
+	record := map[string]interface{}{}
+	assert.NilError(t, json.Unmarshal(buffer.Bytes(), &record))
+	assert.Equal(t, record["msg"], "started")
+	assert.Equal(t, record[MODULE], "virtualization")
+	assert.Equal(t, record[NODE_ID], "node-1")
+	assert.Equal(t, record[SERVICE], "app.demo")
+	assert.Equal(t, record[INSTANCE], float64(2))
+	assert.Equal(t, record["runtime"], "docker")
+	assert.Assert(t, strings.HasPrefix(record["source"].(string), "logger_test.go:"))
+}
+
+func TestReconfigure(t *testing.T) {
+	log := Module("model")
+	buffer := capture(t, Settings{Level: "info", Format: FORMAT_TEXT})
+
+	log.Debug("hidden")
+	assert.NilError(t, Configure(Settings{Level: "info", Format: FORMAT_TEXT, Modules: map[string]string{"model": "debug"}}))
+	log.Debug("shown")
+
+	assert.Assert(t, !strings.Contains(buffer.String(), "hidden"))
+	assert.Assert(t, strings.Contains(buffer.String(), "shown"))
+}
+
+func TestConfigureInvalid(t *testing.T) {
+	assert.ErrorContains(t, Configure(Settings{Level: "trace", Format: FORMAT_TEXT}), "unknown log level")
+	assert.ErrorContains(t, Configure(Settings{Level: "info", Format: "xml"}), "unknown log format")
+	assert.ErrorContains(t, Configure(Settings{Level: "info", Format: FORMAT_TEXT, Modules: map[string]string{"mqtt": "all"}}), "module mqtt")
+}
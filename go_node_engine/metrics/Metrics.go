@@ -0,0 +1,123 @@
+package metrics
+
+import (
+	"go_node_engine/model"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+// NAMESPACE prefixes every metric exported by the NodeEngine
+const NAMESPACE = "node_engine"
+
+// Result labels of the deploy latency histogram
+const (
+	RESULT_SUCCESS = "success"
+	RESULT_FAILURE = "failure"
+)
+
+var registry = prometheus.NewRegistry()
+
+// Engine metrics
+var (
+	// DeployDuration is the time from a deploy command to a running instance, or to the failure
+	DeployDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: NAMESPACE,
+		Name:      "deploy_duration_seconds",
+		Help:      "Time to deploy a service instance, by runtime and result.",
+		Buckets:   []float64{0.5, 1, 2.5, 5, 10, 30, 60, 120, 300},
+	}, []string{"runtime", "result"})
+	// PullDuration is the time spent downloading an image or a kernel
+	PullDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: NAMESPACE,
+		Name:      "pull_duration_seconds",
+		Help:      "Time to pull a container image or download a unikernel image, by runtime.",
+		Buckets:   []float64{0.5, 1, 2.5, 5, 10, 30, 60, 120, 300, 600},
+	}, []string{"runtime"})
+	// MqttPublishFailures counts the messages the broker did not accept
+	MqttPublishFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: NAMESPACE,
+		Name:      "mqtt_publish_failures_total",
+		Help:      "MQTT messages that failed to publish, by topic.",
+	}, []string{"topic"})
+	// NetManagerErrors counts the failed NetManager requests
+	NetManagerErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: NAMESPACE,
+		Name:      "netmanager_errors_total",
+		Help:      "NetManager requests that failed, by operation.",
+	}, []string{"operation"})
+)
+
+// Node metrics, refreshed at every monitoring cycle
+var (
+	nodeCpuUsage = nodeGauge("cpu_usage_percent", "CPU usage of the node.")
+	nodeCpuFree  = nodeGauge("cpu_free_cores", "CPU cores available on the node.")
+	nodeMemUsage = nodeGauge("memory_usage_percent", "Memory usage of the node.")
+	nodeMemFree  = nodeGauge("memory_free_megabytes", "Memory available on the node.")
+	nodeGpuUsage = nodeGauge("gpu_usage_percent", "Usage of the node GPUs.")
+	nodeGpuTemp  = nodeGauge("gpu_temperature_celsius", "Temperature of the node GPUs.")
+	nodeGpuMem   = nodeGauge("gpu_memory_used_megabytes", "Memory used on the node GPUs.")
+	nodeGpuCores = nodeGauge("gpu_cores", "GPUs of the node.")
+	nodeDisk     = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: NAMESPACE,
+		Subsystem: "node",
+		Name:      "disk_megabytes",
+		Help:      "Disk of the node, by ledger (capacity, allocated, allocatable).",
+	}, []string{"ledger"})
+)
+
+// Instance metrics, refreshed by the resource monitoring loop of each runtime
+var (
+	instanceCpu    = instanceGauge("cpu_usage_percent", "CPU usage of the service instance.")
+	instanceMemory = instanceGauge("memory_bytes", "Memory used by the service instance.")
+	instanceDisk   = instanceGauge("disk_bytes", "Disk used by the service instance.")
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		DeployDuration, PullDuration, MqttPublishFailures, NetManagerErrors,
+		nodeCpuUsage, nodeCpuFree, nodeMemUsage, nodeMemFree,
+		nodeGpuUsage, nodeGpuTemp, nodeGpuMem, nodeGpuCores, nodeDisk,
+		instanceCpu, instanceMemory, instanceDisk,
+	)
+}
+
+func nodeGauge(name string, help string) prometheus.Gauge {
+	return prometheus.NewGauge(prometheus.GaugeOpts{Namespace: NAMESPACE, Subsystem: "node", Name: name, Help: help})
+}
+
+func instanceGauge(name string, help string) *prometheus.GaugeVec {
+	return prometheus.NewGaugeVec(prometheus.GaugeOpts{Namespace: NAMESPACE, Subsystem: "instance", Name: name, Help: help},
+		[]string{"service", "instance", "runtime"})
+}
+
+// ObserveNode records the dynamic information of the node
+func ObserveNode(node model.Node) {
+	nodeCpuUsage.Set(node.CpuUsage)
+	nodeCpuFree.Set(float64(node.CpuCores))
+	nodeMemUsage.Set(node.MemoryUsed)
+	nodeMemFree.Set(float64(node.MemoryMB))
+	nodeGpuUsage.Set(node.GpuUsage)
+	nodeGpuTemp.Set(node.GpuTemp)
+	nodeGpuMem.Set(node.GpuMemUsage)
+	nodeGpuCores.Set(float64(node.GpuCores))
+	nodeDisk.WithLabelValues("capacity").Set(float64(node.Capacity.Disk))
+	nodeDisk.WithLabelValues("allocated").Set(float64(node.Allocated.Disk))
+	nodeDisk.WithLabelValues("allocatable").Set(float64(node.Allocatable.Disk))
+}
+
+// ObserveInstances records the resources of the instances of a runtime, dropping the instances no longer running
+func ObserveInstances(runtime string, resources []model.Resources) {
+	for _, gauge := range []*prometheus.GaugeVec{instanceCpu, instanceMemory, instanceDisk} {
+		gauge.DeletePartialMatch(prometheus.Labels{"runtime": runtime})
+	}
+	for _, res := range resources {
+		instance := strconv.Itoa(res.Instance)
+		instanceCpu.WithLabelValues(res.Sname, instance, runtime).Set(res.Cpu)
+		instanceMemory.WithLabelValues(res.Sname, instance, runtime).Set(res.Memory)
+		instanceDisk.WithLabelValues(res.Sname, instance, runtime).Set(float64(res.Disk))
+	}
+}
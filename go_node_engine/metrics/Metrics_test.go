@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"go_node_engine/model"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func scrape(t *testing.T) string {
+	recorder := httptest.NewRecorder()
+	Handler().ServeHTTP(recorder, httptest.NewRequest("GET", METRICS_PATH, nil))
+	assert.Equal(t, recorder.Code, 200)
+	body, err := io.ReadAll(recorder.Body)
+	assert.NilError(t, err)
+	return string(body)
+}
+
+func TestScrape(t *testing.T) {
+	ObserveNode(model.Node{CpuUsage: 12.5, GpuCores: 1, Capacity: model.ResourceAmount{Disk: 2048}})
+	DeployDuration.WithLabelValues("docker", RESULT_SUCCESS).Observe(1.5)
+	MqttPublishFailures.WithLabelValues("job").Inc()
+	NetManagerErrors.WithLabelValues("container/deploy").Inc()
+
+	body := scrape(t)
+	for _, expected := range []string{
+		"node_engine_node_cpu_usage_percent 12.5",
+		"node_engine_node_gpu_cores 1",
+		`node_engine_node_disk_megabytes{ledger="capacity"} 2048`,
+		`node_engine_deploy_duration_seconds_count{result="success",runtime="docker"} 1`,
+		`node_engine_mqtt_publish_failures_total{topic="job"} 1`,
+		`node_engine_netmanager_errors_total{operation="container/deploy"} 1`,
+		"go_goroutines",
+	} {
+		assert.Assert(t, strings.Contains(body, expected), "missing %s", expected)
+	}
+}
+
+func TestObserveInstances(t *testing.T) {
+	ObserveInstances("docker", []model.Resources{{Sname: "app", Instance: 0, Cpu: 3}, {Sname: "app", Instance: 1, Cpu: 4}})
+	ObserveInstances("unikernel", []model.Resources{{Sname: "vm", Instance: 0, Memory: 1024}})
+	// the instance 1 is no longer running, the unikernel instance is untouched
+	ObserveInstances("docker", []model.Resources{{Sname: "app", Instance: 0, Cpu: 5}})
+
+	body := scrape(t)
+	assert.Assert(t, strings.Contains(body, `node_engine_instance_cpu_usage_percent{instance="0",runtime="docker",service="app"} 5`))
+	assert.Assert(t, !strings.Contains(body, `instance="1",runtime="docker"`))
+	assert.Assert(t, strings.Contains(body, `node_engine_instance_memory_bytes{instance="0",runtime="unikernel",service="vm"} 1024`))
+}
@@ -0,0 +1,37 @@
+package metrics
+
+import (
+	"go_node_engine/logger"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"golang.org/x/exp/slog"
+)
+
+var log = logger.Module("metrics")
+
+// METRICS_PATH is where the metrics are scraped from
+const METRICS_PATH = "/metrics"
+
+// Handler serves the NodeEngine metrics in the Prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{ErrorLog: slog.NewLogLogger(log.Handler(), slog.LevelError)})
+}
+
+// Serve starts the metrics listener on address in the background
+func Serve(address string) {
+	mux := http.NewServeMux()
+	mux.Handle(METRICS_PATH, Handler())
+	server := &http.Server{
+		Addr:              address,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		log.Info("Serving metrics", "address", address, "path", METRICS_PATH)
+		if err := server.ListenAndServe(); err != nil {
+			log.Error("Metrics listener stopped", logger.ERROR, err)
+		}
+	}()
+}
@@ -0,0 +1,89 @@
+package model
+
+import (
+	"fmt"
+	"go_node_engine/model/gpu"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/shirou/gopsutil/cpu"
+)
+
+// cpuFeatures are the CPU flags advertised as capabilities when supported by the node
+var cpuFeatures = []string{"avx", "avx2", "avx512f", "aes", "sse4_2", "vmx", "svm", "fma", "asimd", "sve", "neon"}
+
+// deviceClasses maps a capability name to the host device nodes implementing it
+var deviceClasses = map[string]string{
+	"camera": "/dev/video*",
+	"serial": "/dev/tty[AU][CS][MB]*",
+	"i2c":    "/dev/i2c-*",
+	"gpio":   "/dev/gpiochip*",
+	"spi":    "/dev/spidev*",
+	"sound":  "/dev/snd/pcm*",
+}
+
+// probeCapabilities detects what the node can offer beyond plain resources, e.g. kvm=true or device.camera=2
+func probeCapabilities() map[string]string {
+	capabilities := make(map[string]string)
+
+	capabilities["arch"] = runtime.GOARCH
+	capabilities["kvm"] = fmt.Sprintf("%t", kvmAvailable())
+	capabilities["cgroup"] = CgroupVersion()
+
+	for _, feature := range probeCpuFeatures() {
+		capabilities["cpu."+feature] = "true"
+	}
+
+	for class, pattern := range deviceClasses {
+		devices, _ := filepath.Glob(pattern)
+		if len(devices) > 0 {
+			capabilities["device."+class] = fmt.Sprintf("%d", len(devices))
+		}
+	}
+	accelerators := gpu.GetCollector().Collect()
+	if len(accelerators.Gpus) > 0 {
+		capabilities["device.gpu"] = fmt.Sprintf("%d", len(accelerators.Gpus))
+	}
+	if len(accelerators.Tpus) > 0 {
+		capabilities["device.tpu"] = fmt.Sprintf("%d", len(accelerators.Tpus))
+	}
+	return capabilities
+}
+
+// kvmAvailable reports whether /dev/kvm can be opened by the node engine
+func kvmAvailable() bool {
+	kvm, err := os.OpenFile("/dev/kvm", os.O_RDWR, 0)
+	if err != nil {
+		return false
+	}
+	_ = kvm.Close()
+	return true
+}
+
+// CgroupVersion returns v2 on unified hierarchies, v1 otherwise
+func CgroupVersion() string {
+	if _, err := os.Stat("/sys/fs/cgroup/cgroup.controllers"); err == nil {
+		return "v2"
+	}
+	return "v1"
+}
+
+func probeCpuFeatures() []string {
+	info, err := cpu.Info()
+	if err != nil || len(info) == 0 {
+		return nil
+	}
+	flags := make(map[string]bool)
+	for _, flag := range info[0].Flags {
+		flags[strings.ToLower(flag)] = true
+	}
+	features := make([]string, 0)
+	for _, feature := range cpuFeatures {
+		if flags[feature] {
+			features = append(features, feature)
+		}
+	}
+	return features
+}
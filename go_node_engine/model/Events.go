@@ -0,0 +1,59 @@
+package model
+
+import (
+	"sync"
+	"time"
+)
+
+// EVENTS_SIZE is the number of recent events kept by the node
+const EVENTS_SIZE = 200
+
+// EventType tells what an event is about
+type EventType string
+
+const (
+	// EVENT_STATUS an instance changed status
+	EVENT_STATUS EventType = "status"
+	// EVENT_REGISTRATION the registration state of the node changed
+	EVENT_REGISTRATION EventType = "registration"
+	// EVENT_CONNECTION the connection to the cluster broker was established or lost
+	EVENT_CONNECTION EventType = "connection"
+	// EVENT_OPERATOR an operator acted on the node through the admin API
+	EVENT_OPERATOR EventType = "operator"
+	// EVENT_LABELS the labels file could not be reloaded
+	EVENT_LABELS EventType = "labels"
+)
+
+// Event is something that happened on the node, kept for the operators.
+// Sname and Instance are set for the events about an instance.
+type Event struct {
+	Time     time.Time `json:"time"`
+	Type     EventType `json:"type"`
+	Sname    string    `json:"job_name,omitempty"`
+	Instance int       `json:"instance_number"`
+	Message  string    `json:"message"`
+}
+
+var events = make([]Event, 0, EVENTS_SIZE)
+var eventsLock sync.Mutex
+
+// RecordEvent keeps the event, dropping the oldest beyond EVENTS_SIZE
+func RecordEvent(event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	eventsLock.Lock()
+	defer eventsLock.Unlock()
+	if len(events) == EVENTS_SIZE {
+		copy(events, events[1:])
+		events = events[:EVENTS_SIZE-1]
+	}
+	events = append(events, event)
+}
+
+// GetEvents returns the recent events, oldest first
+func GetEvents() []Event {
+	eventsLock.Lock()
+	defer eventsLock.Unlock()
+	return append([]Event(nil), events...)
+}
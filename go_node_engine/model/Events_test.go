@@ -0,0 +1,30 @@
+package model
+
+import (
+	"fmt"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestEventsKeepTheMostRecent(t *testing.T) {
+	for i := 0; i < EVENTS_SIZE+5; i++ {
+		RecordEvent(Event{Type: EVENT_STATUS, Sname: "app", Instance: i, Message: fmt.Sprintf("event %d", i)})
+	}
+	recent := GetEvents()
+	assert.Equal(t, len(recent), EVENTS_SIZE)
+	assert.Equal(t, recent[0].Instance, 5)
+	assert.Equal(t, recent[EVENTS_SIZE-1].Message, fmt.Sprintf("event %d", EVENTS_SIZE+4))
+	assert.Assert(t, !recent[0].Time.IsZero())
+}
+
+func TestInstanceUsage(t *testing.T) {
+	SetInstancesUsage(CONTAINER_RUNTIME, []Resources{{Sname: "app", Instance: 0, Cpu: 12}})
+	res, found := GetInstanceUsage("app", 0)
+	assert.Assert(t, found)
+	assert.Equal(t, res.Cpu, 12.0)
+
+	SetInstancesUsage(CONTAINER_RUNTIME, []Resources{})
+	_, found = GetInstanceUsage("app", 0)
+	assert.Assert(t, !found)
+}
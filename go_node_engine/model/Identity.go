@@ -0,0 +1,73 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// IDENTITY_FILE is the file, in the node state directory, where the node identity is persisted
+const IDENTITY_FILE = "identity.json"
+
+// Identity is what the node received from the cluster at registration, persisted to be presented again after a restart
+type Identity struct {
+	NodeId         string    `json:"node_id"`
+	ClusterAddress string    `json:"cluster_address"`
+	ClusterPort    int       `json:"cluster_port"`
+	MqttUsername   string    `json:"mqtt_username,omitempty"`
+	MqttPassword   string    `json:"mqtt_password,omitempty"`
+	RegisteredAt   time.Time `json:"registered_at"`
+}
+
+// BelongsTo returns true if the identity was issued by the cluster at the given endpoint
+func (i Identity) BelongsTo(address string, port int) bool {
+	return i.NodeId != "" && i.ClusterAddress == address && i.ClusterPort == port
+}
+
+// LoadIdentity reads the identity persisted in the state directory, returning an empty identity if there is none
+func LoadIdentity(stateDirectory string) (Identity, error) {
+	identity := Identity{}
+	content, err := os.ReadFile(filepath.Join(stateDirectory, IDENTITY_FILE))
+	if errors.Is(err, fs.ErrNotExist) {
+		return identity, nil
+	}
+	if err != nil {
+		return identity, err
+	}
+	err = json.Unmarshal(content, &identity)
+	return identity, err
+}
+
+// SaveIdentity persists the identity in the state directory. The file is readable by the owner only,
+// as it holds the broker credentials, and replaced atomically.
+func SaveIdentity(stateDirectory string, identity Identity) error {
+	if err := os.MkdirAll(stateDirectory, 0700); err != nil {
+		return err
+	}
+	content, err := json.MarshalIndent(identity, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(stateDirectory, IDENTITY_FILE+".*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+	if _, err := tmp.Write(content); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(stateDirectory, IDENTITY_FILE))
+}
@@ -0,0 +1,36 @@
+package model
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+)
+
+func TestIdentityPersistence(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "state")
+	identity, err := LoadIdentity(dir)
+	assert.NilError(t, err)
+	assert.Equal(t, identity.NodeId, "")
+
+	saved := Identity{
+		NodeId:         "node-1",
+		ClusterAddress: "10.0.0.1",
+		ClusterPort:    10100,
+		MqttUsername:   "node-1",
+		MqttPassword:   "secret",
+		RegisteredAt:   time.Unix(1000, 0).UTC(),
+	}
+	assert.NilError(t, SaveIdentity(dir, saved))
+	stat, err := os.Stat(filepath.Join(dir, IDENTITY_FILE))
+	assert.NilError(t, err)
+	assert.Equal(t, stat.Mode().Perm(), os.FileMode(0600))
+
+	identity, err = LoadIdentity(dir)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, identity, saved)
+	assert.Assert(t, identity.BelongsTo("10.0.0.1", 10100))
+	assert.Assert(t, !identity.BelongsTo("10.0.0.2", 10100))
+}
@@ -0,0 +1,146 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"go_node_engine/logger"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// Taint effects, following the Kubernetes semantic
+const (
+	TAINT_NO_SCHEDULE        = "NoSchedule"
+	TAINT_PREFER_NO_SCHEDULE = "PreferNoSchedule"
+	TAINT_NO_EXECUTE         = "NoExecute"
+)
+
+// Taint repels the services that do not explicitly tolerate it
+type Taint struct {
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Effect string `json:"effect"`
+}
+
+// NodeLabels groups the user defined labels and taints with the automatically detected capabilities
+type NodeLabels struct {
+	Labels       map[string]string `json:"labels,omitempty"`
+	Taints       []Taint           `json:"taints,omitempty"`
+	Capabilities map[string]string `json:"capabilities,omitempty"`
+}
+
+// labelsFile is the on-disk format of the user defined labels and taints
+type labelsFile struct {
+	Labels map[string]string `json:"labels"`
+	Taints []Taint           `json:"taints"`
+}
+
+var labelsLock sync.Mutex
+var labelsPath string
+var labelsModTime time.Time
+
+// labelsError is the last refresh error reported, the same error is reported once while the file stays invalid
+var labelsError string
+
+// SetLabelsFile loads the labels and taints from the given JSON file and probes the node capabilities.
+// The file is watched for changes by RefreshLabels. An empty path disables user defined labels.
+func SetLabelsFile(path string) error {
+	labelsLock.Lock()
+	labelsPath = path
+	labelsModTime = time.Time{}
+	labelsLock.Unlock()
+	_, err := refreshLabels()
+	return err
+}
+
+// RefreshLabels reloads the labels file if modified and probes the capabilities again.
+// Returns true if labels, taints or capabilities changed since the last refresh.
+// A failed reload is logged and recorded as an event, the previous labels and taints are kept.
+func RefreshLabels() bool {
+	changed, err := refreshLabels()
+	labelsLock.Lock()
+	defer labelsLock.Unlock()
+	if err != nil {
+		if err.Error() != labelsError {
+			log.Error("Unable to reload the node labels, keeping the previous ones", "path", labelsPath, logger.ERROR, err)
+			RecordEvent(Event{Type: EVENT_LABELS, Message: fmt.Sprintf("unable to reload %s: %v", labelsPath, err)})
+		}
+		labelsError = err.Error()
+		return false
+	}
+	labelsError = ""
+	return changed
+}
+
+// GetNodeLabels returns the current labels, taints and capabilities of the node
+func GetNodeLabels() NodeLabels {
+	labelsLock.Lock()
+	defer labelsLock.Unlock()
+	return GetCachedNodeInfo().NodeLabels
+}
+
+func refreshLabels() (bool, error) {
+	labelsLock.Lock()
+	defer labelsLock.Unlock()
+	previous := GetCachedNodeInfo().NodeLabels
+
+	current := NodeLabels{
+		Labels:       previous.Labels,
+		Taints:       previous.Taints,
+		Capabilities: probeCapabilities(),
+	}
+
+	if labelsPath == "" {
+		current.Labels = nil
+		current.Taints = nil
+	} else {
+		stat, err := os.Stat(labelsPath)
+		if err != nil {
+			return false, fmt.Errorf("unable to read labels file: %v", err)
+		}
+		if !stat.ModTime().Equal(labelsModTime) {
+			loaded, err := loadLabelsFile(labelsPath)
+			if err != nil {
+				return false, err
+			}
+			current.Labels = loaded.Labels
+			current.Taints = loaded.Taints
+			labelsModTime = stat.ModTime()
+		}
+	}
+
+	changed := !reflect.DeepEqual(current, previous)
+	nodeLock.Lock()
+	node.NodeLabels = current
+	nodeLock.Unlock()
+	return changed, nil
+}
+
+func loadLabelsFile(path string) (labelsFile, error) {
+	loaded := labelsFile{}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return loaded, fmt.Errorf("unable to read labels file: %v", err)
+	}
+	if err := json.Unmarshal(content, &loaded); err != nil {
+		return loaded, fmt.Errorf("invalid labels file %s: %v", path, err)
+	}
+	for key := range loaded.Labels {
+		if key == "" {
+			return loaded, fmt.Errorf("invalid labels file %s: empty label key", path)
+		}
+	}
+	for _, taint := range loaded.Taints {
+		if taint.Key == "" {
+			return loaded, fmt.Errorf("invalid labels file %s: empty taint key", path)
+		}
+		switch taint.Effect {
+		case TAINT_NO_SCHEDULE, TAINT_PREFER_NO_SCHEDULE, TAINT_NO_EXECUTE:
+		default:
+			return loaded, fmt.Errorf("invalid labels file %s: unknown taint effect %q", path, taint.Effect)
+		}
+	}
+	return loaded, nil
+}
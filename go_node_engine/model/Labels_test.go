@@ -0,0 +1,72 @@
+package model
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+)
+
+func writeLabels(t *testing.T, path string, content string, modTime time.Time) {
+	assert.NilError(t, os.WriteFile(path, []byte(content), 0644))
+	assert.NilError(t, os.Chtimes(path, modTime, modTime))
+}
+
+func TestLabelsFileReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "labels.json")
+	writeLabels(t, path, `{"labels":{"has-camera":"true"},"taints":[{"key":"dedicated","value":"ml","effect":"NoSchedule"}]}`, time.Unix(1000, 0))
+
+	assert.NilError(t, SetLabelsFile(path))
+	labels := GetNodeLabels()
+	assert.Equal(t, labels.Labels["has-camera"], "true")
+	assert.DeepEqual(t, labels.Taints, []Taint{{Key: "dedicated", Value: "ml", Effect: TAINT_NO_SCHEDULE}})
+	assert.Assert(t, labels.Capabilities["arch"] != "")
+	assert.Assert(t, !RefreshLabels())
+
+	writeLabels(t, path, `{"labels":{"has-camera":"false"}}`, time.Unix(2000, 0))
+	assert.Assert(t, RefreshLabels())
+	assert.Equal(t, GetNodeLabels().Labels["has-camera"], "false")
+	assert.Equal(t, len(GetNodeLabels().Taints), 0)
+
+	assert.NilError(t, SetLabelsFile(""))
+	assert.Equal(t, len(GetNodeLabels().Labels), 0)
+}
+
+func TestLabelsFileValidation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "labels.json")
+	writeLabels(t, path, `{"taints":[{"key":"dedicated","effect":"Sometimes"}]}`, time.Unix(1000, 0))
+	assert.ErrorContains(t, SetLabelsFile(path), "unknown taint effect")
+
+	writeLabels(t, path, `{"labels":`, time.Unix(2000, 0))
+	assert.ErrorContains(t, SetLabelsFile(path), "invalid labels file")
+	assert.NilError(t, SetLabelsFile(""))
+}
+
+func TestLabelsRefreshFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "labels.json")
+	writeLabels(t, path, `{"labels":{"zone":"a"}}`, time.Unix(1000, 0))
+	assert.NilError(t, SetLabelsFile(path))
+	defer SetLabelsFile("") //nolint:errcheck // no file to load
+
+	labelEvents := func() int {
+		count := 0
+		for _, event := range GetEvents() {
+			if event.Type == EVENT_LABELS {
+				count++
+			}
+		}
+		return count
+	}
+	before := labelEvents()
+	writeLabels(t, path, `{"labels":`, time.Unix(2000, 0))
+	assert.Assert(t, !RefreshLabels())
+	assert.Assert(t, !RefreshLabels())
+	assert.Equal(t, labelEvents(), before+1)
+	assert.Equal(t, GetNodeLabels().Labels["zone"], "a")
+
+	writeLabels(t, path, `{"labels":{"zone":"b"}}`, time.Unix(3000, 0))
+	assert.Assert(t, RefreshLabels())
+	assert.Equal(t, GetNodeLabels().Labels["zone"], "b")
+}
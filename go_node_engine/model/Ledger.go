@@ -0,0 +1,168 @@
+package model
+
+import (
+	"go_node_engine/logger"
+	"go_node_engine/model/gpu"
+	"sync"
+
+	"github.com/shirou/gopsutil/disk"
+	"github.com/shirou/gopsutil/mem"
+)
+
+// ResourceAmount is an amount of node resources. Cpu is in cores, Memory and Disk in MB.
+type ResourceAmount struct {
+	Cpu    float64 `json:"cpu"`
+	Memory int     `json:"memory"`
+	Gpu    int     `json:"gpu"`
+	Disk   int     `json:"disk"`
+}
+
+// Ledger keeps track of the resources promised to each deployed instance
+type Ledger struct {
+	capacity       ResourceAmount
+	systemReserved ResourceAmount
+	reservations   map[string]ResourceAmount
+	lock           sync.RWMutex
+}
+
+var ledger *Ledger
+var ledgerOnce sync.Once
+
+// GetLedger returns the node ledger, sized on the detected node capacity
+func GetLedger() *Ledger {
+	ledgerOnce.Do(func() {
+		ledger = NewLedger(getCapacity())
+	})
+	return ledger
+}
+
+// NewLedger creates an empty ledger for a node with the given capacity
+func NewLedger(capacity ResourceAmount) *Ledger {
+	return &Ledger{
+		capacity:     capacity,
+		reservations: make(map[string]ResourceAmount),
+	}
+}
+
+// SetSystemReserved sets the resources kept aside for the operating system and the node components
+func (l *Ledger) SetSystemReserved(reserved ResourceAmount) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.systemReserved = reserved
+}
+
+// Reserve records the resources requested by the service instance.
+// Returns false if the instance already holds a reservation, which is then left untouched.
+func (l *Ledger) Reserve(service Service) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	key := InstanceKey(service.Sname, service.Instance)
+	if _, found := l.reservations[key]; found {
+		return false
+	}
+	l.reservations[key] = RequestedResources(service)
+	return true
+}
+
+// Reserved reports whether the given service instance holds a reservation
+func (l *Ledger) Reserved(sname string, instance int) bool {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	_, found := l.reservations[InstanceKey(sname, instance)]
+	return found
+}
+
+// Release removes the reservation of the given service instance, if any
+func (l *Ledger) Release(sname string, instance int) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	delete(l.reservations, InstanceKey(sname, instance))
+}
+
+// Capacity returns the total resources of the node
+func (l *Ledger) Capacity() ResourceAmount {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	return l.capacity
+}
+
+// Allocated returns the resources currently reserved by the deployed instances
+func (l *Ledger) Allocated() ResourceAmount {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	allocated := ResourceAmount{}
+	for _, reservation := range l.reservations {
+		allocated = allocated.add(reservation)
+	}
+	return allocated
+}
+
+// Allocatable returns the resources still available for new instances:
+// capacity minus system reserved minus allocated, never below zero
+func (l *Ledger) Allocatable() ResourceAmount {
+	allocated := l.Allocated()
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	allocatable := l.capacity.sub(l.systemReserved).sub(allocated)
+	if allocatable.Cpu < 0 {
+		allocatable.Cpu = 0
+	}
+	if allocatable.Memory < 0 {
+		allocatable.Memory = 0
+	}
+	if allocatable.Gpu < 0 {
+		allocatable.Gpu = 0
+	}
+	if allocatable.Disk < 0 {
+		allocatable.Disk = 0
+	}
+	return allocatable
+}
+
+// RequestedResources returns the resources requested by a service
+func RequestedResources(service Service) ResourceAmount {
+	return ResourceAmount{
+		Cpu:    float64(service.Vcpus),
+		Memory: service.Memory,
+		Gpu:    service.Vgpus,
+		Disk:   service.Storage,
+	}
+}
+
+func (r ResourceAmount) add(other ResourceAmount) ResourceAmount {
+	return ResourceAmount{
+		Cpu:    r.Cpu + other.Cpu,
+		Memory: r.Memory + other.Memory,
+		Gpu:    r.Gpu + other.Gpu,
+		Disk:   r.Disk + other.Disk,
+	}
+}
+
+func (r ResourceAmount) sub(other ResourceAmount) ResourceAmount {
+	return ResourceAmount{
+		Cpu:    r.Cpu - other.Cpu,
+		Memory: r.Memory - other.Memory,
+		Gpu:    r.Gpu - other.Gpu,
+		Disk:   r.Disk - other.Disk,
+	}
+}
+
+func getCapacity() ResourceAmount {
+	capacity := ResourceAmount{
+		Cpu: float64(getCpuCores()),
+		Gpu: len(gpu.GetCollector().Collect().Gpus),
+	}
+	memory, err := mem.VirtualMemory()
+	if err != nil {
+		log.Error("Unable to read the memory capacity", logger.ERROR, err)
+	} else {
+		capacity.Memory = int(memory.Total >> 20)
+	}
+	diskUsage, err := disk.Usage("/")
+	if err != nil {
+		log.Error("Unable to read the disk capacity", logger.ERROR, err)
+	} else {
+		capacity.Disk = int(diskUsage.Total >> 20)
+	}
+	return capacity
+}
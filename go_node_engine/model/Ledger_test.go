@@ -0,0 +1,38 @@
+package model
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestLedgerAllocatable(t *testing.T) {
+	ledger := NewLedger(ResourceAmount{Cpu: 4, Memory: 4096, Gpu: 1, Disk: 10000})
+	ledger.SetSystemReserved(ResourceAmount{Cpu: 0.5, Memory: 512})
+	assert.Assert(t, ledger.Reserve(Service{Sname: "a", Instance: 0, Vcpus: 2, Memory: 1024, Vgpus: 1, Storage: 500}))
+
+	assert.DeepEqual(t, ledger.Allocated(), ResourceAmount{Cpu: 2, Memory: 1024, Gpu: 1, Disk: 500})
+	assert.DeepEqual(t, ledger.Allocatable(), ResourceAmount{Cpu: 1.5, Memory: 2560, Gpu: 0, Disk: 9500})
+}
+
+func TestLedgerDuplicateReservationIsKept(t *testing.T) {
+	ledger := NewLedger(ResourceAmount{Cpu: 4, Memory: 4096})
+	assert.Assert(t, ledger.Reserve(Service{Sname: "a", Instance: 0, Vcpus: 1, Memory: 100}))
+	assert.Assert(t, !ledger.Reserve(Service{Sname: "a", Instance: 0, Vcpus: 3, Memory: 300}))
+	assert.DeepEqual(t, ledger.Allocated(), ResourceAmount{Cpu: 1, Memory: 100})
+}
+
+func TestLedgerRelease(t *testing.T) {
+	ledger := NewLedger(ResourceAmount{Cpu: 4, Memory: 4096})
+	ledger.Reserve(Service{Sname: "a", Instance: 0, Vcpus: 1, Memory: 100})
+	ledger.Reserve(Service{Sname: "a", Instance: 1, Vcpus: 1, Memory: 100})
+	ledger.Release("a", 0)
+	ledger.Release("unknown", 3)
+	assert.DeepEqual(t, ledger.Allocated(), ResourceAmount{Cpu: 1, Memory: 100})
+}
+
+func TestLedgerAllocatableNeverNegative(t *testing.T) {
+	ledger := NewLedger(ResourceAmount{Cpu: 1, Memory: 100})
+	ledger.Reserve(Service{Sname: "a", Vcpus: 2, Memory: 200})
+	assert.DeepEqual(t, ledger.Allocatable(), ResourceAmount{})
+}
@@ -2,6 +2,7 @@ package model
 
 import (
 	"fmt"
+	"go_node_engine/config"
 	"go_node_engine/logger"
 	"go_node_engine/model/gpu"
 	"net"
@@ -18,6 +19,8 @@ import (
 	psnet "github.com/shirou/gopsutil/net"
 )
 
+var log = logger.Module("model")
+
 // RuntimeType is the type of runtime that the node executes
 type RuntimeType string
 
@@ -38,38 +41,69 @@ const (
 
 // Node is the struct that describes the node
 type Node struct {
-	Id              string            `json:"id"`
-	Host            string            `json:"host"`
-	Ip              string            `json:"ip"`
-	Port            string            `json:"port"`
-	SystemInfo      map[string]string `json:"system_info"`
-	CpuUsage        float64           `json:"cpu"`
-	CpuCores        int               `json:"free_cores"`
-	CpuArch         string            `json:"architecture"`
-	MemoryUsed      float64           `json:"memory"`
-	MemoryMB        int               `json:"memory_free_in_MB"`
-	DiskInfo        map[string]string `json:"disk_info"`
-	NetworkInfo     map[string]string `json:"network_info"`
-	GpuDriver       string            `json:"gpu_driver"`
-	GpuUsage        float64           `json:"gpu_usage"`
-	GpuCores        int               `json:"gpu_cores"`
-	GpuTemp         float64           `json:"gpu_temp"`
-	GpuMemUsage     float64           `json:"gpu_mem_used"`
-	GpuTotMem       float64           `json:"gpu_tot_mem"`
-	Technology      []RuntimeType     `json:"technology"`
-	SupportedAddons []AddonType       `json:"supported_addons"`
+	Id              string                 `json:"id"`
+	Host            string                 `json:"host"`
+	Ip              string                 `json:"ip"`
+	Port            string                 `json:"port"`
+	SystemInfo      map[string]string      `json:"system_info"`
+	CpuUsage        float64                `json:"cpu"`
+	CpuCores        int                    `json:"free_cores"`
+	CpuArch         string                 `json:"architecture"`
+	MemoryUsed      float64                `json:"memory"`
+	MemoryMB        int                    `json:"memory_free_in_MB"`
+	DiskInfo        map[string]string      `json:"disk_info"`
+	NetworkInfo     map[string]string      `json:"network_info"`
+	GpuDriver       string                 `json:"gpu_driver"`
+	GpuUsage        float64                `json:"gpu_usage"`
+	GpuCores        int                    `json:"gpu_cores"`
+	GpuTemp         float64                `json:"gpu_temp"`
+	GpuMemUsage     float64                `json:"gpu_mem_used"`
+	GpuTotMem       float64                `json:"gpu_tot_mem"`
+	GpuAllocation   []gpu.DeviceAllocation `json:"gpu_allocation"`
+	TpuCores        int                    `json:"tpu_cores"`
+	TpuAllocation   []gpu.DeviceAllocation `json:"tpu_allocation"`
+	Accelerators    []gpu.Device           `json:"accelerators"`
+	Capacity        ResourceAmount         `json:"capacity"`
+	Allocated       ResourceAmount         `json:"allocated"`
+	Allocatable     ResourceAmount         `json:"allocatable"`
+	Technology      []RuntimeType          `json:"technology"`
+	SupportedAddons []AddonType            `json:"supported_addons"`
 	Overlay         bool
 	LogDirectory    string
 	NetManagerPort  int
+	NodeLabels
 }
 
 var once sync.Once
 var node Node
 
-// GetNodeInfo returns the node information
+// nodeLock guards node, read through snapshots since monitoring, deployments and API calls run concurrently
+var nodeLock sync.RWMutex
+
+// GetNodeInfo refreshes the dynamic information of the node, then returns a snapshot of the node information
 func GetNodeInfo() *Node {
+	initNodeInfo()
+	dynamic := collectDynamicInfo()
+	nodeLock.Lock()
+	defer nodeLock.Unlock()
+	node.setDynamicInfo(dynamic)
+	snapshot := node
+	return &snapshot
+}
+
+// GetCachedNodeInfo returns a snapshot of the node information as last refreshed, e.g. to read its IP or overlay mode
+// without collecting the system statistics again
+func GetCachedNodeInfo() *Node {
+	initNodeInfo()
+	nodeLock.RLock()
+	defer nodeLock.RUnlock()
+	snapshot := node
+	return &snapshot
+}
+
+func initNodeInfo() {
 	once.Do(func() {
-		node = Node{
+		static := Node{
 			Host:            getHostname(),
 			SystemInfo:      getSystemInfo(),
 			CpuCores:        getCpuCores(),
@@ -79,39 +113,57 @@ func GetNodeInfo() *Node {
 			SupportedAddons: make([]AddonType, 0),
 			Overlay:         false,
 		}
+		static.setDynamicInfo(collectDynamicInfo())
+		nodeLock.Lock()
+		defer nodeLock.Unlock()
+		node = static
 	})
-	node.updateDynamicInfo()
-	return &node
 }
 
 // SetLogDirectory sets the directory where the logs will be stored
-func (n *Node) SetLogDirectory(dir string) {
-	n.LogDirectory = dir
+func SetLogDirectory(dir string) {
+	initNodeInfo()
+	nodeLock.Lock()
+	defer nodeLock.Unlock()
+	node.LogDirectory = dir
 }
 
 // GetDynamicInfo returns the dynamic information of the node (CPU, Memory, GPU usage etc.)
 func GetDynamicInfo() Node {
-	node.updateDynamicInfo()
+	current := GetNodeInfo()
 	return Node{
-		CpuUsage:    node.CpuUsage,
-		CpuCores:    node.CpuCores,
-		MemoryUsed:  node.MemoryUsed,
-		MemoryMB:    node.MemoryMB,
-		GpuDriver:   node.GpuDriver,
-		GpuTemp:     node.GpuTemp,
-		GpuUsage:    node.GpuUsage,
-		GpuTotMem:   node.GpuTotMem,
-		GpuMemUsage: node.GpuMemUsage,
+		CpuUsage:      current.CpuUsage,
+		CpuCores:      current.CpuCores,
+		MemoryUsed:    current.MemoryUsed,
+		MemoryMB:      current.MemoryMB,
+		GpuDriver:     current.GpuDriver,
+		GpuTemp:       current.GpuTemp,
+		GpuUsage:      current.GpuUsage,
+		GpuTotMem:     current.GpuTotMem,
+		GpuMemUsage:   current.GpuMemUsage,
+		GpuCores:      current.GpuCores,
+		GpuAllocation: current.GpuAllocation,
+		TpuCores:      current.TpuCores,
+		TpuAllocation: current.TpuAllocation,
+		Accelerators:  current.Accelerators,
+		Capacity:      current.Capacity,
+		Allocated:     current.Allocated,
+		Allocatable:   current.Allocatable,
 	}
 }
 
 // EnableOverlay enables the overlay network, setting the port
 func EnableOverlay(port int) {
+	initNodeInfo()
+	nodeLock.Lock()
+	defer nodeLock.Unlock()
 	node.Overlay = true
 	node.NetManagerPort = port
 }
 
-func (n *Node) updateDynamicInfo() {
+// collectDynamicInfo reads the system statistics, without holding nodeLock since they take a while to collect
+func collectDynamicInfo() Node {
+	n := Node{}
 	// System Info
 	n.CpuUsage = getAvgCpuUsage()
 	n.Ip = getIp()
@@ -120,20 +172,55 @@ func (n *Node) updateDynamicInfo() {
 	n.DiskInfo = getDiskinfo()
 	n.NetworkInfo = getNetworkInfo()
 
-	// GPU Info
-	n.GpuDriver = getGpuDriver()
-	n.GpuTotMem = getTotGpuMemFreeMB()
-	n.GpuMemUsage = getGpuMemUsage()
-	n.GpuUsage = getGpuUsage()
-	n.GpuCores = getGpuCores()
-	n.GpuTemp = getGpuTemp()
+	// Accelerators Info, cached by the collector between monitoring cycles
+	accelerators := gpu.GetCollector().Collect()
+	n.GpuDriver = accelerators.Driver()
+	n.GpuTotMem = accelerators.GpuMemFreeMB()
+	n.GpuMemUsage = accelerators.GpuMemUsage()
+	n.GpuUsage = accelerators.GpuUsage()
+	n.GpuCores = len(accelerators.Gpus)
+	n.GpuTemp = accelerators.GpuTemp()
+	n.GpuAllocation = gpu.GetAllocator(gpu.GPU).Devices()
+	n.TpuCores = len(accelerators.Tpus)
+	n.TpuAllocation = gpu.GetAllocator(gpu.TPU).Devices()
+	n.Accelerators = append(append([]gpu.Device{}, accelerators.Gpus...), accelerators.Tpus...)
+
+	// Resources promised to the deployed instances
+	n.Capacity = GetLedger().Capacity()
+	n.Allocated = GetLedger().Allocated()
+	n.Allocatable = GetLedger().Allocatable()
+	return n
+}
 
+func (n *Node) setDynamicInfo(dynamic Node) {
+	n.CpuUsage = dynamic.CpuUsage
+	n.Ip = dynamic.Ip
+	n.MemoryMB = dynamic.MemoryMB
+	n.MemoryUsed = dynamic.MemoryUsed
+	n.DiskInfo = dynamic.DiskInfo
+	n.NetworkInfo = dynamic.NetworkInfo
+	n.GpuDriver = dynamic.GpuDriver
+	n.GpuTotMem = dynamic.GpuTotMem
+	n.GpuMemUsage = dynamic.GpuMemUsage
+	n.GpuUsage = dynamic.GpuUsage
+	n.GpuCores = dynamic.GpuCores
+	n.GpuTemp = dynamic.GpuTemp
+	n.GpuAllocation = dynamic.GpuAllocation
+	n.TpuCores = dynamic.TpuCores
+	n.TpuAllocation = dynamic.TpuAllocation
+	n.Accelerators = dynamic.Accelerators
+	n.Capacity = dynamic.Capacity
+	n.Allocated = dynamic.Allocated
+	n.Allocatable = dynamic.Allocatable
 }
 
 // SetNodeId sets the node id
 func SetNodeId(id string) {
-	GetNodeInfo()
+	initNodeInfo()
+	nodeLock.Lock()
 	node.Id = id
+	nodeLock.Unlock()
+	logger.SetNodeID(id)
 }
 
 func getIp() string {
@@ -155,7 +242,7 @@ func getHostname() string {
 	hostname, err := os.Hostname()
 	if err != nil {
 		hostname = ""
-		logger.ErrorLogger().Fatal("Unable to get Node hostname")
+		log.Fatal("Unable to get Node hostname", logger.ERROR, err)
 	}
 	return hostname
 }
@@ -163,7 +250,7 @@ func getHostname() string {
 func getSystemInfo() map[string]string {
 	hostinfo, err := host.Info()
 	if err != nil {
-		logger.ErrorLogger().Printf("Error: %s", err.Error())
+		log.Error("Unable to read the system information", logger.ERROR, err)
 		return make(map[string]string, 0)
 	}
 	sysInfo := make(map[string]string)
@@ -179,7 +266,7 @@ func getSystemInfo() map[string]string {
 func getCpuCores() int {
 	cpu, err := cpu.Counts(true)
 	if err != nil {
-		logger.ErrorLogger().Printf("Error: %s", err.Error())
+		log.Error("Unable to count the CPU cores", logger.ERROR, err)
 		return 0
 	}
 	return cpu
@@ -196,7 +283,7 @@ func getAvgCpuUsage() float64 {
 func getMemoryMB() int {
 	mem, err := mem.VirtualMemory()
 	if err != nil {
-		logger.ErrorLogger().Printf("Error: %s", err.Error())
+		log.Error("Unable to read the available memory", logger.ERROR, err)
 		return 0
 	}
 	return int(mem.Available >> 20)
@@ -205,7 +292,7 @@ func getMemoryMB() int {
 func getMemoryUsage() float64 {
 	mem, err := mem.VirtualMemory()
 	if err != nil {
-		logger.ErrorLogger().Printf("Error: %s", err.Error())
+		log.Error("Unable to read the memory usage", logger.ERROR, err)
 		return 100
 	}
 	return mem.UsedPercent
@@ -240,16 +327,21 @@ func getNetworkInfo() map[string]string {
 }
 
 func getPort() string {
-	port := os.Getenv("MY_PORT")
-	if port == "" {
-		port = "3000"
-	}
-	return port
+	return strconv.Itoa(config.Get().Node.Port)
 }
 
-// AddSupportedTechnology adds a supported technology to the node
-func (n *Node) AddSupportedTechnology(tech RuntimeType) {
-	n.Technology = append(n.Technology, tech)
+// AddSupportedTechnology adds a supported technology to the node, once
+func AddSupportedTechnology(tech RuntimeType) {
+	initNodeInfo()
+	nodeLock.Lock()
+	defer nodeLock.Unlock()
+	for _, supported := range node.Technology {
+		if supported == tech {
+			return
+		}
+	}
+	// never append to the array shared with the snapshots
+	node.Technology = append(append([]RuntimeType{}, node.Technology...), tech)
 }
 
 // GetSupportedTechnologyList returns the list of supported technologies
@@ -258,148 +350,14 @@ func (n *Node) GetSupportedTechnologyList() []RuntimeType {
 }
 
 // AddSupportedAddons adds a supported addon to the node
-func (n *Node) AddSupportedAddons(ext AddonType) {
-	n.SupportedAddons = append(n.SupportedAddons, ext)
+func AddSupportedAddons(ext AddonType) {
+	initNodeInfo()
+	nodeLock.Lock()
+	defer nodeLock.Unlock()
+	node.SupportedAddons = append(append([]AddonType{}, node.SupportedAddons...), ext)
 }
 
 // GetSupportedAddonsList returns the list of supported addons
 func (n *Node) GetSupportedAddonsList() []AddonType {
 	return n.SupportedAddons
 }
-
-func getGpuDriver() string {
-	n, err := gpu.NvsmiDeviceCount()
-	if err != nil {
-		return "-"
-	}
-
-	var queryResult string
-	for i := 0; i < n; i++ {
-		res, err := gpu.NvsmiQuery(fmt.Sprintf("%d", i), "driver_version")
-		if err != nil {
-			continue
-		}
-		queryResult = res
-	}
-
-	if queryResult != "" {
-		return "-"
-	}
-	return queryResult
-}
-
-func getGpuMemUsage() float64 {
-	n, err := gpu.NvsmiDeviceCount()
-	if err != nil || n == 0 {
-		return 0
-	}
-
-	totMem := 0.0
-	for i := 0; i < n; i++ {
-		res, err := gpu.NvsmiQuery(fmt.Sprintf("%d", i), "memory.used")
-		if err != nil {
-			return 0
-		}
-		totm := getTotGpuMem()
-		if totm >= 0 {
-			currmem, err := strconv.Atoi(res)
-			if err != nil {
-				return 0
-			}
-			totMem += float64(currmem) * 100 / getTotGpuMem()
-		}
-	}
-	return totMem / float64(n)
-}
-
-func getGpuCores() int {
-	n, err := gpu.NvsmiDeviceCount()
-	if err != nil || n == 0 {
-		return 0
-	}
-	return n
-}
-
-func getGpuUsage() float64 {
-	n, err := gpu.NvsmiDeviceCount()
-	if err != nil || n == 0 {
-		return 0
-	}
-
-	totUage := 0.0
-	for i := 0; i < n; i++ {
-		res, err := gpu.NvsmiQuery(fmt.Sprintf("%d", i), "utilization.gpu")
-		if err != nil {
-			return 0
-		}
-		gpuusage, err := strconv.Atoi(res)
-		if err != nil {
-			return 0
-		}
-		totUage += float64(gpuusage)
-	}
-	return totUage / float64(n)
-}
-
-func getTotGpuMem() float64 {
-	n, err := gpu.NvsmiDeviceCount()
-	if err != nil || n == 0 {
-		return 0
-	}
-
-	totMem := 0.0
-	for i := 0; i < n; i++ {
-		res, err := gpu.NvsmiQuery(fmt.Sprintf("%d", i), "memory.total")
-		if err != nil {
-			return 0
-		}
-		gpuMem, err := strconv.Atoi(res)
-		if err != nil {
-			return 0
-		}
-		totMem += float64(gpuMem)
-	}
-	return totMem
-}
-
-func getTotGpuMemFreeMB() float64 {
-	n, err := gpu.NvsmiDeviceCount()
-	if err != nil || n == 0 {
-		return 0
-	}
-
-	totMem := 0.0
-	for i := 0; i < n; i++ {
-		res, err := gpu.NvsmiQuery(fmt.Sprintf("%d", i), "memory.free")
-		if err != nil {
-			return 0
-		}
-		gpuMem, err := strconv.Atoi(res)
-		if err != nil {
-			return 0
-		}
-		totMem += float64(gpuMem)
-	}
-	return totMem
-}
-
-func getGpuTemp() float64 {
-	n, err := gpu.NvsmiDeviceCount()
-	if err != nil || n == 0 {
-		return 0
-	}
-
-	totTemp := 0.0
-	for i := 0; i < n; i++ {
-		res, err := gpu.NvsmiQuery(fmt.Sprintf("%d", i), "temperature.gpu")
-		if err != nil {
-			return 0
-		}
-		currTemp, err := strconv.Atoi(res)
-		if err != nil {
-			return 0
-		}
-		totTemp += float64(currTemp)
-	}
-	return totTemp / float64(n)
-}
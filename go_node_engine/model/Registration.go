@@ -0,0 +1,36 @@
+package model
+
+import "sync"
+
+// RegistrationState is the state of the node registration to the cluster orchestrator
+type RegistrationState string
+
+const (
+	// REGISTRATION_REGISTERING the node is performing the handshake, or waiting for the cluster to answer
+	REGISTRATION_REGISTERING RegistrationState = "registering"
+	// REGISTRATION_REGISTERED the node has a node ID and is connected to the cluster broker
+	REGISTRATION_REGISTERED RegistrationState = "registered"
+	// REGISTRATION_STANDALONE the node runs the services of a local manifest, without a cluster
+	REGISTRATION_STANDALONE RegistrationState = "standalone"
+)
+
+var registrationState = REGISTRATION_REGISTERING
+var registrationLock sync.RWMutex
+
+// SetRegistrationState updates the local registration state of the node
+func SetRegistrationState(state RegistrationState) {
+	registrationLock.Lock()
+	changed := registrationState != state
+	registrationState = state
+	registrationLock.Unlock()
+	if changed {
+		RecordEvent(Event{Type: EVENT_REGISTRATION, Message: string(state)})
+	}
+}
+
+// GetRegistrationState returns the local registration state of the node
+func GetRegistrationState() RegistrationState {
+	registrationLock.RLock()
+	defer registrationLock.RUnlock()
+	return registrationState
+}
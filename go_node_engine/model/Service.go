@@ -1,5 +1,7 @@
 package model
 
+import "fmt"
+
 // Service is the struct that describes the service
 type Service struct {
 	JobID           string   `json:"_id"`
@@ -12,25 +14,28 @@ type Service struct {
 	Status          string   `json:"status"`
 	Runtime         string   `json:"virtualization"`
 	StatusDetail    string   `json:"status_detail"`
+	StatusReason    string   `json:"status_reason"`
 	Vtpus           int      `json:"vtpus"`
 	Vgpus           int      `json:"vgpus"`
 	Vcpus           int      `json:"vcpus"`
 	Memory          int      `json:"memory"`
+	Storage         int      `json:"storage"`
 	UnikernelImages []string `json:"vm_images"`
 	Architectures   []string `json:"arch"`
 	Pid             int
 	OneShot         bool `json:"one_shot"`
 }
 
-// Resources is the struct that describes the resources
+// Resources is the struct that describes the resources.
+// Cpu is in percent, Memory and Disk in bytes. The peers of schema version 1 get the numbers as strings, see schema.
 type Resources struct {
-	Cpu      string `json:"cpu"`
-	Memory   string `json:"memory"`
-	Disk     string `json:"disk"`
-	Logs     string `json:"logs"`
-	Sname    string `json:"job_name"`
-	Runtime  string `json:"virtualization"`
-	Instance int    `json:"instance"`
+	Cpu      float64 `json:"cpu"`
+	Memory   float64 `json:"memory"`
+	Disk     int64   `json:"disk"`
+	Logs     string  `json:"logs"`
+	Sname    string  `json:"job_name"`
+	Runtime  string  `json:"virtualization"`
+	Instance int     `json:"instance"`
 }
 
 // ServiceStatus is the struct that describes the service status
@@ -42,3 +47,25 @@ const (
 	SERVICE_COMPLETED  = "COMPLETED"
 	SERVICE_UNDEPLOYED = "UNDEPLOYED"
 )
+
+// Phases of an in-place update, reported as status reason while the instance keeps its status
+const (
+	UPDATE_PULLING     = "UPDATE_PULLING"
+	UPDATE_STARTING    = "UPDATE_STARTING"
+	UPDATE_READINESS   = "UPDATE_READINESS"
+	UPDATE_STOPPING    = "UPDATE_STOPPING"
+	UPDATE_COMPLETED   = "UPDATE_COMPLETED"
+	UPDATE_ROLLED_BACK = "UPDATE_ROLLED_BACK"
+	UPDATE_FAILED      = "UPDATE_FAILED"
+)
+
+// Reasons of an instance paused or resumed by an operator, reported while the instance keeps its status
+const (
+	OPERATOR_PAUSED  = "OPERATOR_PAUSED"
+	OPERATOR_RESUMED = "OPERATOR_RESUMED"
+)
+
+// InstanceKey identifies an instance of a service on the node, e.g. in the ledgers, the runtimes and the admin API
+func InstanceKey(sname string, instance int) string {
+	return fmt.Sprintf("%s.instance.%d", sname, instance)
+}
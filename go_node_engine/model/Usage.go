@@ -0,0 +1,30 @@
+package model
+
+import "sync"
+
+// usage is the last resource usage sampled for each instance, by runtime
+var usage = make(map[RuntimeType]map[string]Resources)
+var usageLock sync.RWMutex
+
+// SetInstancesUsage records the last usage sampled by the runtime, replacing the previous sample
+func SetInstancesUsage(runtime RuntimeType, resources []Resources) {
+	sample := make(map[string]Resources, len(resources))
+	for _, res := range resources {
+		sample[InstanceKey(res.Sname, res.Instance)] = res
+	}
+	usageLock.Lock()
+	defer usageLock.Unlock()
+	usage[runtime] = sample
+}
+
+// GetInstanceUsage returns the last usage sampled for the instance, if any
+func GetInstanceUsage(sname string, instance int) (Resources, bool) {
+	usageLock.RLock()
+	defer usageLock.RUnlock()
+	for _, sample := range usage {
+		if res, found := sample[InstanceKey(sname, instance)]; found {
+			return res, true
+		}
+	}
+	return Resources{}, false
+}
@@ -0,0 +1,218 @@
+package gpu
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// DeviceAllocation describes an accelerator device and the instance it is currently assigned to.
+// Instance is empty when the device is free.
+type DeviceAllocation struct {
+	Index    int    `json:"index"`
+	BusID    string `json:"bus_id"`
+	Instance string `json:"instance"`
+}
+
+// Allocator keeps track of the accelerators assigned to each deployed instance.
+// Devices are identified by their bus ID, which stays the same when devices are added or removed.
+type Allocator struct {
+	devices []Device
+	owners  map[string]string
+	lent    map[string]string
+	lock    sync.Mutex
+}
+
+var allocators = make(map[AcceleratorKind]*Allocator)
+var allocatorsLock sync.Mutex
+
+// GetAllocator returns the node allocator for the given accelerator kind,
+// following the devices discovered by the collector
+func GetAllocator(kind AcceleratorKind) *Allocator {
+	devices := GetCollector().Collect().Devices(kind)
+	allocatorsLock.Lock()
+	allocator, found := allocators[kind]
+	if !found {
+		allocator = NewAllocator(nil)
+		allocators[kind] = allocator
+	}
+	allocatorsLock.Unlock()
+	allocator.SetDevices(devices)
+	return allocator
+}
+
+// NewAllocator creates an allocator managing the given devices
+func NewAllocator(devices []Device) *Allocator {
+	allocator := &Allocator{owners: make(map[string]string), lent: make(map[string]string)}
+	allocator.SetDevices(devices)
+	return allocator
+}
+
+// DeviceID returns the stable identifier of the device: its bus ID, or its index when the bus ID is unknown
+func DeviceID(device Device) string {
+	if device.BusID != "" {
+		return device.BusID
+	}
+	return fmt.Sprintf("index-%d", device.Index)
+}
+
+// SetDevices updates the devices managed by the allocator, e.g. after a device was plugged or removed.
+// The assignments of a removed device are kept until released, in case it comes back.
+func (a *Allocator) SetDevices(devices []Device) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.devices = append([]Device{}, devices...)
+}
+
+// Allocate assigns n distinct free devices to the given instance, returning their IDs.
+// The request is rejected if the instance already owns devices or if not enough devices are free.
+func (a *Allocator) Allocate(instance string, n int) ([]string, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	for _, owner := range a.owners {
+		if owner == instance {
+			return nil, fmt.Errorf("devices already allocated to %s", instance)
+		}
+	}
+
+	free := a.free()
+	if len(free) < n {
+		return nil, fmt.Errorf("requested %d devices, only %d of %d available", n, len(free), len(a.devices))
+	}
+
+	assigned := free[:n]
+	for _, id := range assigned {
+		a.owners[id] = instance
+	}
+	return assigned, nil
+}
+
+// AllocateFrom assigns n distinct devices to an instance replacing lender, e.g. the container of an update,
+// reusing the devices of lender before the free ones. The reused devices are lent: released by the instance
+// they go back to lender, until Keep makes them its own.
+func (a *Allocator) AllocateFrom(lender string, instance string, n int) ([]string, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	for _, owner := range a.owners {
+		if owner == instance {
+			return nil, fmt.Errorf("devices already allocated to %s", instance)
+		}
+	}
+
+	reused := a.assigned(lender)
+	if len(reused) > n {
+		reused = reused[:n]
+	}
+	free := a.free()
+	if len(reused)+len(free) < n {
+		return nil, fmt.Errorf("requested %d devices, %d reusable and %d of %d available", n, len(reused), len(free), len(a.devices))
+	}
+
+	assigned := append(reused, free[:n-len(reused)]...)
+	for _, id := range reused {
+		a.lent[id] = lender
+	}
+	for _, id := range assigned {
+		a.owners[id] = instance
+	}
+	return assigned, nil
+}
+
+// Keep makes the devices lent to the given instance its own
+func (a *Allocator) Keep(instance string) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	for _, id := range a.assigned(instance) {
+		delete(a.lent, id)
+	}
+}
+
+// Release frees all the devices assigned to the given instance, the lent ones go back to their lender
+func (a *Allocator) Release(instance string) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	for _, id := range a.assigned(instance) {
+		a.release(id)
+	}
+}
+
+// ReleaseDevices frees the given devices, if still assigned to the given instance
+func (a *Allocator) ReleaseDevices(instance string, ids []string) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	for _, id := range ids {
+		if a.owners[id] == instance {
+			a.release(id)
+		}
+	}
+}
+
+// Assigned returns the IDs of the devices currently assigned to the given instance
+func (a *Allocator) Assigned(instance string) []string {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return a.assigned(instance)
+}
+
+// Free returns the number of present devices not assigned to any instance
+func (a *Allocator) Free() int {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return len(a.free())
+}
+
+// Devices returns the allocation status of every present device
+func (a *Allocator) Devices() []DeviceAllocation {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	devices := make([]DeviceAllocation, len(a.devices))
+	for i, device := range a.devices {
+		devices[i] = DeviceAllocation{
+			Index:    device.Index,
+			BusID:    device.BusID,
+			Instance: a.owners[DeviceID(device)],
+		}
+	}
+	return devices
+}
+
+// free returns the IDs of the present devices not assigned, in discovery order
+func (a *Allocator) free() []string {
+	free := make([]string, 0, len(a.devices))
+	for _, device := range a.devices {
+		if _, used := a.owners[DeviceID(device)]; !used {
+			free = append(free, DeviceID(device))
+		}
+	}
+	return free
+}
+
+// assigned returns the sorted IDs of the devices assigned to the instance
+func (a *Allocator) assigned(instance string) []string {
+	ids := make([]string, 0)
+	for id, owner := range a.owners {
+		if owner == instance {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// release frees the device, or gives it back to the instance it was lent from
+func (a *Allocator) release(id string) {
+	if lender, lent := a.lent[id]; lent {
+		a.owners[id] = lender
+		delete(a.lent, id)
+		return
+	}
+	delete(a.owners, id)
+}
@@ -0,0 +1,129 @@
+package gpu
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func devices(busIDs ...string) []Device {
+	result := make([]Device, len(busIDs))
+	for i, busID := range busIDs {
+		result[i] = Device{Index: i, BusID: busID}
+	}
+	return result
+}
+
+func TestAllocateDistinctDevices(t *testing.T) {
+	alloc := NewAllocator(devices("0000:01:00.0", "0000:02:00.0", "0000:03:00.0"))
+	first, err := alloc.Allocate("a.instance.0", 2)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, first, []string{"0000:01:00.0", "0000:02:00.0"})
+	second, err := alloc.Allocate("b.instance.0", 1)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, second, []string{"0000:03:00.0"})
+	assert.Equal(t, alloc.Free(), 0)
+}
+
+func TestAllocateRejectedWhenNotEnoughDevices(t *testing.T) {
+	alloc := NewAllocator(devices("0000:01:00.0", "0000:02:00.0"))
+	_, err := alloc.Allocate("a.instance.0", 1)
+	assert.NilError(t, err)
+	_, err = alloc.Allocate("b.instance.0", 2)
+	assert.ErrorContains(t, err, "only 1 of 2 available")
+	assert.Equal(t, alloc.Free(), 1)
+}
+
+func TestAllocateRejectedWhenAlreadyAssigned(t *testing.T) {
+	alloc := NewAllocator(devices("0000:01:00.0", "0000:02:00.0"))
+	_, err := alloc.Allocate("a.instance.0", 1)
+	assert.NilError(t, err)
+	_, err = alloc.Allocate("a.instance.0", 1)
+	assert.ErrorContains(t, err, "already allocated")
+	assert.DeepEqual(t, alloc.Assigned("a.instance.0"), []string{"0000:01:00.0"})
+}
+
+func TestReleaseFreesDevices(t *testing.T) {
+	alloc := NewAllocator(devices("0000:01:00.0", "0000:02:00.0"))
+	_, _ = alloc.Allocate("a.instance.0", 2)
+	alloc.Release("a.instance.0")
+	assert.Equal(t, alloc.Free(), 2)
+	reused, err := alloc.Allocate("b.instance.0", 1)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, reused, []string{"0000:01:00.0"})
+	assert.DeepEqual(t, alloc.Devices(), []DeviceAllocation{
+		{Index: 0, BusID: "0000:01:00.0", Instance: "b.instance.0"},
+		{Index: 1, BusID: "0000:02:00.0", Instance: ""},
+	})
+}
+
+func TestReleaseDevicesFreesOnlyTheGivenDevices(t *testing.T) {
+	alloc := NewAllocator(devices("0000:01:00.0", "0000:02:00.0", "0000:03:00.0"))
+	_, _ = alloc.Allocate("a.instance.0", 1)
+	_, _ = alloc.Allocate("b.instance.0", 2)
+	alloc.ReleaseDevices("b.instance.0", []string{"0000:01:00.0", "0000:03:00.0"})
+	assert.DeepEqual(t, alloc.Assigned("a.instance.0"), []string{"0000:01:00.0"})
+	assert.DeepEqual(t, alloc.Assigned("b.instance.0"), []string{"0000:02:00.0"})
+}
+
+func TestAllocateFromReusesTheLenderDevices(t *testing.T) {
+	alloc := NewAllocator(devices("0000:01:00.0", "0000:02:00.0", "0000:03:00.0"))
+	_, _ = alloc.Allocate("a.instance.0", 2)
+	_, _ = alloc.Allocate("b.instance.0", 1)
+
+	// a replacement needing more devices than its lender holds takes the free ones too
+	_, err := alloc.AllocateFrom("b.instance.0", "b.instance.0.rev1", 2)
+	assert.ErrorContains(t, err, "1 reusable and 0 of 3 available")
+	reused, err := alloc.AllocateFrom("a.instance.0", "a.instance.0.rev1", 1)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, reused, []string{"0000:01:00.0"})
+	assert.DeepEqual(t, alloc.Assigned("a.instance.0"), []string{"0000:02:00.0"})
+
+	// released before Keep, the lent devices go back to the lender
+	alloc.Release("a.instance.0.rev1")
+	assert.DeepEqual(t, alloc.Assigned("a.instance.0"), []string{"0000:01:00.0", "0000:02:00.0"})
+
+	_, _ = alloc.AllocateFrom("a.instance.0", "a.instance.0.rev2", 1)
+	alloc.Keep("a.instance.0.rev2")
+	alloc.Release("a.instance.0")
+	alloc.ReleaseDevices("a.instance.0", []string{"0000:01:00.0"})
+	assert.DeepEqual(t, alloc.Assigned("a.instance.0.rev2"), []string{"0000:01:00.0"})
+	assert.Equal(t, alloc.Free(), 1)
+}
+
+func TestAllocateZeroDevices(t *testing.T) {
+	alloc := NewAllocator(nil)
+	gpus, err := alloc.Allocate("a.instance.0", 0)
+	assert.NilError(t, err)
+	assert.Equal(t, len(gpus), 0)
+}
+
+func TestSetDevicesKeepsAssignments(t *testing.T) {
+	alloc := NewAllocator(devices("0000:01:00.0", "0000:02:00.0"))
+	_, err := alloc.Allocate("a.instance.0", 1)
+	assert.NilError(t, err)
+
+	// a device is plugged before the assigned one, which changes the positions but not the bus IDs
+	alloc.SetDevices(devices("0000:00:10.0", "0000:01:00.0", "0000:02:00.0"))
+	assert.Equal(t, alloc.Free(), 2)
+	assert.DeepEqual(t, alloc.Devices(), []DeviceAllocation{
+		{Index: 0, BusID: "0000:00:10.0", Instance: ""},
+		{Index: 1, BusID: "0000:01:00.0", Instance: "a.instance.0"},
+		{Index: 2, BusID: "0000:02:00.0", Instance: ""},
+	})
+	second, err := alloc.Allocate("b.instance.0", 2)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, second, []string{"0000:00:10.0", "0000:02:00.0"})
+
+	// the assignments of a removed device are kept until released
+	alloc.SetDevices(devices("0000:00:10.0", "0000:02:00.0"))
+	assert.Equal(t, alloc.Free(), 0)
+	assert.DeepEqual(t, alloc.Assigned("a.instance.0"), []string{"0000:01:00.0"})
+	alloc.Release("a.instance.0")
+	assert.Equal(t, len(alloc.Assigned("a.instance.0")), 0)
+}
+
+func TestDeviceID(t *testing.T) {
+	assert.Equal(t, DeviceID(Device{Index: 2, BusID: "0000:01:00.0"}), "0000:01:00.0")
+	assert.Equal(t, DeviceID(Device{Index: 2}), "index-2")
+}
@@ -0,0 +1,153 @@
+package gpu
+
+import (
+	"sync"
+	"time"
+)
+
+// DEFAULT_CACHE_TTL is the time a collected snapshot is reused before querying the providers again
+const DEFAULT_CACHE_TTL = time.Second
+
+// Snapshot is the status of all the accelerators of the node at a given time
+type Snapshot struct {
+	Gpus      []Device
+	Tpus      []Device
+	Collected time.Time
+}
+
+// Collector queries all the available providers at most once per cache TTL
+type Collector struct {
+	providers []AcceleratorProvider
+	ttl       time.Duration
+	snapshot  *Snapshot
+	lock      sync.Mutex
+}
+
+var collector *Collector
+var collectorOnce sync.Once
+
+// GetCollector returns the node collector, backed by the NVIDIA, AMD, Intel and Edge TPU providers
+func GetCollector() *Collector {
+	collectorOnce.Do(func() {
+		collector = NewCollector(
+			DEFAULT_CACHE_TTL,
+			NewNvidiaProvider(),
+			NewAmdProvider(),
+			NewIntelProvider(),
+			NewEdgeTpuProvider(),
+		)
+	})
+	return collector
+}
+
+// NewCollector creates a collector over the given providers
+func NewCollector(ttl time.Duration, providers ...AcceleratorProvider) *Collector {
+	return &Collector{
+		providers: providers,
+		ttl:       ttl,
+	}
+}
+
+// SetCacheTTL changes how long a snapshot is cached, usually aligned to the monitoring cycle
+func (c *Collector) SetCacheTTL(ttl time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.ttl = ttl
+}
+
+// Collect returns the cached snapshot, refreshing it with one query per provider when expired
+func (c *Collector) Collect() Snapshot {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.snapshot != nil && time.Since(c.snapshot.Collected) < c.ttl {
+		return *c.snapshot
+	}
+
+	snapshot := Snapshot{
+		Gpus:      make([]Device, 0),
+		Tpus:      make([]Device, 0),
+		Collected: time.Now(),
+	}
+	for _, provider := range c.providers {
+		if !provider.Available() {
+			continue
+		}
+		devices, err := provider.Devices()
+		if err != nil {
+			continue
+		}
+		switch provider.Kind() {
+		case GPU:
+			snapshot.Gpus = append(snapshot.Gpus, devices...)
+		case TPU:
+			snapshot.Tpus = append(snapshot.Tpus, devices...)
+		}
+	}
+	c.snapshot = &snapshot
+	return snapshot
+}
+
+// Devices returns the devices of the given kind, ordered as indexed by the allocator
+func (s Snapshot) Devices(kind AcceleratorKind) []Device {
+	if kind == TPU {
+		return s.Tpus
+	}
+	return s.Gpus
+}
+
+// Driver returns the driver version of the first GPU, "-" when no GPU is present
+func (s Snapshot) Driver() string {
+	for _, device := range s.Gpus {
+		if device.Driver != "" {
+			return device.Driver
+		}
+	}
+	return "-"
+}
+
+// GpuUsage returns the average utilization percentage of the GPUs
+func (s Snapshot) GpuUsage() float64 {
+	if len(s.Gpus) == 0 {
+		return 0
+	}
+	tot := 0.0
+	for _, device := range s.Gpus {
+		tot += device.Utilization
+	}
+	return tot / float64(len(s.Gpus))
+}
+
+// GpuMemUsage returns the average memory usage percentage of the GPUs
+func (s Snapshot) GpuMemUsage() float64 {
+	if len(s.Gpus) == 0 {
+		return 0
+	}
+	tot := 0.0
+	for _, device := range s.Gpus {
+		if device.MemTotalMB > 0 {
+			tot += device.MemUsedMB * 100 / device.MemTotalMB
+		}
+	}
+	return tot / float64(len(s.Gpus))
+}
+
+// GpuMemFreeMB returns the free memory summed across the GPUs
+func (s Snapshot) GpuMemFreeMB() float64 {
+	tot := 0.0
+	for _, device := range s.Gpus {
+		tot += device.MemFreeMB
+	}
+	return tot
+}
+
+// GpuTemp returns the average temperature of the GPUs
+func (s Snapshot) GpuTemp() float64 {
+	if len(s.Gpus) == 0 {
+		return 0
+	}
+	tot := 0.0
+	for _, device := range s.Gpus {
+		tot += device.Temperature
+	}
+	return tot / float64(len(s.Gpus))
+}
@@ -0,0 +1,86 @@
+package gpu
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+)
+
+func TestCollectorCachesSnapshot(t *testing.T) {
+	provider := &FakeProvider{VendorName: "fake", Devs: []Device{{Index: 0, Utilization: 50}}}
+	collector := NewCollector(time.Hour, provider)
+	first := collector.Collect()
+	second := collector.Collect()
+	assert.Equal(t, provider.Queries(), 1)
+	assert.Equal(t, len(first.Gpus), 1)
+	assert.Equal(t, first.Collected, second.Collected)
+}
+
+func TestCollectorRefreshesExpiredSnapshot(t *testing.T) {
+	provider := &FakeProvider{VendorName: "fake"}
+	collector := NewCollector(0, provider)
+	collector.Collect()
+	collector.Collect()
+	assert.Equal(t, provider.Queries(), 2)
+}
+
+func TestCollectorSkipsFailingAndUnavailableProviders(t *testing.T) {
+	broken := &FakeProvider{VendorName: "broken", Err: errors.New("boom")}
+	missing := &FakeProvider{VendorName: "missing", Unavailable: true, Devs: []Device{{}}}
+	tpu := &FakeProvider{VendorName: "coral", DeviceKind: TPU, Devs: []Device{{Kind: TPU}}}
+	snapshot := NewCollector(time.Hour, broken, missing, tpu).Collect()
+	assert.Equal(t, len(snapshot.Gpus), 0)
+	assert.Equal(t, len(snapshot.Tpus), 1)
+	assert.Equal(t, missing.Queries(), 0)
+	assert.Equal(t, snapshot.Driver(), "-")
+}
+
+func TestSnapshotAggregates(t *testing.T) {
+	snapshot := Snapshot{Gpus: []Device{
+		{Driver: "535.104", Utilization: 20, MemTotalMB: 1000, MemUsedMB: 250, MemFreeMB: 750, Temperature: 40},
+		{Driver: "535.104", Utilization: 60, MemTotalMB: 1000, MemUsedMB: 750, MemFreeMB: 250, Temperature: 60},
+	}}
+	assert.Equal(t, snapshot.Driver(), "535.104")
+	assert.Equal(t, snapshot.GpuUsage(), 40.0)
+	assert.Equal(t, snapshot.GpuMemUsage(), 50.0)
+	assert.Equal(t, snapshot.GpuMemFreeMB(), 1000.0)
+	assert.Equal(t, snapshot.GpuTemp(), 50.0)
+}
+
+func TestParseNvsmiOutput(t *testing.T) {
+	output := "0, NVIDIA A2, 535.104.05, 00000000:01:00.0, 12, 15356, 1024, 14332, 41\n" +
+		"1, NVIDIA A2, 535.104.05, 00000000:02:00.0, [N/A], 15356, 0, 15356, 39\n"
+	devices, err := parseNvsmiOutput(output)
+	assert.NilError(t, err)
+	assert.Equal(t, len(devices), 2)
+	assert.Equal(t, devices[0].Driver, "535.104.05")
+	assert.Equal(t, devices[0].Utilization, 12.0)
+	assert.Equal(t, devices[1].Index, 1)
+	assert.Equal(t, devices[1].Utilization, 0.0)
+	assert.Equal(t, devices[1].MemFreeMB, 15356.0)
+}
+
+func TestParseNvsmiOutputMalformed(t *testing.T) {
+	_, err := parseNvsmiOutput("0, NVIDIA A2\n")
+	assert.ErrorContains(t, err, "unexpected nvidia-smi output")
+}
+
+func TestParseRocmOutput(t *testing.T) {
+	output := []byte(`{
+		"card1": {"Card series": "Radeon RX 6800", "PCI Bus": "0000:04:00.0", "GPU use (%)": "30",
+			"VRAM Total Memory (B)": "2147483648", "VRAM Total Used Memory (B)": "1073741824",
+			"Temperature (Sensor edge) (C)": "45.0"},
+		"card0": {"Card series": "Radeon RX 6800", "PCI Bus": "0000:03:00.0", "GPU use (%)": "10"},
+		"system": {"Driver version": "6.2.0"}
+	}`)
+	devices, err := parseRocmOutput(output)
+	assert.NilError(t, err)
+	assert.Equal(t, len(devices), 2)
+	assert.Equal(t, devices[0].Index, 0)
+	assert.Equal(t, devices[1].Driver, "6.2.0")
+	assert.Equal(t, devices[1].MemTotalMB, 2048.0)
+	assert.Equal(t, devices[1].MemFreeMB, 1024.0)
+	assert.Equal(t, devices[1].Temperature, 45.0)
+}
@@ -0,0 +1,109 @@
+package gpu
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// edgeTpuUsbIDs are the vendor:product pairs of the Coral USB accelerator, before and after the firmware is loaded
+var edgeTpuUsbIDs = map[string]bool{
+	"1a6e:089a": true,
+	"18d1:9302": true,
+}
+
+// EdgeTpuProvider detects Edge TPUs (Coral PCIe/M.2 and USB) and NPUs exposed through the kernel accel subsystem
+type EdgeTpuProvider struct {
+	sysDir string
+	devDir string
+}
+
+// NewEdgeTpuProvider returns a provider scanning the host sysfs and device tree
+func NewEdgeTpuProvider() *EdgeTpuProvider {
+	return &EdgeTpuProvider{sysDir: "/sys", devDir: "/dev"}
+}
+
+// Vendor returns the provider vendor
+func (p *EdgeTpuProvider) Vendor() string {
+	return "edgetpu"
+}
+
+// Kind returns the class of accelerators of the provider
+func (p *EdgeTpuProvider) Kind() AcceleratorKind {
+	return TPU
+}
+
+// Available reports whether sysfs can be scanned
+func (p *EdgeTpuProvider) Available() bool {
+	_, err := os.Stat(p.sysDir)
+	return err == nil
+}
+
+// Devices lists the Coral PCIe, Coral USB and accel NPU devices, in this order
+func (p *EdgeTpuProvider) Devices() ([]Device, error) {
+	devices := make([]Device, 0)
+
+	// Coral PCIe/M.2 modules, bound to the gasket/apex driver
+	apex, _ := filepath.Glob(filepath.Join(p.sysDir, "class", "apex", "apex_*"))
+	sort.Strings(apex)
+	for _, dev := range apex {
+		devices = append(devices, Device{
+			Index:       len(devices),
+			Kind:        TPU,
+			Vendor:      "google",
+			Name:        "Coral Edge TPU (PCIe)",
+			Driver:      linkBase(filepath.Join(dev, "device", "driver")),
+			BusID:       linkBase(filepath.Join(dev, "device")),
+			DevicePaths: []string{filepath.Join(p.devDir, filepath.Base(dev))},
+		})
+	}
+
+	// Coral USB accelerators
+	usb, _ := filepath.Glob(filepath.Join(p.sysDir, "bus", "usb", "devices", "*"))
+	sort.Strings(usb)
+	for _, dev := range usb {
+		id := fmt.Sprintf("%s:%s", readSysfs(filepath.Join(dev, "idVendor")), readSysfs(filepath.Join(dev, "idProduct")))
+		if !edgeTpuUsbIDs[id] {
+			continue
+		}
+		busnum := atoiOrZero(readSysfs(filepath.Join(dev, "busnum")))
+		devnum := atoiOrZero(readSysfs(filepath.Join(dev, "devnum")))
+		devices = append(devices, Device{
+			Index:       len(devices),
+			Kind:        TPU,
+			Vendor:      "google",
+			Name:        "Coral Edge TPU (USB)",
+			Driver:      "libedgetpu",
+			BusID:       filepath.Base(dev),
+			DevicePaths: []string{filepath.Join(p.devDir, "bus", "usb", fmt.Sprintf("%03d", busnum), fmt.Sprintf("%03d", devnum))},
+		})
+	}
+
+	// NPUs registered in the accel subsystem (e.g. intel_vpu, amdxdna)
+	accel, _ := filepath.Glob(filepath.Join(p.sysDir, "class", "accel", "accel*"))
+	sort.Strings(accel)
+	for _, dev := range accel {
+		driver := linkBase(filepath.Join(dev, "device", "driver"))
+		devices = append(devices, Device{
+			Index:       len(devices),
+			Kind:        TPU,
+			Vendor:      driver,
+			Name:        fmt.Sprintf("NPU %s", readSysfs(filepath.Join(dev, "device", "device"))),
+			Driver:      driver,
+			BusID:       linkBase(filepath.Join(dev, "device")),
+			DevicePaths: []string{filepath.Join(p.devDir, "accel", filepath.Base(dev))},
+		})
+	}
+	return devices, nil
+}
+
+func atoiOrZero(value string) int {
+	number, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return 0
+	}
+	return number
+}
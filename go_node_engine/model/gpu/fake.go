@@ -0,0 +1,53 @@
+package gpu
+
+import "sync"
+
+// FakeProvider is an in-memory AcceleratorProvider meant for tests.
+// It returns the configured devices, or Err when set, and counts the queries it receives.
+type FakeProvider struct {
+	VendorName  string
+	DeviceKind  AcceleratorKind
+	Devs        []Device
+	Err         error
+	Unavailable bool
+	queries     int
+	lock        sync.Mutex
+}
+
+// Vendor returns the configured vendor name
+func (p *FakeProvider) Vendor() string {
+	return p.VendorName
+}
+
+// Kind returns the configured accelerator kind, GPU by default
+func (p *FakeProvider) Kind() AcceleratorKind {
+	if p.DeviceKind == "" {
+		return GPU
+	}
+	return p.DeviceKind
+}
+
+// Available reports the provider as present unless Unavailable is set
+func (p *FakeProvider) Available() bool {
+	return !p.Unavailable
+}
+
+// Devices returns a copy of the configured devices
+func (p *FakeProvider) Devices() ([]Device, error) {
+	p.lock.Lock()
+	p.queries++
+	p.lock.Unlock()
+	if p.Err != nil {
+		return nil, p.Err
+	}
+	devices := make([]Device, len(p.Devs))
+	copy(devices, p.Devs)
+	return devices, nil
+}
+
+// Queries returns how many times Devices has been called
+func (p *FakeProvider) Queries() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.queries
+}
@@ -0,0 +1,94 @@
+package gpu
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const intelVendorID = "0x8086"
+
+// IntelProvider discovers the Intel GPUs from the DRM sysfs tree.
+// Intel does not ship a lightweight query tool on every platform, hence only
+// the device identity, driver and temperature (when exposed by hwmon) are reported.
+type IntelProvider struct {
+	sysDir string
+	devDir string
+}
+
+// NewIntelProvider returns a provider reading the DRM devices from sysfs
+func NewIntelProvider() *IntelProvider {
+	return &IntelProvider{sysDir: "/sys", devDir: "/dev"}
+}
+
+// Vendor returns the provider vendor
+func (p *IntelProvider) Vendor() string {
+	return "intel"
+}
+
+// Kind returns the class of accelerators of the provider
+func (p *IntelProvider) Kind() AcceleratorKind {
+	return GPU
+}
+
+// Available reports whether the DRM sysfs tree is present
+func (p *IntelProvider) Available() bool {
+	_, err := os.Stat(filepath.Join(p.sysDir, "class", "drm"))
+	return err == nil
+}
+
+// Devices lists the Intel cards with a single scan of the DRM class directory
+func (p *IntelProvider) Devices() ([]Device, error) {
+	cards, err := filepath.Glob(filepath.Join(p.sysDir, "class", "drm", "card*"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(cards)
+
+	devices := make([]Device, 0)
+	for _, card := range cards {
+		name := filepath.Base(card)
+		// skip connectors such as card0-HDMI-A-1
+		if strings.Contains(name, "-") {
+			continue
+		}
+		if readSysfs(filepath.Join(card, "device", "vendor")) != intelVendorID {
+			continue
+		}
+		device := Device{
+			Index:  len(devices),
+			Kind:   GPU,
+			Vendor: "intel",
+			Name:   readSysfs(filepath.Join(card, "device", "device")),
+			Driver: linkBase(filepath.Join(card, "device", "driver")),
+			BusID:  linkBase(filepath.Join(card, "device")),
+		}
+		hwmon, _ := filepath.Glob(filepath.Join(card, "device", "hwmon", "hwmon*", "temp1_input"))
+		if len(hwmon) > 0 {
+			device.Temperature = parseMetric(readSysfs(hwmon[0])) / 1000
+		}
+		render, _ := filepath.Glob(filepath.Join(card, "device", "drm", "renderD*"))
+		for _, node := range render {
+			device.DevicePaths = append(device.DevicePaths, filepath.Join(p.devDir, "dri", filepath.Base(node)))
+		}
+		devices = append(devices, device)
+	}
+	return devices, nil
+}
+
+func readSysfs(path string) string {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(content))
+}
+
+func linkBase(path string) string {
+	target, err := os.Readlink(path)
+	if err != nil {
+		return ""
+	}
+	return filepath.Base(target)
+}
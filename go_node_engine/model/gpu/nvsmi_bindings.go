@@ -9,39 +9,91 @@ import (
 
 const (
 	bin       = "nvidia-smi"
-	gpuArg    = "--id="
 	queryArg  = "--query-gpu="
 	formatArg = "--format=csv,noheader,nounits"
 )
 
-// NvsmiQuery Query to nvidia-smi. Refer to https://nvidia.custhelp.com/app/answers/detail/a_id/3751/~/useful-nvidia-smi-queries
-// For the available query
-func NvsmiQuery(id string, query string) (string, error) {
-	var out bytes.Buffer
+// nvsmiFields are queried all at once for every device, the order matches the columns of the csv output
+var nvsmiFields = []string{
+	"index",
+	"name",
+	"driver_version",
+	"pci.bus_id",
+	"utilization.gpu",
+	"memory.total",
+	"memory.used",
+	"memory.free",
+	"temperature.gpu",
+}
 
-	cmd := exec.Command(bin, fmt.Sprintf("%s%s", gpuArg, id), fmt.Sprintf("%s%s", queryArg, query), formatArg)
-	cmd.Stdout = &out
+// NvidiaProvider collects the NVIDIA GPUs metrics through nvidia-smi
+type NvidiaProvider struct {
+	bin string
+}
 
-	err := cmd.Run()
-	if err != nil {
-		return "", err
-	}
-	return strings.TrimSpace(out.String()), nil
+// NewNvidiaProvider returns a provider backed by the nvidia-smi binary
+func NewNvidiaProvider() *NvidiaProvider {
+	return &NvidiaProvider{bin: bin}
 }
 
-// NvsmiDeviceCount Counts the available nvidia-smi compatible GPUs in the machine
-func NvsmiDeviceCount() (int, error) {
+// Vendor returns the provider vendor
+func (p *NvidiaProvider) Vendor() string {
+	return "nvidia"
+}
+
+// Kind returns the class of accelerators of the provider
+func (p *NvidiaProvider) Kind() AcceleratorKind {
+	return GPU
+}
+
+// Available reports whether nvidia-smi is installed
+func (p *NvidiaProvider) Available() bool {
+	_, err := exec.LookPath(p.bin)
+	return err == nil
+}
+
+// Devices queries all the NVIDIA GPUs with a single nvidia-smi invocation.
+// Refer to https://nvidia.custhelp.com/app/answers/detail/a_id/3751/~/useful-nvidia-smi-queries
+// For the available query fields
+func (p *NvidiaProvider) Devices() ([]Device, error) {
 	var out bytes.Buffer
 
-	query := "gpu_name"
-	cmd := exec.Command(bin, fmt.Sprintf("%s%s", queryArg, query), formatArg)
+	cmd := exec.Command(p.bin, fmt.Sprintf("%s%s", queryArg, strings.Join(nvsmiFields, ",")), formatArg)
 	cmd.Stdout = &out
 
 	err := cmd.Run()
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
+	return parseNvsmiOutput(out.String())
+}
 
-	nvSmi := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
-	return len(nvSmi), nil
+func parseNvsmiOutput(output string) ([]Device, error) {
+	devices := make([]Device, 0)
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		columns := strings.Split(line, ",")
+		if len(columns) != len(nvsmiFields) {
+			return nil, fmt.Errorf("unexpected nvidia-smi output: %s", line)
+		}
+		for i := range columns {
+			columns[i] = strings.TrimSpace(columns[i])
+		}
+		devices = append(devices, Device{
+			Index:       int(parseMetric(columns[0])),
+			Kind:        GPU,
+			Vendor:      "nvidia",
+			Name:        columns[1],
+			Driver:      columns[2],
+			BusID:       columns[3],
+			Utilization: parseMetric(columns[4]),
+			MemTotalMB:  parseMetric(columns[5]),
+			MemUsedMB:   parseMetric(columns[6]),
+			MemFreeMB:   parseMetric(columns[7]),
+			Temperature: parseMetric(columns[8]),
+		})
+	}
+	return devices, nil
 }
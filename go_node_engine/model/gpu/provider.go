@@ -0,0 +1,56 @@
+package gpu
+
+import (
+	"strconv"
+	"strings"
+)
+
+// AcceleratorKind is the class of accelerator exposed by a provider
+type AcceleratorKind string
+
+// AcceleratorKind constants
+const (
+	GPU AcceleratorKind = "gpu"
+	TPU AcceleratorKind = "tpu"
+)
+
+// Device describes a single accelerator device and its last sampled metrics.
+// Index is the position of the device as seen by the vendor tooling,
+// DevicePaths are the host device nodes required to use it inside a container.
+type Device struct {
+	Index       int             `json:"index"`
+	Kind        AcceleratorKind `json:"kind"`
+	Vendor      string          `json:"vendor"`
+	Name        string          `json:"name"`
+	Driver      string          `json:"driver"`
+	BusID       string          `json:"bus_id"`
+	Utilization float64         `json:"utilization"`
+	MemTotalMB  float64         `json:"mem_total_mb"`
+	MemUsedMB   float64         `json:"mem_used_mb"`
+	MemFreeMB   float64         `json:"mem_free_mb"`
+	Temperature float64         `json:"temperature"`
+	DevicePaths []string        `json:"-"`
+}
+
+// AcceleratorProvider discovers the accelerators of a single vendor and samples their metrics
+type AcceleratorProvider interface {
+	// Vendor returns the name of the vendor handled by the provider
+	Vendor() string
+	// Kind returns the class of accelerators discovered by the provider
+	Kind() AcceleratorKind
+	// Available reports whether the vendor tooling or drivers are present on the node
+	Available() bool
+	// Devices returns all the devices of the vendor, collected with a single batched query
+	Devices() ([]Device, error)
+}
+
+// parseMetric converts a metric reported by vendor tools to float, unsupported values such as [N/A] default to 0
+func parseMetric(value string) float64 {
+	value = strings.TrimSpace(value)
+	value = strings.TrimSuffix(value, "%")
+	metric, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0
+	}
+	return metric
+}
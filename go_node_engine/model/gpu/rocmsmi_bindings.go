@@ -0,0 +1,135 @@
+package gpu
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const rocmBin = "rocm-smi"
+
+// rocmArgs query every metric of every AMD device at once
+var rocmArgs = []string{
+	"--showproductname",
+	"--showdriverversion",
+	"--showbus",
+	"--showuse",
+	"--showmeminfo", "vram",
+	"--showtemp",
+	"--json",
+}
+
+// AmdProvider collects the AMD GPUs metrics through rocm-smi
+type AmdProvider struct {
+	bin    string
+	devDir string
+}
+
+// NewAmdProvider returns a provider backed by the rocm-smi binary
+func NewAmdProvider() *AmdProvider {
+	return &AmdProvider{bin: rocmBin, devDir: "/dev"}
+}
+
+// Vendor returns the provider vendor
+func (p *AmdProvider) Vendor() string {
+	return "amd"
+}
+
+// Kind returns the class of accelerators of the provider
+func (p *AmdProvider) Kind() AcceleratorKind {
+	return GPU
+}
+
+// Available reports whether rocm-smi is installed
+func (p *AmdProvider) Available() bool {
+	_, err := exec.LookPath(p.bin)
+	return err == nil
+}
+
+// Devices queries all the AMD GPUs with a single rocm-smi invocation
+func (p *AmdProvider) Devices() ([]Device, error) {
+	var out bytes.Buffer
+
+	cmd := exec.Command(p.bin, rocmArgs...)
+	cmd.Stdout = &out
+
+	err := cmd.Run()
+	if err != nil {
+		return nil, err
+	}
+	devices, err := parseRocmOutput(out.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	for i := range devices {
+		devices[i].DevicePaths = append([]string{filepath.Join(p.devDir, "kfd")}, renderNode(p.devDir, devices[i].BusID)...)
+	}
+	return devices, nil
+}
+
+func parseRocmOutput(output []byte) ([]Device, error) {
+	cards := make(map[string]map[string]string)
+	err := json.Unmarshal(output, &cards)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected rocm-smi output: %v", err)
+	}
+
+	driver := ""
+	if system, found := cards["system"]; found {
+		driver = system["Driver version"]
+	}
+
+	devices := make([]Device, 0)
+	for card, metrics := range cards {
+		if !strings.HasPrefix(card, "card") {
+			continue
+		}
+		index, err := strconv.Atoi(strings.TrimPrefix(card, "card"))
+		if err != nil {
+			continue
+		}
+		device := Device{
+			Index:  index,
+			Kind:   GPU,
+			Vendor: "amd",
+			Driver: driver,
+			Name:   metrics["Card series"],
+			BusID:  metrics["PCI Bus"],
+		}
+		for key, value := range metrics {
+			switch {
+			case key == "GPU use (%)":
+				device.Utilization = parseMetric(value)
+			case key == "VRAM Total Memory (B)":
+				device.MemTotalMB = parseMetric(value) / (1 << 20)
+			case key == "VRAM Total Used Memory (B)":
+				device.MemUsedMB = parseMetric(value) / (1 << 20)
+			case strings.HasPrefix(key, "Temperature (Sensor edge)"):
+				device.Temperature = parseMetric(value)
+			}
+		}
+		device.MemFreeMB = device.MemTotalMB - device.MemUsedMB
+		devices = append(devices, device)
+	}
+	sort.Slice(devices, func(i, j int) bool {
+		return devices[i].Index < devices[j].Index
+	})
+	return devices, nil
+}
+
+// renderNode resolves the DRM render node of the device attached to the given PCI bus
+func renderNode(devDir string, busID string) []string {
+	if busID == "" {
+		return nil
+	}
+	node, err := filepath.EvalSymlinks(filepath.Join(devDir, "dri", "by-path", fmt.Sprintf("pci-%s-render", strings.ToLower(busID))))
+	if err != nil {
+		return nil
+	}
+	return []string{node}
+}
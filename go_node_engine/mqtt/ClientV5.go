@@ -0,0 +1,369 @@
+package mqtt
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"go_node_engine/logger"
+	"go_node_engine/requests"
+	"go_node_engine/schema"
+	"sync"
+	"time"
+
+	v5packets "github.com/eclipse/paho.golang/packets"
+	"github.com/eclipse/paho.golang/paho"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/eclipse/paho.mqtt.golang/packets"
+)
+
+// errUnsupportedProtocol is returned when the broker does not speak MQTT 5
+var errUnsupportedProtocol = errors.New("broker does not support MQTT 5")
+
+// clientV5 is an MQTT 5 client behind the same interface as the 3.1.1 one, configured by the same options,
+// so that the router, the outbox and the presence work with both protocol versions.
+// Every message is delivered to the default publish handler, the subscription callbacks are ignored.
+type clientV5 struct {
+	opts    *mqtt.ClientOptions
+	backoff requests.Backoff
+	client  *paho.Client
+	closed  bool
+	lock    sync.Mutex
+}
+
+func newClientV5(opts *mqtt.ClientOptions, backoff requests.Backoff) *clientV5 {
+	return &clientV5{opts: opts, backoff: backoff}
+}
+
+func (c *clientV5) IsConnected() bool {
+	return c.IsConnectionOpen()
+}
+
+func (c *clientV5) IsConnectionOpen() bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.client != nil
+}
+
+func (c *clientV5) Connect() mqtt.Token {
+	return runToken(func() error {
+		err := c.connect()
+		if err == nil && c.opts.OnConnect != nil {
+			go c.opts.OnConnect(c)
+		}
+		return err
+	})
+}
+
+func (c *clientV5) connect() error {
+	if len(c.opts.Servers) == 0 {
+		return errors.New("no broker to connect to")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.opts.ConnectTimeout)
+	defer cancel()
+	conn, err := dialBroker(ctx, c.opts.Servers[0], c.opts.TLSConfig)
+	if err != nil {
+		return err
+	}
+
+	var client *paho.Client
+	client = paho.NewClient(paho.ClientConfig{
+		Conn:   v5packets.NewThreadSafeConn(conn),
+		Router: paho.NewSingleHandlerRouter(c.route),
+		OnClientError: func(err error) {
+			c.connectionLost(client, err)
+		},
+		OnServerDisconnect: func(disconnect *paho.Disconnect) {
+			c.connectionLost(client, fmt.Errorf("disconnected by the broker, reason code %d", disconnect.ReasonCode))
+		},
+	})
+
+	username, password := c.opts.Username, c.opts.Password
+	if c.opts.CredentialsProvider != nil {
+		username, password = c.opts.CredentialsProvider()
+	}
+	connect := &paho.Connect{
+		ClientID:     c.opts.ClientID,
+		KeepAlive:    uint16(c.opts.KeepAlive),
+		CleanStart:   c.opts.CleanSession,
+		Username:     username,
+		UsernameFlag: username != "",
+		Password:     []byte(password),
+		PasswordFlag: password != "",
+	}
+	if c.opts.WillEnabled {
+		connect.WillMessage = &paho.WillMessage{
+			Retain:  c.opts.WillRetained,
+			QoS:     c.opts.WillQos,
+			Topic:   c.opts.WillTopic,
+			Payload: c.opts.WillPayload,
+		}
+	}
+	connack, err := client.Connect(ctx, connect)
+	if err != nil {
+		return connackError(connack, err)
+	}
+	if connack.ReasonCode != 0 {
+		_ = client.Disconnect(&paho.Disconnect{})
+		return connackError(connack, nil)
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.closed {
+		_ = client.Disconnect(&paho.Disconnect{})
+		return errors.New("client closed")
+	}
+	c.client = client
+	return nil
+}
+
+// connackError maps the MQTT 5 refusals to the 3.1.1 errors, so that both clients are handled alike.
+// Only a refused protocol version means that the broker does not support MQTT 5: reason code 0x84, or any
+// code below 0x80, which MQTT 5 does not define for a refusal and a 3.1.1 broker answers with.
+func connackError(connack *paho.Connack, err error) error {
+	if connack == nil || connack.ReasonCode == 0 {
+		return err
+	}
+	switch code := connack.ReasonCode; {
+	case code == 0x84 || code < 0x80:
+		return errUnsupportedProtocol
+	case code == 0x86 || code == 0x8C:
+		return packets.ErrorRefusedBadUsernameOrPassword
+	case code == 0x87:
+		return packets.ErrorRefusedNotAuthorised
+	default:
+		return fmt.Errorf("connection refused by the broker, reason code 0x%02X", code)
+	}
+}
+
+// connectionLost notifies the loss of the given connection, once, and reconnects if enabled
+func (c *clientV5) connectionLost(client *paho.Client, err error) {
+	c.lock.Lock()
+	if c.client != client || c.closed {
+		c.lock.Unlock()
+		return
+	}
+	c.client = nil
+	c.lock.Unlock()
+
+	if c.opts.OnConnectionLost != nil {
+		go c.opts.OnConnectionLost(c, err)
+	}
+	if c.opts.AutoReconnect {
+		go c.reconnect()
+	}
+}
+
+func (c *clientV5) reconnect() {
+	for attempt := 0; ; attempt++ {
+		time.Sleep(c.backoff.Delay(attempt))
+		c.lock.Lock()
+		closed := c.closed
+		c.lock.Unlock()
+		if closed {
+			return
+		}
+		err := c.connect()
+		if err == nil {
+			if c.opts.OnConnect != nil {
+				go c.opts.OnConnect(c)
+			}
+			return
+		}
+		if errors.Is(err, packets.ErrorRefusedNotAuthorised) || errors.Is(err, packets.ErrorRefusedBadUsernameOrPassword) {
+			ReRegister(fmt.Sprintf("broker refused the reconnection, %v", err))
+			return
+		}
+		log.Error("Unable to reconnect to the MQTT broker", logger.ERROR, err)
+	}
+}
+
+func (c *clientV5) Disconnect(quiesce uint) {
+	c.lock.Lock()
+	client := c.client
+	c.client = nil
+	c.closed = true
+	c.lock.Unlock()
+	if client != nil {
+		// a normal disconnection, the broker does not publish the will
+		if err := client.Disconnect(&paho.Disconnect{ReasonCode: 0}); err != nil {
+			log.Error("Unable to disconnect from the MQTT broker", logger.ERROR, err)
+		}
+	}
+}
+
+func (c *clientV5) Publish(topic string, qos byte, retained bool, payload interface{}) mqtt.Token {
+	return runToken(func() error {
+		content, contentType, err := payloadBytes(payload)
+		if err != nil {
+			return err
+		}
+		version := schema.VERSION_1
+		if message, isEncoded := payload.(encoded); isEncoded && message.version != "" {
+			version = message.version
+		}
+		return c.publish(&paho.Publish{
+			Topic:      topic,
+			QoS:        qos,
+			Retain:     retained,
+			Payload:    content,
+			Properties: &paho.PublishProperties{ContentType: contentType, User: outgoingProperties(version, "")},
+		})
+	})
+}
+
+func (c *clientV5) publish(publish *paho.Publish) error {
+	c.lock.Lock()
+	client := c.client
+	c.lock.Unlock()
+	if client == nil {
+		return errors.New("not connected to the broker")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), PUBLISH_TIMEOUT)
+	defer cancel()
+	response, err := client.Publish(ctx, publish)
+	if err != nil {
+		return err
+	}
+	if response != nil && response.ReasonCode >= 0x80 {
+		if response.ReasonCode == 0x87 {
+			ReRegister(fmt.Sprintf("broker refused a message to %s", publish.Topic))
+		}
+		return fmt.Errorf("message refused by the broker, reason code %d", response.ReasonCode)
+	}
+	return nil
+}
+
+func (c *clientV5) Subscribe(topic string, qos byte, callback mqtt.MessageHandler) mqtt.Token {
+	return c.SubscribeMultiple(map[string]byte{topic: qos}, callback)
+}
+
+func (c *clientV5) SubscribeMultiple(filters map[string]byte, callback mqtt.MessageHandler) mqtt.Token {
+	return runToken(func() error {
+		c.lock.Lock()
+		client := c.client
+		c.lock.Unlock()
+		if client == nil {
+			return errors.New("not connected to the broker")
+		}
+		subscribe := &paho.Subscribe{Subscriptions: make(map[string]paho.SubscribeOptions)}
+		for filter, qos := range filters {
+			subscribe.Subscriptions[filter] = paho.SubscribeOptions{QoS: qos}
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), SUBSCRIBE_TIMEOUT)
+		defer cancel()
+		suback, err := client.Subscribe(ctx, subscribe)
+		if err != nil {
+			return err
+		}
+		for _, reason := range suback.Reasons {
+			if reason >= 0x80 {
+				return fmt.Errorf("subscription refused by the broker, reason code %d", reason)
+			}
+		}
+		return nil
+	})
+}
+
+func (c *clientV5) Unsubscribe(topics ...string) mqtt.Token {
+	return runToken(func() error {
+		c.lock.Lock()
+		client := c.client
+		c.lock.Unlock()
+		if client == nil {
+			return errors.New("not connected to the broker")
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), SUBSCRIBE_TIMEOUT)
+		defer cancel()
+		_, err := client.Unsubscribe(ctx, &paho.Unsubscribe{Topics: topics})
+		return err
+	})
+}
+
+// AddRoute is not supported, messages are dispatched by the default publish handler
+func (c *clientV5) AddRoute(topic string, callback mqtt.MessageHandler) {}
+
+// OptionsReader is not supported by the MQTT 5 client
+func (c *clientV5) OptionsReader() mqtt.ClientOptionsReader {
+	return mqtt.ClientOptionsReader{}
+}
+
+func (c *clientV5) route(publish *paho.Publish) {
+	if c.opts.DefaultPublishHandler != nil {
+		c.opts.DefaultPublishHandler(c, &messageV5{publish: publish, received: time.Now()})
+	}
+}
+
+// messageV5 is a received MQTT 5 message, see Properties for its v5 properties
+type messageV5 struct {
+	publish  *paho.Publish
+	received time.Time
+}
+
+func (m *messageV5) Duplicate() bool   { return false }
+func (m *messageV5) Qos() byte         { return m.publish.QoS }
+func (m *messageV5) Retained() bool    { return m.publish.Retain }
+func (m *messageV5) Topic() string     { return m.publish.Topic }
+func (m *messageV5) MessageID() uint16 { return m.publish.PacketID }
+func (m *messageV5) Payload() []byte   { return m.publish.Payload }
+func (m *messageV5) Ack()              {}
+
+// token completes when its operation returns
+type token struct {
+	done chan struct{}
+	err  error
+}
+
+func runToken(operation func() error) mqtt.Token {
+	t := &token{done: make(chan struct{})}
+	go func() {
+		t.err = operation()
+		close(t.done)
+	}()
+	return t
+}
+
+func (t *token) Wait() bool {
+	<-t.done
+	return true
+}
+
+func (t *token) WaitTimeout(timeout time.Duration) bool {
+	select {
+	case <-t.done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+func (t *token) Done() <-chan struct{} {
+	return t.done
+}
+
+func (t *token) Error() error {
+	select {
+	case <-t.done:
+		return t.err
+	default:
+		return nil
+	}
+}
+
+// payloadBytes returns the content of a payload, and its content type if known
+func payloadBytes(payload interface{}) ([]byte, string, error) {
+	switch p := payload.(type) {
+	case encoded:
+		return p.data, p.contentType, nil
+	case string:
+		return []byte(p), "", nil
+	case []byte:
+		return p, "", nil
+	case bytes.Buffer:
+		return p.Bytes(), "", nil
+	case *bytes.Buffer:
+		return p.Bytes(), "", nil
+	}
+	return nil, "", fmt.Errorf("unsupported payload type %T", payload)
+}
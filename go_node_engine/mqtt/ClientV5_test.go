@@ -0,0 +1,138 @@
+package mqtt
+
+import (
+	"bytes"
+	"errors"
+	"go_node_engine/requests"
+	"go_node_engine/schema"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/eclipse/paho.golang/paho"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/eclipse/paho.mqtt.golang/packets"
+	"gotest.tools/assert"
+)
+
+func TestConnackError(t *testing.T) {
+	failure := errors.New("failed")
+	assert.Equal(t, connackError(nil, failure), failure)
+	assert.Equal(t, connackError(&paho.Connack{ReasonCode: 0x84}, failure), errUnsupportedProtocol)
+	assert.Equal(t, connackError(&paho.Connack{ReasonCode: 0x86}, failure), packets.ErrorRefusedBadUsernameOrPassword)
+	assert.Equal(t, connackError(&paho.Connack{ReasonCode: 0x8C}, failure), packets.ErrorRefusedBadUsernameOrPassword)
+	assert.Equal(t, connackError(&paho.Connack{ReasonCode: 0x87}, failure), packets.ErrorRefusedNotAuthorised)
+	assert.Equal(t, connackError(&paho.Connack{ReasonCode: 0x01}, nil), errUnsupportedProtocol)
+	assert.ErrorContains(t, connackError(&paho.Connack{ReasonCode: 0x80}, failure), "reason code 0x80")
+	assert.ErrorContains(t, connackError(&paho.Connack{ReasonCode: 0x9F}, failure), "reason code 0x9F")
+	assert.Assert(t, !errors.Is(connackError(&paho.Connack{ReasonCode: 0x88}, failure), errUnsupportedProtocol))
+	assert.Assert(t, isAuthError(connackError(&paho.Connack{ReasonCode: 0x87}, failure)))
+	assert.Assert(t, !isAuthError(errUnsupportedProtocol))
+}
+
+func TestProperties(t *testing.T) {
+	msg := &messageV5{publish: &paho.Publish{
+		Topic:   "nodes/n1/controls/deploy",
+		Payload: []byte("{}"),
+		Properties: &paho.PublishProperties{
+			ResponseTopic:   "responses/n1",
+			CorrelationData: []byte("42"),
+			User:            outgoingProperties(schema.VERSION, "00-trace-span-01"),
+		},
+	}}
+	properties := Properties(msg)
+	assert.Equal(t, properties.ResponseTopic, "responses/n1")
+	assert.DeepEqual(t, properties.CorrelationData, []byte("42"))
+	assert.Equal(t, properties.SchemaVersion, schema.VERSION)
+	assert.Equal(t, properties.TraceParent, "00-trace-span-01")
+	assert.Equal(t, properties.MessageExpiry, time.Duration(0))
+	assert.Assert(t, !properties.Expired(time.Now()))
+
+	received := time.Now()
+	expiry := uint32(30)
+	properties = Properties(&messageV5{publish: &paho.Publish{Properties: &paho.PublishProperties{MessageExpiry: &expiry}}, received: received})
+	assert.Equal(t, properties.MessageExpiry, 30*time.Second)
+	assert.Equal(t, properties.Expires, received.Add(30*time.Second))
+	assert.Assert(t, !properties.Expired(received.Add(29*time.Second)))
+	assert.Assert(t, properties.Expired(received.Add(30*time.Second)))
+
+	assert.DeepEqual(t, Properties(&messageV5{publish: &paho.Publish{}}), MessageProperties{})
+	assert.Equal(t, len(outgoingProperties(schema.VERSION_1, "")), 1)
+}
+
+func TestPayloadBytes(t *testing.T) {
+	for _, payload := range []interface{}{"data", []byte("data"), *bytes.NewBufferString("data"), bytes.NewBufferString("data")} {
+		content, contentType, err := payloadBytes(payload)
+		assert.NilError(t, err)
+		assert.Equal(t, string(content), "data")
+		assert.Equal(t, contentType, "")
+	}
+	content, contentType, err := payloadBytes(encoded{data: []byte{8, 1}, contentType: schema.CONTENT_TYPE_PROTOBUF})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, content, []byte{8, 1})
+	assert.Equal(t, contentType, schema.CONTENT_TYPE_PROTOBUF)
+	_, _, err = payloadBytes(42)
+	assert.ErrorContains(t, err, "unsupported payload type")
+}
+
+func TestToken(t *testing.T) {
+	release := make(chan struct{})
+	failure := errors.New("failed")
+	tok := runToken(func() error {
+		<-release
+		return failure
+	})
+	assert.Assert(t, !tok.WaitTimeout(10*time.Millisecond))
+	assert.NilError(t, tok.Error())
+	close(release)
+	assert.Assert(t, tok.Wait())
+	assert.Equal(t, tok.Error(), failure)
+}
+
+type connectingClient struct {
+	mqtt.Client
+	err      error
+	attempts int32
+}
+
+func (c *connectingClient) Connect() mqtt.Token {
+	atomic.AddInt32(&c.attempts, 1)
+	return &connectToken{err: c.err}
+}
+
+type connectToken struct {
+	mqtt.DummyToken
+	err error
+}
+
+func (t *connectToken) Error() error {
+	return t.err
+}
+
+func TestFallbackOnlyOnUnsupportedProtocol(t *testing.T) {
+	backoff := requests.Backoff{Initial: time.Millisecond, Max: time.Millisecond}
+	defer func() { mainMqttClient = nil }()
+
+	primary := &connectingClient{err: errors.New("connection refused")}
+	fallback := &connectingClient{}
+	mainMqttClient = primary
+	done := make(chan struct{})
+	go func() {
+		runMqttClient(primary, fallback, backoff)
+		close(done)
+	}()
+	for atomic.LoadInt32(&primary.attempts) < 3 {
+		time.Sleep(time.Millisecond)
+	}
+	clientLock.Lock()
+	mainMqttClient = nil
+	clientLock.Unlock()
+	<-done
+	assert.Equal(t, atomic.LoadInt32(&fallback.attempts), int32(0))
+
+	primary = &connectingClient{err: errUnsupportedProtocol}
+	mainMqttClient = primary
+	runMqttClient(primary, fallback, backoff)
+	assert.Equal(t, atomic.LoadInt32(&fallback.attempts), int32(1))
+	assert.Equal(t, mainMqttClient, mqtt.Client(fallback))
+}
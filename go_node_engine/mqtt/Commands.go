@@ -0,0 +1,153 @@
+package mqtt
+
+import (
+	"errors"
+	"go_node_engine/config"
+	"go_node_engine/logger"
+	"go_node_engine/schema"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// ACK_TOPIC is the node subtopic where control commands are acknowledged
+const ACK_TOPIC = "ack"
+
+const (
+	COMMAND_DEPLOY = "deploy"
+	COMMAND_DELETE = "delete"
+	COMMAND_UPDATE = "update"
+)
+
+// Nack reason codes, the admission rejections are reported with their own codes
+const (
+	REASON_INVALID_REQUEST    = "INVALID_REQUEST"
+	REASON_ALREADY_DEPLOYED   = "ALREADY_DEPLOYED"
+	REASON_INSTANCE_CONFLICT  = "INSTANCE_CONFLICT"
+	REASON_UNDEPLOY_FAILED    = "UNDEPLOY_FAILED"
+	REASON_NOT_DEPLOYED       = "NOT_DEPLOYED"
+	REASON_UPDATE_UNSUPPORTED = "UPDATE_UNSUPPORTED"
+	REASON_UPDATE_IN_PROGRESS = "UPDATE_IN_PROGRESS"
+	REASON_EXPIRED            = "EXPIRED"
+	REASON_UNSUPPORTED_SCHEMA = "UNSUPPORTED_SCHEMA"
+)
+
+// peerVersion is the schema version of the last command received, the messages sent to the cluster follow it
+var peerVersion atomic.Value
+
+// getPeerVersion returns the schema version of the cluster, version 1 until a command announces another one
+func getPeerVersion() string {
+	if version, known := peerVersion.Load().(string); known {
+		return version
+	}
+	return schema.VERSION_1
+}
+
+// decodeCommand decodes a control command following its MQTT 5 content type, JSON by default.
+// The request ID defaults to the MQTT 5 correlation data. Commands sent by orchestrators not supporting
+// request IDs have none, they are neither deduplicated nor acknowledged.
+// Commands of an unknown schema version are not decoded as version 1, ErrUnsupportedVersion is returned instead.
+func decodeCommand(msg mqtt.Message) (schema.ServiceCommand, error) {
+	properties := Properties(msg)
+	command, err := schema.UnmarshalCommand(msg.Payload(), properties.ContentType)
+	if command.RequestID == "" {
+		command.RequestID = string(properties.CorrelationData)
+	}
+	if versionErr := schema.CheckVersion(properties.SchemaVersion); versionErr != nil {
+		return command, versionErr
+	}
+	if err == nil {
+		version := properties.SchemaVersion
+		if version == "" {
+			version = schema.VERSION_1
+		}
+		peerVersion.Store(version)
+	}
+	return command, err
+}
+
+// invalidCommandReason returns the nack reason of a command that could not be decoded
+func invalidCommandReason(err error) string {
+	if errors.Is(err, schema.ErrUnsupportedVersion) {
+		return REASON_UNSUPPORTED_SCHEMA
+	}
+	return REASON_INVALID_REQUEST
+}
+
+// sendAck records the answer to the command, so that a repeated command gets the same one, and publishes it
+func sendAck(ack schema.Ack) {
+	if ack.RequestID == "" {
+		return
+	}
+	getDeduplicator().Record(ack)
+	publishAck(ack)
+}
+
+func publishAck(ack schema.Ack) {
+	payload, err := encode(ack)
+	if err != nil {
+		log.Error("Unable to encode the ack", "request_id", ack.RequestID, logger.ERROR, err)
+		return
+	}
+	if err := publish(ACK_TOPIC, payload); err != nil {
+		log.Error("Unable to ack the request", "command", ack.Command, "request_id", ack.RequestID, logger.ERROR, err)
+	}
+}
+
+// Deduplicator remembers the answers to the commands received within a time window
+type Deduplicator struct {
+	window time.Duration
+	now    func() time.Time
+	seen   map[string]dedupEntry
+	lock   sync.Mutex
+}
+
+type dedupEntry struct {
+	ack  schema.Ack
+	seen time.Time
+}
+
+var deduplicator *Deduplicator
+var deduplicatorOnce sync.Once
+
+func getDeduplicator() *Deduplicator {
+	deduplicatorOnce.Do(func() {
+		deduplicator = NewDeduplicator(config.Get().Mqtt.DedupWindow)
+	})
+	return deduplicator
+}
+
+// NewDeduplicator returns a deduplicator remembering the commands for the given window
+func NewDeduplicator(window time.Duration) *Deduplicator {
+	return &Deduplicator{window: window, now: time.Now, seen: make(map[string]dedupEntry)}
+}
+
+// Seen returns the answer given to the command with the same request ID, if received within the window
+func (d *Deduplicator) Seen(command string, requestID string) (schema.Ack, bool) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	entry, found := d.seen[dedupKey(command, requestID)]
+	if !found || d.now().Sub(entry.seen) > d.window {
+		return schema.Ack{}, false
+	}
+	return entry.ack, true
+}
+
+// Record remembers the answer to a command, forgetting the ones older than the window
+func (d *Deduplicator) Record(ack schema.Ack) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	now := d.now()
+	for key, entry := range d.seen {
+		if now.Sub(entry.seen) > d.window {
+			delete(d.seen, key)
+		}
+	}
+	d.seen[dedupKey(ack.Command, ack.RequestID)] = dedupEntry{ack: ack, seen: now}
+}
+
+func dedupKey(command string, requestID string) string {
+	return command + "/" + requestID
+}
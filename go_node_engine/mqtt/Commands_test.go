@@ -0,0 +1,102 @@
+package mqtt
+
+import (
+	"go_node_engine/deployment"
+	"go_node_engine/model"
+	"go_node_engine/schema"
+	"testing"
+	"time"
+
+	"github.com/eclipse/paho.golang/paho"
+	"gotest.tools/assert"
+)
+
+func TestDeduplicator(t *testing.T) {
+	now := time.Now()
+	d := NewDeduplicator(time.Minute)
+	d.now = func() time.Time { return now }
+
+	_, seen := d.Seen(COMMAND_DEPLOY, "r1")
+	assert.Assert(t, !seen)
+	d.Record(schema.Ack{RequestID: "r1", Command: COMMAND_DEPLOY, Accepted: true})
+
+	ack, seen := d.Seen(COMMAND_DEPLOY, "r1")
+	assert.Assert(t, seen)
+	assert.Assert(t, ack.Accepted)
+	_, seen = d.Seen(COMMAND_DELETE, "r1")
+	assert.Assert(t, !seen)
+
+	now = now.Add(2 * time.Minute)
+	_, seen = d.Seen(COMMAND_DEPLOY, "r1")
+	assert.Assert(t, !seen)
+	d.Record(schema.Ack{RequestID: "r2", Command: COMMAND_DEPLOY})
+	assert.Equal(t, len(d.seen), 1)
+}
+
+func TestDecodeCommand(t *testing.T) {
+	msg := &messageV5{publish: &paho.Publish{Payload: []byte(`{"request_id":"r1","job_name":"app"}`)}}
+	command, err := decodeCommand(msg)
+	assert.NilError(t, err)
+	assert.Equal(t, command.RequestID, "r1")
+	assert.Equal(t, command.Sname, "app")
+
+	msg = &messageV5{publish: &paho.Publish{
+		Payload:    schema.ServiceCommand{Service: model.Service{Sname: "app", Instance: 2}}.MarshalProto(),
+		Properties: &paho.PublishProperties{ContentType: schema.CONTENT_TYPE_PROTOBUF, CorrelationData: []byte("r2")},
+	}}
+	command, err = decodeCommand(msg)
+	assert.NilError(t, err)
+	assert.Equal(t, command.RequestID, "r2")
+	assert.Equal(t, command.Instance, 2)
+
+	msg = &messageV5{publish: &paho.Publish{Payload: []byte(`not json`)}}
+	command, err = decodeCommand(msg)
+	assert.Assert(t, err != nil)
+	assert.Equal(t, command.RequestID, "")
+	assert.Equal(t, invalidCommandReason(err), REASON_INVALID_REQUEST)
+}
+
+func TestDecodeCommandVersion(t *testing.T) {
+	defer peerVersion.Store(schema.VERSION_1)
+	command := []byte(`{"request_id":"r1","job_name":"app"}`)
+	withVersion := func(version string) *messageV5 {
+		return &messageV5{publish: &paho.Publish{
+			Payload:    command,
+			Properties: &paho.PublishProperties{User: paho.UserProperties{{Key: PROPERTY_SCHEMA_VERSION, Value: version}}},
+		}}
+	}
+
+	_, err := decodeCommand(withVersion(schema.VERSION_2))
+	assert.NilError(t, err)
+	assert.Equal(t, getPeerVersion(), schema.VERSION_2)
+
+	// the cluster keeps its version until a command announces another one
+	decoded, err := decodeCommand(withVersion("3"))
+	assert.ErrorContains(t, err, "unsupported schema version 3")
+	assert.Equal(t, decoded.RequestID, "r1")
+	assert.Equal(t, invalidCommandReason(err), REASON_UNSUPPORTED_SCHEMA)
+	assert.Equal(t, getPeerVersion(), schema.VERSION_2)
+
+	_, err = decodeCommand(&messageV5{publish: &paho.Publish{Payload: command}})
+	assert.NilError(t, err)
+	assert.Equal(t, getPeerVersion(), schema.VERSION_1)
+}
+
+func TestDeployExpired(t *testing.T) {
+	expiry := uint32(1)
+	msg := &messageV5{
+		publish: &paho.Publish{
+			Payload:    []byte(`{"request_id":"expired-1","job_name":"app.expired","image":"nginx","virtualization":"docker"}`),
+			Properties: &paho.PublishProperties{MessageExpiry: &expiry},
+		},
+		received: time.Now().Add(-2 * time.Second),
+	}
+	deployHandler(nil, msg)
+
+	ack, seen := getDeduplicator().Seen(COMMAND_DEPLOY, "expired-1")
+	assert.Assert(t, seen)
+	assert.Assert(t, !ack.Accepted)
+	assert.Equal(t, ack.Reason, REASON_EXPIRED)
+	_, deployed := deployment.GetInstance("app.expired", 0)
+	assert.Assert(t, !deployed)
+}
@@ -1,61 +1,71 @@
 package mqtt
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
+	"go_node_engine/admission"
+	"go_node_engine/config"
+	"go_node_engine/deployment"
 	"go_node_engine/logger"
 	"go_node_engine/model"
+	"go_node_engine/requests"
+	"go_node_engine/schema"
+	"go_node_engine/tracing"
 	"go_node_engine/virtualization"
 	"strings"
+	"sync"
 	"time"
 
 	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/eclipse/paho.mqtt.golang/packets"
+	"go.opentelemetry.io/otel/attribute"
 )
 
-// TOPICS is a map of topics and their handlers
-var TOPICS = make(map[string]mqtt.MessageHandler)
+var log = logger.Module("mqtt")
+
+func init() {
+	deployment.OnStatus(reportServiceStatus)
+}
 
 var clientID = ""
 var mainMqttClient mqtt.Client
+var clientLock sync.Mutex
 var brokerUrl = ""
 var brokerPort = ""
 
-var messagePubHandler mqtt.MessageHandler = func(client mqtt.Client, msg mqtt.Message) {
-	logger.InfoLogger().Printf("DEBUG - Received message: %s from topic: %s\n", msg.Payload(), msg.Topic())
-}
-
 var connectHandler mqtt.OnConnectHandler = func(client mqtt.Client) {
-	logger.InfoLogger().Println("Connected to the MQTT broker")
+	log.Info("Connected to the MQTT broker")
+	model.RecordEvent(model.Event{Type: model.EVENT_CONNECTION, Message: "connected to the broker"})
+	model.SetRegistrationState(model.REGISTRATION_REGISTERED)
 
-	topicsQosMap := make(map[string]byte)
-	for key, _ := range TOPICS {
-		topicsQosMap[key] = 1
+	//subscribe to all the routed topics
+	if err := GetRouter().subscribeAll(client); err != nil {
+		log.Error("Unable to subscribe to the node topics", logger.ERROR, err)
+	} else {
+		log.Info("Subscribed to the node topics")
 	}
 
-	//subscribe to all the topics
-	tqtoken := client.SubscribeMultiple(topicsQosMap, subscribeHandlerDispatcher)
-	tqtoken.Wait()
-	logger.InfoLogger().Printf("Subscribed to topics \n")
+	PublishPresence(PRESENCE_ONLINE)
 
-}
+	// replay what was not delivered while disconnected
+	triggerFlush()
 
-var subscribeHandlerDispatcher = func(client mqtt.Client, msg mqtt.Message) {
-	for key, handler := range TOPICS {
-		if strings.Contains(msg.Topic(), key) {
-			handler(client, msg)
-		}
-	}
 }
 
 var connectLostHandler mqtt.ConnectionLostHandler = func(client mqtt.Client, err error) {
-	logger.InfoLogger().Printf("Connect lost: %v", err)
+	log.Warn("Connection to the MQTT broker lost", logger.ERROR, err)
+	model.RecordEvent(model.Event{Type: model.EVENT_CONNECTION, Message: fmt.Sprintf("connection to the broker lost: %v", err)})
 }
 
-// InitMqtt initializes the mqtt client by connecting to the broker, setting the client ID and the topics
-func InitMqtt(clientid string, brokerurl string, brokerport string) {
+// InitMqtt initializes the mqtt client by connecting to the broker, setting the client ID and the topics.
+// The connection is secured and authenticated as configured in the mqtt section, issued credentials take precedence.
+func InitMqtt(clientid string, brokerurl string, brokerport string, issued BrokerCredentials) {
 
+	clientLock.Lock()
+	defer clientLock.Unlock()
 	if clientID != "" {
-		logger.InfoLogger().Printf("Mqtt already initialized no need for any further initialization")
+		log.Info("Mqtt already initialized no need for any further initialization")
 		return
 	}
 
@@ -65,119 +75,349 @@ func InitMqtt(clientid string, brokerurl string, brokerport string) {
 	//platform's assigned client ID
 	clientID = clientid
 
-	TOPICS[fmt.Sprintf("nodes/%s/control/deploy", clientID)] = deployHandler
-	TOPICS[fmt.Sprintf("nodes/%s/control/delete", clientID)] = deleteHandler
+	for topic, handler := range map[string]mqtt.MessageHandler{
+		"control/deploy":   deployHandler,
+		"control/delete":   deleteHandler,
+		"control/update":   updateHandler,
+		"control/register": reRegisterHandler,
+	} {
+		if err := GetRouter().Handle(nodeTopic(clientID, topic), 1, handler); err != nil {
+			log.Fatal("Unable to route the topic", "topic", topic, logger.ERROR, err)
+		}
+	}
+
+	cfg := config.Get().Mqtt
+	tlsCfg, err := tlsConfig(cfg, brokerUrl)
+	if err != nil {
+		log.Fatal("Unable to setup MQTT TLS", logger.ERROR, err)
+	}
 
 	opts := mqtt.NewClientOptions()
-	opts.AddBroker(fmt.Sprintf("tcp://%s:%s", brokerUrl, brokerPort))
+	opts.AddBroker(brokerAddress(cfg, brokerUrl, brokerPort))
 	opts.SetClientID(clientid + "-ne")
-	opts.SetUsername("")
-	opts.SetPassword("")
-	opts.SetDefaultPublishHandler(messagePubHandler)
+	opts.SetTLSConfig(tlsCfg)
+	opts.SetCredentialsProvider(credentialsProvider(cfg, issued, clientid))
+	// subscriptions have no callback of their own, the router dispatches every message exactly once
+	opts.SetDefaultPublishHandler(GetRouter().Dispatch)
+	// the broker flags the node offline as soon as the connection is lost without a disconnect
+	opts.SetWill(nodeTopic(clientid, PRESENCE_TOPIC), PRESENCE_OFFLINE, 1, true)
 	opts.OnConnect = connectHandler
 	opts.OnConnectionLost = connectLostHandler
 
-	go runMqttClient(opts)
+	backoff := requests.ClusterBackoff(config.Get().Cluster)
+	var fallback mqtt.Client
+	switch cfg.Version {
+	case MQTT_V5:
+		mainMqttClient = newClientV5(opts, backoff)
+	case MQTT_V311:
+		mainMqttClient = mqtt.NewClient(opts)
+	default:
+		mainMqttClient = newClientV5(opts, backoff)
+		fallback = mqtt.NewClient(opts)
+	}
+	startFlusher()
+	go runMqttClient(mainMqttClient, fallback, backoff)
 }
 
-func runMqttClient(opts *mqtt.ClientOptions) {
-	mainMqttClient = mqtt.NewClient(opts)
-	if token := mainMqttClient.Connect(); token.Wait() && token.Error() != nil {
-		panic(token.Error())
+// runMqttClient connects to the broker, retrying with backoff until the client is closed.
+// If the broker does not support the protocol version and a fallback client is given, e.g. MQTT 3.1.1 for a broker
+// not supporting MQTT 5, the fallback is tried right away and kept if it connects. Any other failure is retried as is.
+// A broker refusing the node credentials means the cluster forgot the node, which registers again.
+func runMqttClient(client mqtt.Client, fallback mqtt.Client, backoff requests.Backoff) {
+	for attempt := 0; ; attempt++ {
+		err := connectClient(client)
+		if errors.Is(err, errUnsupportedProtocol) && fallback != nil && swapClient(client, fallback) {
+			log.Warn("Unable to connect with MQTT 5, trying MQTT 3.1.1", logger.ERROR, err)
+			if err = connectClient(fallback); err == nil {
+				return
+			}
+			swapClient(fallback, client)
+		}
+		if err == nil {
+			return
+		}
+		if isAuthError(err) {
+			ReRegister(fmt.Sprintf("broker refused the connection, %v", err))
+			return
+		}
+		wait := backoff.Delay(attempt)
+		log.Error("Unable to connect to the MQTT broker", logger.ERROR, err, "retry_in", wait.String())
+		time.Sleep(wait)
+
+		clientLock.Lock()
+		closed := mainMqttClient != client
+		clientLock.Unlock()
+		if closed {
+			return
+		}
 	}
 }
 
-func publishToBroker(topic string, payload string) {
-	logger.InfoLogger().Printf("MQTT - publish to - %s - the payload - %s", topic, payload)
-	token := mainMqttClient.Publish(fmt.Sprintf("nodes/%s/%s", clientID, topic), 1, false, payload)
-	if token.WaitTimeout(time.Second*5) && token.Error() != nil {
-		logger.ErrorLogger().Printf("ERROR: MQTT PUBLISH: %s", token.Error())
+func connectClient(client mqtt.Client) error {
+	token := client.Connect()
+	token.Wait()
+	return token.Error()
+}
+
+// swapClient replaces the node client, unless it was closed in the meantime
+func swapClient(current mqtt.Client, replacement mqtt.Client) bool {
+	clientLock.Lock()
+	defer clientLock.Unlock()
+	if mainMqttClient != current {
+		return false
 	}
+	mainMqttClient = replacement
+	return true
+}
+
+func isAuthError(err error) bool {
+	return errors.Is(err, packets.ErrorRefusedNotAuthorised) || errors.Is(err, packets.ErrorRefusedBadUsernameOrPassword)
+}
+
+// closeMqtt disconnects from the broker and forgets the node topics, so that InitMqtt can be called again
+func closeMqtt() {
+	clientLock.Lock()
+	defer clientLock.Unlock()
+	GetRouter().unbind()
+	if mainMqttClient != nil {
+		mainMqttClient.Disconnect(250)
+		mainMqttClient = nil
+	}
+	prefix := nodeTopic(clientID, "")
+	for _, filter := range GetRouter().Filters() {
+		if strings.HasPrefix(filter, prefix) {
+			_ = GetRouter().Remove(filter)
+		}
+	}
+	clientID = ""
+}
+
+// nodeTopic returns the full topic of the given node subtopic
+func nodeTopic(id string, topic string) string {
+	return fmt.Sprintf("nodes/%s/%s", id, topic)
 }
 
 func deployHandler(client mqtt.Client, msg mqtt.Message) {
-	logger.InfoLogger().Printf("Received deployment request with payload: %s", string(msg.Payload()))
-	service := model.Service{}
-	err := json.Unmarshal(msg.Payload(), &service)
-	logger.InfoLogger().Printf("%v", service)
+	received := time.Now()
+	log.Info("Received deployment request", "payload", string(msg.Payload()))
+	properties := Properties(msg)
+	if properties.TraceParent != "" {
+		log.Debug("Deployment request traced", "traceparent", properties.TraceParent, "schema_version", properties.SchemaVersion)
+	}
+	// the deployment continues the trace of the cluster, if any
+	ctx, span := tracing.Start(tracing.Extract(context.Background(), properties.TraceParent), tracing.SPAN_RECEIVE,
+		attribute.String("messaging.destination", msg.Topic()))
+	defer span.End()
+	command, err := decodeCommand(msg)
+	id, service := command.RequestID, command.Service
+	span.SetAttributes(tracing.Instance(service.Sname, service.Instance, service.Runtime)...)
+	serviceLog := log.Instance(service.Sname, service.Instance)
+	if id != "" {
+		if ack, seen := getDeduplicator().Seen(COMMAND_DEPLOY, id); seen {
+			serviceLog.Info("Deployment request already received", "request_id", id)
+			publishAck(ack)
+			if current, deployed := deployment.GetInstance(service.Sname, service.Instance); deployed {
+				deployment.ReportStatus(current)
+			}
+			return
+		}
+	}
 	if err != nil {
-		logger.ErrorLogger().Printf("ERROR: unable to unmarshal cluster orch request: %v", err)
+		log.Error("Unable to decode the deployment request", logger.ERROR, err)
+		sendAck(schema.Ack{RequestID: id, Command: COMMAND_DEPLOY, Reason: invalidCommandReason(err), Detail: err.Error()})
+		return
+	}
+	// the cluster may have scheduled the instance elsewhere meanwhile, an expired deploy is not run
+	if properties.Expired(time.Now()) {
+		serviceLog.Warn("Deployment request expired, dropping it", "request_id", id, "message_expiry", properties.MessageExpiry)
+		sendAck(schema.Ack{RequestID: id, Command: COMMAND_DEPLOY, Reason: REASON_EXPIRED, Detail: fmt.Sprintf("message expiry of %s elapsed before handling", properties.MessageExpiry)})
+		return
+	}
+	serviceLog.Debug("Deployment spec", "spec", service)
+
+	// a deploy re-sent after a timeout must not fail the running instance
+	current, fresh := deployment.Track(service)
+	if !fresh {
+		if !deployment.SameSpec(current, service) {
+			serviceLog.Error("Deployment conflicts with the deployed instance", "request_id", id)
+			sendAck(schema.Ack{RequestID: id, Command: COMMAND_DEPLOY, Reason: REASON_INSTANCE_CONFLICT, Detail: "instance already deployed with a different spec"})
+			return
+		}
+		serviceLog.Info("Instance already deployed, reporting its status")
+		sendAck(schema.Ack{RequestID: id, Command: COMMAND_DEPLOY, Accepted: true, Reason: REASON_ALREADY_DEPLOYED})
+		deployment.ReportStatus(current)
+		respond(msg, serviceStatus(current))
+		return
+	}
+
+	// reject right away what this node cannot run, so that the cluster can pick another node.
+	// Admitted services have their resources accounted before anything is pulled.
+	reserved, rejection := admission.GetController().Admit(service)
+	if rejection != nil {
+		serviceLog.Error("Deployment rejected", "reason", rejection.Reason, "detail", rejection.Detail)
+		service.Status = model.SERVICE_FAILED
+		service.StatusReason = rejection.Reason
+		service.StatusDetail = rejection.Detail
+		sendAck(schema.Ack{RequestID: id, Command: COMMAND_DEPLOY, Reason: rejection.Reason, Detail: rejection.Detail})
+		deployment.ReportStatus(service)
+		respond(msg, serviceStatus(service))
 		return
 	}
+	sendAck(schema.Ack{RequestID: id, Command: COMMAND_DEPLOY, Accepted: true})
 	//handle deployment in background
 	go func() {
-		runtime := virtualization.GetRuntime(model.RuntimeType(service.Runtime))
-		err = runtime.Deploy(service, ReportServiceStatus)
-		service.Status = model.SERVICE_CREATED
-		if err != nil {
-			logger.ErrorLogger().Printf("ERROR during app deployment: %v", err)
-			service.StatusDetail = err.Error()
-			service.Status = model.SERVICE_FAILED
-		}
-		ReportServiceStatus(service)
+		service := deployment.Run(ctx, service, reserved, received)
+		respond(msg, serviceStatus(service))
 	}()
 }
+
 func deleteHandler(client mqtt.Client, msg mqtt.Message) {
-	logger.InfoLogger().Printf("Received undeployment request with payload: %s", string(msg.Payload()))
-	service := model.Service{}
-	err := json.Unmarshal(msg.Payload(), &service)
+	log.Info("Received undeployment request", "payload", string(msg.Payload()))
+	command, err := decodeCommand(msg)
+	id, service := command.RequestID, command.Service
+	serviceLog := log.Instance(service.Sname, service.Instance)
+	if id != "" {
+		if ack, seen := getDeduplicator().Seen(COMMAND_DELETE, id); seen {
+			serviceLog.Info("Undeployment request already received", "request_id", id)
+			publishAck(ack)
+			return
+		}
+	}
 	if err != nil {
-		logger.ErrorLogger().Printf("ERROR: unable to unmarshal cluster orch request: %v", err)
+		log.Error("Unable to decode the undeployment request", logger.ERROR, err)
+		sendAck(schema.Ack{RequestID: id, Command: COMMAND_DELETE, Reason: invalidCommandReason(err), Detail: err.Error()})
 		return
 	}
 	runtime := virtualization.GetRuntime(model.RuntimeType(service.Runtime))
 	err = runtime.Undeploy(service.Sname, service.Instance)
 	if err != nil {
-		logger.ErrorLogger().Printf("Unable to undeploy application: %s", err.Error())
+		serviceLog.Error("Unable to undeploy application", logger.ERROR, err)
+		sendAck(schema.Ack{RequestID: id, Command: COMMAND_DELETE, Reason: REASON_UNDEPLOY_FAILED, Detail: err.Error()})
+		service.StatusDetail = err.Error()
+		respond(msg, serviceStatus(service))
 		return
 	}
+	sendAck(schema.Ack{RequestID: id, Command: COMMAND_DELETE, Accepted: true})
 	service.Status = model.SERVICE_UNDEPLOYED
-	ReportServiceStatus(service)
+	deployment.ReportStatusAndReleaseResources(service)
+	respond(msg, serviceStatus(service))
 }
 
-// ReportServiceStatus reports the status of the services
-func ReportServiceStatus(service model.Service) {
-	type ServiceStatus struct {
-		Sname    string `json:"sname"`
-		Status   string `json:"status"`
-		Detail   string `json:"status_detail"`
-		Instance int    `json:"instance"`
-		Publicip string `json:"publicip"`
+// updateHandler replaces a running instance with a new spec, reporting each phase as status reason.
+// The instance keeps running the previous spec if the replacement fails.
+func updateHandler(client mqtt.Client, msg mqtt.Message) {
+	log.Info("Received update request", "payload", string(msg.Payload()))
+	command, err := decodeCommand(msg)
+	id, replacement := command.RequestID, command.Service
+	serviceLog := log.Instance(replacement.Sname, replacement.Instance)
+	if id != "" {
+		if ack, seen := getDeduplicator().Seen(COMMAND_UPDATE, id); seen {
+			serviceLog.Info("Update request already received", "request_id", id)
+			publishAck(ack)
+			return
+		}
+	}
+	if err != nil {
+		log.Error("Unable to decode the update request", logger.ERROR, err)
+		sendAck(schema.Ack{RequestID: id, Command: COMMAND_UPDATE, Reason: invalidCommandReason(err), Detail: err.Error()})
+		return
+	}
+	current, deployed := deployment.GetInstance(replacement.Sname, replacement.Instance)
+	if !deployed {
+		sendAck(schema.Ack{RequestID: id, Command: COMMAND_UPDATE, Reason: REASON_NOT_DEPLOYED})
+		return
 	}
-	reportStatusStruct := ServiceStatus{
+	if deployment.SameSpec(current, replacement) {
+		sendAck(schema.Ack{RequestID: id, Command: COMMAND_UPDATE, Accepted: true, Reason: REASON_ALREADY_DEPLOYED})
+		deployment.ReportStatus(current)
+		respond(msg, serviceStatus(current))
+		return
+	}
+	updater, supported := virtualization.GetRuntime(model.RuntimeType(current.Runtime)).(virtualization.RuntimeUpdater)
+	if !supported || current.Runtime != replacement.Runtime {
+		sendAck(schema.Ack{RequestID: id, Command: COMMAND_UPDATE, Reason: REASON_UPDATE_UNSUPPORTED, Detail: "the runtime cannot update instances in place"})
+		return
+	}
+	current, updatable := deployment.BeginUpdate(replacement.Sname, replacement.Instance)
+	if !updatable {
+		sendAck(schema.Ack{RequestID: id, Command: COMMAND_UPDATE, Reason: REASON_UPDATE_IN_PROGRESS})
+		return
+	}
+
+	// the replacement resources are accounted instead of the current ones
+	controller := admission.GetController()
+	if rejection := controller.Replace(current, replacement); rejection != nil {
+		serviceLog.Error("Update rejected", "reason", rejection.Reason, "detail", rejection.Detail)
+		deployment.EndUpdate(current)
+		sendAck(schema.Ack{RequestID: id, Command: COMMAND_UPDATE, Reason: rejection.Reason, Detail: rejection.Detail})
+		return
+	}
+	sendAck(schema.Ack{RequestID: id, Command: COMMAND_UPDATE, Accepted: true})
+
+	go func() {
+		reportPhase := func(phase string) {
+			serviceLog.Info("Update phase", "phase", phase)
+			status, _ := deployment.GetInstance(current.Sname, current.Instance)
+			status.StatusReason = phase
+			status.StatusDetail = ""
+			deployment.ReportStatus(status)
+		}
+		err := updater.Update(current, replacement, reportPhase, deployment.ReportStatusAndReleaseResources)
+		running := replacement
+		running.Status = model.SERVICE_CREATED
+		running.StatusReason = model.UPDATE_COMPLETED
+		if err != nil {
+			serviceLog.Error("Update failed", logger.ERROR, err)
+			running = current
+			running.Status = model.SERVICE_CREATED
+			running.StatusReason = model.UPDATE_ROLLED_BACK
+			running.StatusDetail = err.Error()
+			var rollbackErr *virtualization.RollbackError
+			if errors.As(err, &rollbackErr) {
+				running.Status = model.SERVICE_FAILED
+				running.StatusReason = model.UPDATE_FAILED
+				controller.Release(current.Sname, current.Instance)
+			} else if rejection := controller.Replace(replacement, current); rejection != nil {
+				// the current spec runs again anyway, it stays accounted with the resources of the replacement
+				serviceLog.Error("Unable to account the rolled back instance", "reason", rejection.Reason, "detail", rejection.Detail)
+			}
+		}
+		deployment.EndUpdate(running)
+		deployment.ReportStatus(running)
+		respond(msg, serviceStatus(running))
+	}()
+}
+
+// reportServiceStatus publishes the status of the instances to the cluster, logging it on a standalone node
+func reportServiceStatus(service model.Service) {
+	if standalone.Load() {
+		log.Instance(service.Sname, service.Instance).Info("Instance status", "status", deployment.StatusMessage(service))
+	}
+	publishToBroker("job", serviceStatus(service))
+}
+
+func serviceStatus(service model.Service) schema.ServiceStatus {
+	return schema.ServiceStatus{
 		Sname:    service.Sname,
 		Status:   service.Status,
 		Detail:   service.StatusDetail,
+		Reason:   service.StatusReason,
 		Instance: service.Instance,
-		Publicip: model.GetNodeInfo().Ip,
+		Publicip: model.GetCachedNodeInfo().Ip,
 	}
-	jsonmsg, err := json.Marshal(reportStatusStruct)
-	if err != nil {
-		logger.ErrorLogger().Printf("ERROR: unable to report service status: %v", err)
-	}
-	publishToBroker("job", string(jsonmsg))
 }
 
 // ReportServiceResources reports the resources of the services
 func ReportServiceResources(services []model.Resources) {
-	type ServiceResources struct {
-		Services []model.Resources `json:"services"`
-	}
-	reportStatusStruct := ServiceResources{
-		Services: services,
-	}
-	jsonmsg, err := json.Marshal(reportStatusStruct)
-	if err != nil {
-		logger.ErrorLogger().Printf("ERROR: unable to report services resources: %v", err)
-	}
-	publishToBroker("jobs/resources", string(jsonmsg))
+	publishToBroker("jobs/resources", schema.ServiceResources{Services: services})
 }
 
 // ReportNodeInformation reports the information of the node in the broker
 func ReportNodeInformation(node model.Node) {
-	data, err := json.Marshal(node)
-	if err != nil {
-		logger.ErrorLogger().Printf("ERROR: error gathering node info")
-	}
-	publishToBroker("information", string(data))
+	publishToBroker("information", schema.NodeInformation{Node: node})
+}
+
+// ReportNodeLabels reports the labels, taints and capabilities of the node in the broker
+func ReportNodeLabels(labels model.NodeLabels) {
+	publishToBroker("labels", schema.NodeLabels{NodeLabels: labels})
 }
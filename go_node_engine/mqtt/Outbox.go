@@ -0,0 +1,162 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"fmt"
+	"go_node_engine/logger"
+	"go_node_engine/schema"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// OUTBOX_SUFFIX is the extension of the files holding the queued messages
+const OUTBOX_SUFFIX = ".msg"
+
+// outboxMessage is a message waiting to be delivered to the broker
+type outboxMessage struct {
+	Sequence    uint64 `json:"sequence"`
+	Topic       string `json:"topic"`
+	Data        []byte `json:"data,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Version     string `json:"schema_version,omitempty"`
+}
+
+func (m outboxMessage) encoded() encoded {
+	version := m.Version
+	if version == "" {
+		version = schema.VERSION_1
+	}
+	return encoded{data: m.Data, contentType: m.ContentType, version: version}
+}
+
+// outbox is a bounded on-disk FIFO queue, one file per message named after its sequence number.
+// When full the oldest messages are dropped. The sequences of the queued messages are kept in memory,
+// the directory is only listed when opening the queue.
+type outbox struct {
+	dir       string
+	limit     int
+	next      uint64
+	sequences []uint64
+	lock      sync.Mutex
+}
+
+// openOutbox opens the queue in the given directory, keeping the messages left by a previous run
+func openOutbox(dir string, limit int) (*outbox, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	sequences, err := queuedSequences(dir)
+	if err != nil {
+		return nil, err
+	}
+	box := &outbox{dir: dir, limit: limit, sequences: sequences}
+	if len(sequences) > 0 {
+		box.next = sequences[len(sequences)-1] + 1
+	}
+	return box, nil
+}
+
+// Append queues a message, returning the number of old messages dropped to make room for it
+func (o *outbox) Append(topic string, payload encoded) (int, error) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	message := outboxMessage{Sequence: o.next, Topic: topic, Data: payload.data, ContentType: payload.contentType, Version: payload.version}
+	content, err := json.Marshal(message)
+	if err != nil {
+		return 0, err
+	}
+	tmp := o.path(message.Sequence) + ".tmp"
+	if err := os.WriteFile(tmp, content, 0600); err != nil {
+		return 0, err
+	}
+	if err := os.Rename(tmp, o.path(message.Sequence)); err != nil {
+		return 0, err
+	}
+	o.next++
+	o.sequences = append(o.sequences, message.Sequence)
+
+	dropped := 0
+	for len(o.sequences) > o.limit {
+		if err := os.Remove(o.path(o.sequences[0])); err != nil && !os.IsNotExist(err) {
+			return dropped, err
+		}
+		o.sequences = o.sequences[1:]
+		dropped++
+	}
+	return dropped, nil
+}
+
+// Pending returns the queued messages, oldest first
+func (o *outbox) Pending() ([]outboxMessage, error) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	messages := make([]outboxMessage, 0, len(o.sequences))
+	for _, sequence := range append([]uint64{}, o.sequences...) {
+		content, err := os.ReadFile(o.path(sequence))
+		if err != nil {
+			return nil, err
+		}
+		message := outboxMessage{}
+		if err := json.Unmarshal(content, &message); err != nil {
+			// a message truncated by a crash would block the queue forever
+			log.Error("Dropping corrupted queued message", "sequence", sequence, logger.ERROR, err)
+			_ = os.Remove(o.path(sequence))
+			o.forget(sequence)
+			continue
+		}
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
+
+// Remove deletes a delivered message
+func (o *outbox) Remove(message outboxMessage) error {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	err := os.Remove(o.path(message.Sequence))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	o.forget(message.Sequence)
+	return nil
+}
+
+// forget drops the sequence from the queued ones
+func (o *outbox) forget(sequence uint64) {
+	for i, queued := range o.sequences {
+		if queued == sequence {
+			o.sequences = append(o.sequences[:i:i], o.sequences[i+1:]...)
+			return
+		}
+	}
+}
+
+func (o *outbox) path(sequence uint64) string {
+	return filepath.Join(o.dir, fmt.Sprintf("%020d%s", sequence, OUTBOX_SUFFIX))
+}
+
+// queuedSequences lists the sequences of the messages queued in the directory, oldest first
+func queuedSequences(dir string) ([]uint64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	sequences := make([]uint64, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasSuffix(name, OUTBOX_SUFFIX) {
+			continue
+		}
+		sequence, err := strconv.ParseUint(strings.TrimSuffix(name, OUTBOX_SUFFIX), 10, 64)
+		if err != nil {
+			continue
+		}
+		sequences = append(sequences, sequence)
+	}
+	sort.Slice(sequences, func(i, j int) bool { return sequences[i] < sequences[j] })
+	return sequences, nil
+}
@@ -0,0 +1,161 @@
+package mqtt
+
+import (
+	"go_node_engine/schema"
+	"os"
+	"path/filepath"
+	"testing"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"gotest.tools/assert"
+)
+
+func TestOutboxOrderAndLimit(t *testing.T) {
+	dir := t.TempDir()
+	box, err := openOutbox(dir, 3)
+	assert.NilError(t, err)
+	for _, payload := range []string{"a", "b", "c", "d"} {
+		_, err := box.Append("job", encoded{data: []byte(payload), contentType: schema.CONTENT_TYPE_JSON})
+		assert.NilError(t, err)
+	}
+	pending, err := box.Pending()
+	assert.NilError(t, err)
+	assert.Equal(t, len(pending), 3)
+	assert.Equal(t, pending[0].encoded().String(), "b")
+	assert.Equal(t, pending[2].encoded().String(), "d")
+
+	// a restarted node keeps the queue and its ordering
+	assert.NilError(t, box.Remove(pending[0]))
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "00000000000000000000"+OUTBOX_SUFFIX), []byte("{trunc"), 0600))
+	box, err = openOutbox(dir, 3)
+	assert.NilError(t, err)
+	_, err = box.Append("job", encoded{data: []byte("e"), contentType: schema.CONTENT_TYPE_JSON})
+	assert.NilError(t, err)
+	pending, err = box.Pending()
+	assert.NilError(t, err)
+	assert.Equal(t, len(pending), 3)
+	assert.Equal(t, pending[0].encoded().String(), "c")
+	assert.Equal(t, pending[2].encoded().String(), "e")
+}
+
+func TestOutboxDropsOnlyBeyondLimit(t *testing.T) {
+	box, err := openOutbox(t.TempDir(), 2)
+	assert.NilError(t, err)
+	for _, payload := range []string{"a", "b"} {
+		_, err := box.Append("job", encoded{data: []byte(payload), contentType: schema.CONTENT_TYPE_JSON})
+		assert.NilError(t, err)
+	}
+	pending, err := box.Pending()
+	assert.NilError(t, err)
+	assert.NilError(t, box.Remove(pending[0]))
+
+	// the delivered message makes room for the next one
+	dropped, err := box.Append("job", encoded{data: []byte("c"), contentType: schema.CONTENT_TYPE_JSON})
+	assert.NilError(t, err)
+	assert.Equal(t, dropped, 0)
+	dropped, err = box.Append("job", encoded{data: []byte("d"), contentType: schema.CONTENT_TYPE_JSON})
+	assert.NilError(t, err)
+	assert.Equal(t, dropped, 1)
+	pending, err = box.Pending()
+	assert.NilError(t, err)
+	assert.Equal(t, len(pending), 2)
+	assert.Equal(t, pending[0].encoded().String(), "c")
+}
+
+type fakeClient struct {
+	mqtt.Client
+	connected bool
+	published []string
+	retained  []string
+	onPublish func()
+}
+
+func (c *fakeClient) IsConnectionOpen() bool {
+	return c.connected
+}
+
+func (c *fakeClient) Publish(topic string, qos byte, retained bool, payload interface{}) mqtt.Token {
+	content, _, _ := payloadBytes(payload)
+	if c.onPublish != nil {
+		c.onPublish()
+	}
+	if retained {
+		c.retained = append(c.retained, topic+" "+string(content))
+		return &mqtt.DummyToken{}
+	}
+	c.published = append(c.published, topic+" "+string(content))
+	return &mqtt.DummyToken{}
+}
+
+// testMessage is encoded as a JSON string
+type testMessage string
+
+func (m testMessage) MarshalProto() []byte {
+	return []byte(m)
+}
+
+func TestPublishWhileDisconnected(t *testing.T) {
+	box, err := openOutbox(t.TempDir(), 10)
+	assert.NilError(t, err)
+	outboxOnce.Do(func() {})
+	messages = box
+	client := &fakeClient{}
+	mainMqttClient, clientID = client, "node-1"
+	defer func() {
+		mainMqttClient, clientID = nil, ""
+	}()
+
+	publishToBroker("job", testMessage("created"))
+	publishToBroker("information", testMessage("old"))
+	publishToBroker("job", testMessage("dead"))
+	publishToBroker("information", testMessage("new"))
+	assert.Equal(t, len(client.published), 0)
+
+	client.connected = true
+	flush()
+	assert.DeepEqual(t, client.published, []string{
+		`nodes/node-1/job "created"`,
+		`nodes/node-1/job "dead"`,
+		`nodes/node-1/information "new"`,
+	})
+	pending, err := box.Pending()
+	assert.NilError(t, err)
+	assert.Equal(t, len(pending), 0)
+}
+
+func TestPublishOutsideLatestLock(t *testing.T) {
+	box, err := openOutbox(t.TempDir(), 10)
+	assert.NilError(t, err)
+	outboxOnce.Do(func() {})
+	messages = box
+	client := &fakeClient{}
+	mainMqttClient, clientID = client, "node-1"
+	defer func() {
+		mainMqttClient, clientID = nil, ""
+	}()
+	heldWhilePublishing := false
+	client.onPublish = func() {
+		if latestLock.TryLock() {
+			latestLock.Unlock()
+		} else {
+			heldWhilePublishing = true
+		}
+	}
+
+	publishToBroker("information", testMessage("kept"))
+	latestLock.Lock()
+	assert.Equal(t, latest["information"].payload.String(), `"kept"`)
+	latestLock.Unlock()
+
+	client.connected = true
+	flush()
+	publishToBroker("information", testMessage("delivered"))
+	assert.Assert(t, !heldWhilePublishing)
+	assert.DeepEqual(t, client.published, []string{
+		`nodes/node-1/information "kept"`,
+		`nodes/node-1/information "delivered"`,
+	})
+	latestLock.Lock()
+	assert.Equal(t, len(latest), 0)
+	latestLock.Unlock()
+}
@@ -0,0 +1,41 @@
+package mqtt
+
+import (
+	"errors"
+	"go_node_engine/logger"
+)
+
+// PRESENCE_TOPIC is the node subtopic carrying its retained presence, set to offline by the broker if the node is lost
+const PRESENCE_TOPIC = "presence"
+
+const (
+	// PRESENCE_ONLINE the node is connected and accepting deployments
+	PRESENCE_ONLINE = "online"
+	// PRESENCE_DRAINING the node is shutting down, stopping its services
+	PRESENCE_DRAINING = "draining"
+	// PRESENCE_OFFLINE the node left, gracefully or not
+	PRESENCE_OFFLINE = "offline"
+)
+
+// PublishPresence publishes the retained presence of the node
+func PublishPresence(presence string) {
+	clientLock.Lock()
+	client, id := mainMqttClient, clientID
+	clientLock.Unlock()
+	err := errors.New("not connected to the broker")
+	if client != nil && client.IsConnectionOpen() {
+		err = waitToken(client.Publish(nodeTopic(id, PRESENCE_TOPIC), 1, true, presence))
+	}
+	if err != nil {
+		log.Error("Unable to publish presence", "presence", presence, logger.ERROR, err)
+		return
+	}
+	log.Info("Presence published", "presence", presence)
+}
+
+// Shutdown delivers the queued reports, publishes the offline presence and disconnects from the broker
+func Shutdown() {
+	flush()
+	PublishPresence(PRESENCE_OFFLINE)
+	closeMqtt()
+}
@@ -0,0 +1,26 @@
+package mqtt
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestPublishPresence(t *testing.T) {
+	client := &fakeClient{}
+	mainMqttClient, clientID = client, "node-1"
+	defer func() {
+		mainMqttClient, clientID = nil, ""
+	}()
+
+	PublishPresence(PRESENCE_ONLINE)
+	assert.Equal(t, len(client.retained), 0)
+
+	client.connected = true
+	PublishPresence(PRESENCE_DRAINING)
+	PublishPresence(PRESENCE_OFFLINE)
+	assert.DeepEqual(t, client.retained, []string{
+		"nodes/node-1/presence draining",
+		"nodes/node-1/presence offline",
+	})
+}
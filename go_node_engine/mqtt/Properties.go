@@ -0,0 +1,108 @@
+package mqtt
+
+import (
+	"go_node_engine/logger"
+	"go_node_engine/schema"
+	"go_node_engine/tracing"
+	"time"
+
+	"github.com/eclipse/paho.golang/paho"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+const (
+	// PROPERTY_SCHEMA_VERSION user property carrying the payload schema version
+	PROPERTY_SCHEMA_VERSION = "schema_version"
+	// PROPERTY_TRACEPARENT user property carrying the W3C trace context of the message
+	PROPERTY_TRACEPARENT = tracing.TRACEPARENT
+)
+
+// MessageProperties are the MQTT 5 properties of a received message, all empty with MQTT 3.1.1
+type MessageProperties struct {
+	ContentType     string
+	ResponseTopic   string
+	CorrelationData []byte
+	SchemaVersion   string
+	TraceParent     string
+	// MessageExpiry is the remaining lifetime of the message when received, Expires the time it expires at.
+	// Both are zero for messages without expiry.
+	MessageExpiry time.Duration
+	Expires       time.Time
+}
+
+// Expired tells whether the message outlived its expiry interval, e.g. while queued before being handled
+func (p MessageProperties) Expired(now time.Time) bool {
+	return !p.Expires.IsZero() && !now.Before(p.Expires)
+}
+
+// Properties returns the MQTT 5 properties of a received message
+func Properties(msg mqtt.Message) MessageProperties {
+	message, isV5 := msg.(*messageV5)
+	if !isV5 || message.publish.Properties == nil {
+		return MessageProperties{}
+	}
+	properties := message.publish.Properties
+	result := MessageProperties{
+		ContentType:     properties.ContentType,
+		ResponseTopic:   properties.ResponseTopic,
+		CorrelationData: properties.CorrelationData,
+		SchemaVersion:   properties.User.Get(PROPERTY_SCHEMA_VERSION),
+		TraceParent:     properties.User.Get(PROPERTY_TRACEPARENT),
+	}
+	if properties.MessageExpiry != nil {
+		result.MessageExpiry = time.Duration(*properties.MessageExpiry) * time.Second
+		result.Expires = message.received.Add(result.MessageExpiry)
+	}
+	return result
+}
+
+// respond publishes the message to the response topic of the request, with its correlation data and encoding.
+// Requests without a response topic, e.g. received with MQTT 3.1.1, are only answered on the status topics.
+func respond(request mqtt.Message, msg schema.Message) {
+	properties := Properties(request)
+	if properties.ResponseTopic == "" {
+		return
+	}
+	encoding, err := schema.EncodingOf(properties.ContentType)
+	if err != nil {
+		encoding = schema.ENCODING_JSON
+	}
+	version := properties.SchemaVersion
+	if schema.CheckVersion(version) != nil || version == "" {
+		version = schema.VERSION_1
+	}
+	payload, contentType, err := schema.MarshalVersion(msg, encoding, version)
+	if err != nil {
+		log.Error("Unable to encode the response", "topic", properties.ResponseTopic, logger.ERROR, err)
+		return
+	}
+	clientLock.Lock()
+	client, isV5 := mainMqttClient.(*clientV5)
+	clientLock.Unlock()
+	if !isV5 {
+		return
+	}
+	err = client.publish(&paho.Publish{
+		Topic:   properties.ResponseTopic,
+		QoS:     1,
+		Payload: payload,
+		Properties: &paho.PublishProperties{
+			ContentType:     contentType,
+			CorrelationData: properties.CorrelationData,
+			User:            outgoingProperties(version, properties.TraceParent),
+		},
+	})
+	if err != nil {
+		log.Error("Unable to respond", "topic", properties.ResponseTopic, logger.ERROR, err)
+	}
+}
+
+// outgoingProperties are the user properties of every message sent, with the schema version of its payload
+// and the trace context if any
+func outgoingProperties(version string, traceParent string) paho.UserProperties {
+	properties := paho.UserProperties{{Key: PROPERTY_SCHEMA_VERSION, Value: version}}
+	if traceParent != "" {
+		properties = append(properties, paho.UserProperty{Key: PROPERTY_TRACEPARENT, Value: traceParent})
+	}
+	return properties
+}
@@ -0,0 +1,206 @@
+package mqtt
+
+import (
+	"errors"
+	"fmt"
+	"go_node_engine/config"
+	"go_node_engine/logger"
+	"go_node_engine/metrics"
+	"go_node_engine/schema"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// PUBLISH_TIMEOUT is the time waited for the broker to acknowledge a message
+const PUBLISH_TIMEOUT = time.Second * 5
+
+// DURABLE_TOPICS are queued on disk and replayed in order, as losing them would leave the cluster with a wrong state
+var DURABLE_TOPICS = map[string]bool{
+	"job": true,
+}
+
+var messages *outbox
+var outboxOnce sync.Once
+
+// latest keeps, per topic, the last report not delivered yet. Periodic reports are only worth their latest value.
+// The lock is never held while publishing, the sequence tells whether a newer report replaced the one published.
+var latest = make(map[string]pendingReport)
+var latestSequence uint64
+var latestLock sync.Mutex
+
+type pendingReport struct {
+	payload  encoded
+	sequence uint64
+}
+
+// standalone keeps the reports on the node, there is no broker to publish them to
+var standalone atomic.Bool
+
+var flushSignal = make(chan struct{}, 1)
+var flusherOnce sync.Once
+var flushLock sync.Mutex
+
+// encoded is a payload ready to be published, with its content type and schema version
+type encoded struct {
+	data        []byte
+	contentType string
+	version     string
+}
+
+func (p encoded) String() string {
+	if p.contentType == schema.CONTENT_TYPE_PROTOBUF {
+		return fmt.Sprintf("%d bytes of %s", len(p.data), p.contentType)
+	}
+	return string(p.data)
+}
+
+// encode encodes a message with the configured encoding and the schema version of the cluster
+func encode(msg schema.Message) (encoded, error) {
+	version := getPeerVersion()
+	data, contentType, err := schema.MarshalVersion(msg, config.Get().Mqtt.Encoding, version)
+	return encoded{data: data, contentType: contentType, version: version}, err
+}
+
+func getOutbox() *outbox {
+	outboxOnce.Do(func() {
+		cfg := config.Get()
+		var err error
+		messages, err = openOutbox(filepath.Join(cfg.Node.StateDirectory, "outbox"), cfg.Mqtt.OutboxLimit)
+		if err != nil {
+			log.Error("Unable to open the MQTT outbox, status reports will be lost while disconnected", logger.ERROR, err)
+		}
+	})
+	return messages
+}
+
+// publishToBroker publishes to the node topic. Durable topics are queued and delivered in order,
+// the other ones are coalesced to their latest value while the broker is unreachable.
+func publishToBroker(topic string, msg schema.Message) {
+	if standalone.Load() {
+		return
+	}
+	payload, err := encode(msg)
+	if err != nil {
+		log.Error("Unable to encode the message", "topic", topic, logger.ERROR, err)
+		return
+	}
+	log.Debug("Publishing", "topic", topic, "payload", payload.String())
+	box := getOutbox()
+	if DURABLE_TOPICS[topic] && box != nil {
+		dropped, err := box.Append(topic, payload)
+		if err != nil {
+			log.Error("Unable to queue the message", "topic", topic, logger.ERROR, err)
+		}
+		if dropped > 0 {
+			log.Error("Outbox full, oldest messages dropped", "dropped", dropped)
+		}
+		triggerFlush()
+		return
+	}
+
+	latestLock.Lock()
+	latestSequence++
+	report := pendingReport{payload: payload, sequence: latestSequence}
+	latest[topic] = report
+	latestLock.Unlock()
+	if err := publish(topic, payload); err != nil {
+		log.Warn("Unable to publish, kept until reconnection", "topic", topic, logger.ERROR, err)
+		return
+	}
+	delivered(topic, report)
+}
+
+// delivered forgets the report published, unless a newer one replaced it meanwhile
+func delivered(topic string, report pendingReport) {
+	latestLock.Lock()
+	defer latestLock.Unlock()
+	if latest[topic].sequence == report.sequence {
+		delete(latest, topic)
+	}
+}
+
+// SetStandalone stops publishing the reports, for a node running without a cluster.
+// The status of the instances is logged instead, and everything stays available through the admin API.
+func SetStandalone() {
+	standalone.Store(true)
+}
+
+// publish sends a message and waits for the broker acknowledgement.
+// The content type is only carried by MQTT 5, the payloads published with MQTT 3.1.1 are always JSON.
+func publish(topic string, payload encoded) error {
+	clientLock.Lock()
+	client, id := mainMqttClient, clientID
+	clientLock.Unlock()
+	if client == nil || !client.IsConnectionOpen() {
+		return errors.New("not connected to the broker")
+	}
+	var content interface{} = payload.data
+	if _, isV5 := client.(*clientV5); isV5 {
+		content = payload
+	}
+	token := client.Publish(nodeTopic(id, topic), 1, false, content)
+	if !token.WaitTimeout(PUBLISH_TIMEOUT) {
+		metrics.MqttPublishFailures.WithLabelValues(topic).Inc()
+		return errors.New("publish timeout")
+	}
+	if err := token.Error(); err != nil {
+		metrics.MqttPublishFailures.WithLabelValues(topic).Inc()
+		return err
+	}
+	return nil
+}
+
+// triggerFlush asks the flusher to deliver the queued messages, without blocking
+func triggerFlush() {
+	select {
+	case flushSignal <- struct{}{}:
+	default:
+	}
+}
+
+// startFlusher starts the background job delivering the queued messages at every trigger
+func startFlusher() {
+	flusherOnce.Do(func() {
+		go func() {
+			for range flushSignal {
+				flush()
+			}
+		}()
+	})
+}
+
+// flush delivers the queued messages in order, stopping at the first failure, then the coalesced reports
+func flush() {
+	flushLock.Lock()
+	defer flushLock.Unlock()
+	if box := getOutbox(); box != nil {
+		pending, err := box.Pending()
+		if err != nil {
+			log.Error("Unable to read the outbox", logger.ERROR, err)
+		}
+		for _, message := range pending {
+			if err := publish(message.Topic, message.encoded()); err != nil {
+				log.Warn("Unable to publish the queued messages", "queued", len(pending), logger.ERROR, err)
+				return
+			}
+			if err := box.Remove(message); err != nil {
+				log.Error("Unable to remove the delivered message from the outbox", "sequence", message.Sequence, logger.ERROR, err)
+			}
+		}
+	}
+
+	latestLock.Lock()
+	reports := make(map[string]pendingReport, len(latest))
+	for topic, report := range latest {
+		reports[topic] = report
+	}
+	latestLock.Unlock()
+	for topic, report := range reports {
+		if err := publish(topic, report.payload); err != nil {
+			return
+		}
+		delivered(topic, report)
+	}
+}
@@ -0,0 +1,39 @@
+package mqtt
+
+import (
+	"go_node_engine/model"
+	"sync/atomic"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+var reRegistrationHandler = func() {}
+var reRegistering int32
+
+// SetReRegistrationHandler sets the function registering the node again to the cluster orchestrator,
+// expected to perform a new handshake and call InitMqtt with its result
+func SetReRegistrationHandler(handler func()) {
+	reRegistrationHandler = handler
+}
+
+// ReRegister drops the broker connection and registers the node again, e.g. when the cluster forgot about it.
+// Calls received while a re-registration is in progress are ignored.
+func ReRegister(reason string) {
+	if !atomic.CompareAndSwapInt32(&reRegistering, 0, 1) {
+		return
+	}
+	log.Info("Registering again to the cluster orchestrator", "reason", reason)
+	model.SetRegistrationState(model.REGISTRATION_REGISTERING)
+	// never block the paho callbacks, closing the client from one of them would deadlock
+	go func() {
+		defer atomic.StoreInt32(&reRegistering, 0)
+		// the node may come back with a different ID, the current one must not stay online
+		PublishPresence(PRESENCE_OFFLINE)
+		closeMqtt()
+		reRegistrationHandler()
+	}()
+}
+
+func reRegisterHandler(client mqtt.Client, msg mqtt.Message) {
+	ReRegister("requested by the cluster orchestrator")
+}
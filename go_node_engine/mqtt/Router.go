@@ -0,0 +1,181 @@
+package mqtt
+
+import (
+	"errors"
+	"go_node_engine/logger"
+	"runtime/debug"
+	"strings"
+	"sync"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// SUBSCRIBE_TIMEOUT is the time waited for the broker to acknowledge a subscription change
+const SUBSCRIBE_TIMEOUT = PUBLISH_TIMEOUT
+
+type route struct {
+	qos     byte
+	handler mqtt.MessageHandler
+}
+
+// Router dispatches the incoming messages to the handlers whose topic filter matches, following the MQTT semantics:
+// + matches one topic level, a trailing # any number of levels. Routes can be added and removed while connected.
+type Router struct {
+	routes map[string]route
+	client mqtt.Client
+	lock   sync.RWMutex
+}
+
+var router = NewRouter()
+
+// GetRouter returns the router of the node MQTT client
+func GetRouter() *Router {
+	return router
+}
+
+// NewRouter returns a router without routes
+func NewRouter() *Router {
+	return &Router{routes: make(map[string]route)}
+}
+
+// Handle routes the messages matching filter to handler, subscribing right away if connected.
+// A route already registered for the same filter is replaced.
+func (r *Router) Handle(filter string, qos byte, handler mqtt.MessageHandler) error {
+	if err := ValidateFilter(filter); err != nil {
+		return err
+	}
+	if qos > 2 {
+		return errors.New("invalid qos")
+	}
+	r.lock.Lock()
+	r.routes[filter] = route{qos: qos, handler: handler}
+	client := r.client
+	r.lock.Unlock()
+
+	if client != nil && client.IsConnectionOpen() {
+		return waitToken(client.Subscribe(filter, qos, nil))
+	}
+	return nil
+}
+
+// Remove deletes the route of the given filter, unsubscribing right away if connected
+func (r *Router) Remove(filter string) error {
+	r.lock.Lock()
+	_, found := r.routes[filter]
+	delete(r.routes, filter)
+	client := r.client
+	r.lock.Unlock()
+
+	if found && client != nil && client.IsConnectionOpen() {
+		return waitToken(client.Unsubscribe(filter))
+	}
+	return nil
+}
+
+// Filters returns the filters currently routed
+func (r *Router) Filters() []string {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	filters := make([]string, 0, len(r.routes))
+	for filter := range r.routes {
+		filters = append(filters, filter)
+	}
+	return filters
+}
+
+// subscribeAll binds the router to the client, subscribing to every routed filter. Called at every (re)connection.
+func (r *Router) subscribeAll(client mqtt.Client) error {
+	r.lock.Lock()
+	r.client = client
+	filters := make(map[string]byte)
+	for filter, route := range r.routes {
+		filters[filter] = route.qos
+	}
+	r.lock.Unlock()
+	if len(filters) == 0 {
+		return nil
+	}
+	return waitToken(client.SubscribeMultiple(filters, nil))
+}
+
+// unbind detaches the router from a closed client
+func (r *Router) unbind() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.client = nil
+}
+
+// Dispatch calls every handler whose filter matches the message topic, once each.
+// A panicking handler is logged and does not prevent the other ones from running.
+func (r *Router) Dispatch(client mqtt.Client, msg mqtt.Message) {
+	log.Debug("Received message", "topic", msg.Topic(), "payload", string(msg.Payload()))
+	r.lock.RLock()
+	handlers := make([]mqtt.MessageHandler, 0, 1)
+	for filter, route := range r.routes {
+		if TopicMatches(filter, msg.Topic()) {
+			handlers = append(handlers, route.handler)
+		}
+	}
+	r.lock.RUnlock()
+
+	if len(handlers) == 0 {
+		log.Warn("No handler for topic", "topic", msg.Topic())
+	}
+	for _, handler := range handlers {
+		safeHandle(handler, client, msg)
+	}
+}
+
+func safeHandle(handler mqtt.MessageHandler, client mqtt.Client, msg mqtt.Message) {
+	defer func() {
+		if err := recover(); err != nil {
+			log.Error("Handler panicked", "topic", msg.Topic(), logger.ERROR, err, "stack", string(debug.Stack()))
+		}
+	}()
+	handler(client, msg)
+}
+
+// ValidateFilter checks a topic filter: wildcards must fill a whole level and # must be the last level
+func ValidateFilter(filter string) error {
+	if filter == "" {
+		return errors.New("empty topic filter")
+	}
+	levels := strings.Split(filter, "/")
+	for i, level := range levels {
+		if strings.Contains(level, "#") && (level != "#" || i != len(levels)-1) {
+			return errors.New("# must be the last level of the topic filter " + filter)
+		}
+		if strings.Contains(level, "+") && level != "+" {
+			return errors.New("+ must be a whole level of the topic filter " + filter)
+		}
+	}
+	return nil
+}
+
+// TopicMatches returns true if topic matches the filter. Topics starting with $ are not matched by a leading wildcard.
+func TopicMatches(filter string, topic string) bool {
+	filterLevels := strings.Split(filter, "/")
+	topicLevels := strings.Split(topic, "/")
+	if strings.HasPrefix(topic, "$") && (filterLevels[0] == "+" || filterLevels[0] == "#") {
+		return false
+	}
+	for i, level := range filterLevels {
+		if level == "#" {
+			return true
+		}
+		if i >= len(topicLevels) {
+			return false
+		}
+		if level != "+" && level != topicLevels[i] {
+			return false
+		}
+	}
+	return len(filterLevels) == len(topicLevels)
+}
+
+func waitToken(token mqtt.Token) error {
+	if !token.WaitTimeout(SUBSCRIBE_TIMEOUT) {
+		return errors.New("broker did not acknowledge in time")
+	}
+	return token.Error()
+}
@@ -0,0 +1,73 @@
+package mqtt
+
+import (
+	"testing"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"gotest.tools/assert"
+)
+
+func TestTopicMatches(t *testing.T) {
+	for _, test := range []struct {
+		filter  string
+		topic   string
+		matches bool
+	}{
+		{"nodes/1/control/deploy", "nodes/1/control/deploy", true},
+		{"nodes/1/control/deploy", "nodes/10/control/deploy", false},
+		{"nodes/1/control/deploy", "nodes/1/control/deploy/extra", false},
+		{"nodes/+/control/deploy", "nodes/1/control/deploy", true},
+		{"nodes/+/control/deploy", "nodes/1/control/delete", false},
+		{"nodes/1/#", "nodes/1/control/deploy", true},
+		{"nodes/1/#", "nodes/1", true},
+		{"nodes/1/#", "nodes/2/control", false},
+		{"#", "$SYS/broker/uptime", false},
+		{"+/broker/uptime", "$SYS/broker/uptime", false},
+	} {
+		assert.Equal(t, TopicMatches(test.filter, test.topic), test.matches, "%s %s", test.filter, test.topic)
+	}
+}
+
+func TestValidateFilter(t *testing.T) {
+	assert.NilError(t, ValidateFilter("nodes/+/control/#"))
+	assert.ErrorContains(t, ValidateFilter("nodes/#/control"), "last level")
+	assert.ErrorContains(t, ValidateFilter("nodes/1+/control"), "whole level")
+	assert.ErrorContains(t, ValidateFilter(""), "empty")
+}
+
+type fakeMessage struct {
+	mqtt.Message
+	topic string
+}
+
+func (m fakeMessage) Topic() string {
+	return m.topic
+}
+
+func (m fakeMessage) Payload() []byte {
+	return []byte{}
+}
+
+func TestRouterDispatch(t *testing.T) {
+	router := NewRouter()
+	calls := make([]string, 0)
+	assert.NilError(t, router.Handle("nodes/1/control/deploy", 1, func(mqtt.Client, mqtt.Message) {
+		calls = append(calls, "deploy")
+	}))
+	assert.NilError(t, router.Handle("nodes/1/#", 0, func(mqtt.Client, mqtt.Message) {
+		panic("broken handler")
+	}))
+	assert.NilError(t, router.Handle("nodes/1/control/+", 2, func(mqtt.Client, mqtt.Message) {
+		calls = append(calls, "control")
+	}))
+	assert.ErrorContains(t, router.Handle("nodes/1/control/deploy", 3, nil), "invalid qos")
+
+	router.Dispatch(nil, fakeMessage{topic: "nodes/1/control/deploy"})
+	assert.Equal(t, len(calls), 2)
+	router.Dispatch(nil, fakeMessage{topic: "nodes/1/control/deployment"})
+	assert.DeepEqual(t, calls[2:], []string{"control"})
+
+	assert.NilError(t, router.Remove("nodes/1/control/+"))
+	router.Dispatch(nil, fakeMessage{topic: "nodes/1/control/delete"})
+	assert.Equal(t, len(calls), 3)
+}
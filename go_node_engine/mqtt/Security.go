@@ -0,0 +1,94 @@
+package mqtt
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"go_node_engine/config"
+	"go_node_engine/logger"
+	"go_node_engine/requests"
+	"os"
+	"strings"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+const (
+	// MQTT_AUTO tries MQTT 5 first, falling back to 3.1.1 if the broker does not support it
+	MQTT_AUTO = "auto"
+	// MQTT_V5 only connects with MQTT 5
+	MQTT_V5 = "5"
+	// MQTT_V311 only connects with MQTT 3.1.1
+	MQTT_V311 = "3.1.1"
+)
+
+// BrokerCredentials are the per-node broker credentials handed out by the cluster at handshake, if any
+type BrokerCredentials struct {
+	Username string
+	Password string
+}
+
+// brokerAddress returns the broker URL for the configured scheme
+func brokerAddress(cfg config.MqttConfig, host string, port string) string {
+	address := fmt.Sprintf("%s://%s:%s", cfg.Scheme, host, port)
+	if cfg.Scheme == "ws" || cfg.Scheme == "wss" {
+		address += "/" + strings.TrimPrefix(cfg.WebsocketPath, "/")
+	}
+	return address
+}
+
+// tlsConfig builds the TLS configuration for the ssl and wss schemes, nil otherwise.
+// When a CA file is given, only brokers signed by that CA are trusted.
+func tlsConfig(cfg config.MqttConfig, host string) (*tls.Config, error) {
+	if cfg.Scheme != "ssl" && cfg.Scheme != "wss" {
+		return nil, nil
+	}
+	serverName := cfg.ServerName
+	if serverName == "" {
+		serverName = host
+	}
+	return requests.NewTLSConfig(cfg.CaFile, cfg.CertFile, cfg.KeyFile, serverName)
+}
+
+// credentialsProvider returns the broker credentials at every (re)connection,
+// so that rotated password and token files are picked up without a restart
+func credentialsProvider(cfg config.MqttConfig, issued BrokerCredentials, nodeId string) mqtt.CredentialsProvider {
+	return func() (string, string) {
+		if issued.Username != "" || issued.Password != "" {
+			return issued.Username, issued.Password
+		}
+		username, password, err := configuredCredentials(cfg, nodeId)
+		if err != nil {
+			log.Error("Unable to read MQTT credentials", logger.ERROR, err)
+		}
+		return username, password
+	}
+}
+
+func configuredCredentials(cfg config.MqttConfig, nodeId string) (string, string, error) {
+	username := cfg.Username
+	switch {
+	case cfg.TokenFile != "":
+		token, err := readSecret(cfg.TokenFile)
+		if username == "" {
+			username = nodeId
+		}
+		return username, token, err
+	case cfg.PasswordFile != "":
+		password, err := readSecret(cfg.PasswordFile)
+		return username, password, err
+	}
+	return username, "", nil
+}
+
+func readSecret(file string) (string, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	secret := strings.TrimSpace(string(content))
+	if secret == "" {
+		return "", errors.New(file + " is empty")
+	}
+	return secret, nil
+}
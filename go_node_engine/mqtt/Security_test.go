@@ -0,0 +1,55 @@
+package mqtt
+
+import (
+	"go_node_engine/config"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestBrokerAddress(t *testing.T) {
+	cfg := config.Default().Mqtt
+	assert.Equal(t, brokerAddress(cfg, "10.0.0.1", "10003"), "tcp://10.0.0.1:10003")
+
+	cfg.Scheme = "wss"
+	assert.Equal(t, brokerAddress(cfg, "10.0.0.1", "10003"), "wss://10.0.0.1:10003/mqtt")
+}
+
+func TestTlsConfig(t *testing.T) {
+	cfg := config.Default().Mqtt
+	tlsCfg, err := tlsConfig(cfg, "broker")
+	assert.NilError(t, err)
+	assert.Assert(t, tlsCfg == nil)
+
+	cfg.Scheme = "ssl"
+	tlsCfg, err = tlsConfig(cfg, "broker")
+	assert.NilError(t, err)
+	assert.Equal(t, tlsCfg.ServerName, "broker")
+	assert.Assert(t, tlsCfg.RootCAs == nil)
+
+	cfg.CaFile = filepath.Join(t.TempDir(), "ca.pem")
+	assert.NilError(t, os.WriteFile(cfg.CaFile, []byte("not a certificate"), 0600))
+	_, err = tlsConfig(cfg, "broker")
+	assert.ErrorContains(t, err, "no certificate found")
+}
+
+func TestCredentialsProvider(t *testing.T) {
+	cfg := config.Default().Mqtt
+	cfg.TokenFile = filepath.Join(t.TempDir(), "token")
+	assert.NilError(t, os.WriteFile(cfg.TokenFile, []byte("secret-token\n"), 0600))
+
+	username, password := credentialsProvider(cfg, BrokerCredentials{}, "node-1")()
+	assert.Equal(t, username, "node-1")
+	assert.Equal(t, password, "secret-token")
+
+	// rotated tokens are read at the next connection
+	assert.NilError(t, os.WriteFile(cfg.TokenFile, []byte("rotated"), 0600))
+	_, password = credentialsProvider(cfg, BrokerCredentials{}, "node-1")()
+	assert.Equal(t, password, "rotated")
+
+	username, password = credentialsProvider(cfg, BrokerCredentials{Username: "issued", Password: "pw"}, "node-1")()
+	assert.Equal(t, username, "issued")
+	assert.Equal(t, password, "pw")
+}
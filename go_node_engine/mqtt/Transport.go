@@ -0,0 +1,94 @@
+package mqtt
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// dialBroker opens the network connection to the broker for the MQTT 5 client, which leaves it to the caller
+func dialBroker(ctx context.Context, broker *url.URL, tlsCfg *tls.Config) (net.Conn, error) {
+	switch broker.Scheme {
+	case "tcp":
+		var dialer net.Dialer
+		return dialer.DialContext(ctx, "tcp", broker.Host)
+	case "ssl":
+		dialer := tls.Dialer{Config: tlsCfg}
+		return dialer.DialContext(ctx, "tcp", broker.Host)
+	case "ws", "wss":
+		dialer := websocket.Dialer{
+			TLSClientConfig:  tlsCfg,
+			Subprotocols:     []string{"mqtt"},
+			HandshakeTimeout: PUBLISH_TIMEOUT,
+		}
+		ws, _, err := dialer.DialContext(ctx, broker.String(), nil)
+		if err != nil {
+			return nil, err
+		}
+		return &websocketConn{Conn: ws}, nil
+	}
+	return nil, fmt.Errorf("unsupported scheme %s", broker.Scheme)
+}
+
+// websocketConn exposes a websocket as a stream, MQTT packets being carried in binary messages
+type websocketConn struct {
+	*websocket.Conn
+	reader     io.Reader
+	readLock   sync.Mutex
+	writeLock  sync.Mutex
+	closeOnce  sync.Once
+	closeError error
+}
+
+func (c *websocketConn) Read(p []byte) (int, error) {
+	c.readLock.Lock()
+	defer c.readLock.Unlock()
+	for {
+		if c.reader == nil {
+			_, reader, err := c.NextReader()
+			if err != nil {
+				return 0, err
+			}
+			c.reader = reader
+		}
+		n, err := c.reader.Read(p)
+		if err == io.EOF {
+			c.reader = nil
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+func (c *websocketConn) Write(p []byte) (int, error) {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	if err := c.WriteMessage(websocket.BinaryMessage, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (c *websocketConn) SetDeadline(t time.Time) error {
+	if err := c.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return c.SetWriteDeadline(t)
+}
+
+func (c *websocketConn) Close() error {
+	c.closeOnce.Do(func() {
+		c.closeError = c.Conn.Close()
+	})
+	return c.closeError
+}
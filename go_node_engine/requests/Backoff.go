@@ -0,0 +1,62 @@
+package requests
+
+import (
+	"go_node_engine/config"
+	"math/rand"
+	"time"
+)
+
+// Backoff is a jittered exponential backoff: the n-th wait is a random duration between half and the whole of
+// Initial*2^n, capped at Max. Retries stop once Total has elapsed.
+type Backoff struct {
+	Initial time.Duration
+	Max     time.Duration
+	Total   time.Duration
+}
+
+// ClusterBackoff returns the backoff used to reach the cluster orchestrator and its broker
+func ClusterBackoff(cluster config.ClusterConfig) Backoff {
+	return Backoff{
+		Initial: cluster.RetryInitial,
+		Max:     cluster.RetryMax,
+		Total:   cluster.RetryTimeout,
+	}
+}
+
+// Delay returns the wait before the given retry attempt, starting from 0
+func (b Backoff) Delay(attempt int) time.Duration {
+	delay := b.Initial
+	for i := 0; i < attempt && delay < b.Max; i++ {
+		delay *= 2
+	}
+	if delay > b.Max {
+		delay = b.Max
+	}
+	half := int64(delay / 2)
+	if half <= 0 {
+		return delay
+	}
+	return time.Duration(half + rand.Int63n(half+1))
+}
+
+// Retry runs operation until it succeeds or the total wait is exhausted, returning the last error
+func (b Backoff) Retry(operation func() error, onRetry func(attempt int, wait time.Duration, err error)) error {
+	deadline := time.Now().Add(b.Total)
+	for attempt := 0; ; attempt++ {
+		err := operation()
+		if err == nil {
+			return nil
+		}
+		wait := b.Delay(attempt)
+		if time.Now().Add(wait).After(deadline) {
+			return err
+		}
+		if onRetry != nil {
+			onRetry(attempt, wait, err)
+		}
+		sleep(wait)
+	}
+}
+
+// sleep is replaced in tests
+var sleep = time.Sleep
@@ -0,0 +1,74 @@
+package requests
+
+import (
+	"errors"
+	"go_node_engine/config"
+	"go_node_engine/model"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	backoff := Backoff{Initial: time.Second, Max: 10 * time.Second}
+	for attempt, expected := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second} {
+		delay := backoff.Delay(attempt)
+		assert.Assert(t, delay >= expected/2 && delay <= expected, "attempt %d: %s", attempt, delay)
+	}
+}
+
+func TestBackoffRetry(t *testing.T) {
+	slept := time.Duration(0)
+	sleep = func(d time.Duration) { slept += d }
+	defer func() { sleep = time.Sleep }()
+
+	calls := 0
+	err := Backoff{Initial: time.Millisecond, Max: time.Millisecond, Total: time.Minute}.Retry(func() error {
+		calls++
+		if calls < 3 {
+			return errors.New("unreachable")
+		}
+		return nil
+	}, nil)
+	assert.NilError(t, err)
+	assert.Equal(t, calls, 3)
+	assert.Assert(t, slept > 0)
+
+	err = Backoff{Initial: time.Hour, Max: time.Hour, Total: time.Minute}.Retry(func() error {
+		return errors.New("unreachable")
+	}, nil)
+	assert.ErrorContains(t, err, "unreachable")
+}
+
+func TestClusterHandshakeRetries(t *testing.T) {
+	sleep = func(time.Duration) {}
+	defer func() { sleep = time.Sleep }()
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = io.WriteString(w, `{"id":"node-1","MQTT_BROKER_PORT":"10003"}`)
+	}))
+	defer server.Close()
+	serverUrl, _ := url.Parse(server.URL)
+	host, port, _ := net.SplitHostPort(serverUrl.Host)
+
+	cluster := config.Default().Cluster
+	cluster.Address = host
+	cluster.Port, _ = strconv.Atoi(port)
+	answer, err := ClusterHandshake(cluster, model.Identity{}, Backoff{Initial: time.Millisecond, Max: time.Millisecond, Total: time.Minute})
+	assert.NilError(t, err)
+	assert.Equal(t, answer.NodeId, "node-1")
+	assert.Equal(t, requests, 3)
+}
@@ -3,48 +3,86 @@ package requests
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"go_node_engine/config"
 	"go_node_engine/logger"
 	"go_node_engine/model"
 	"io"
-	"net/http"
+	"time"
 )
 
+var log = logger.Module("requests")
+
 // HandshakeAnswer is the struct that describes the handshake answer between the nodes
 type HandshakeAnswer struct {
-	MqttPort string `json:"MQTT_BROKER_PORT"`
-	NodeId   string `json:"id"`
+	MqttPort     string `json:"MQTT_BROKER_PORT"`
+	NodeId       string `json:"id"`
+	MqttUsername string `json:"MQTT_BROKER_USERNAME,omitempty"`
+	MqttPassword string `json:"MQTT_BROKER_PASSWORD,omitempty"`
+}
+
+// handshakeRequest is the node information, together with the credentials previously issued to the node, if any,
+// so that the cluster can match the node to its previous record
+type handshakeRequest struct {
+	*model.Node
+	MqttUsername string `json:"mqtt_username,omitempty"`
+	Token        string `json:"token,omitempty"`
 }
 
-// ClusterHandshake sends a handshake request to the cluster manager
-func ClusterHandshake(address string, port int) HandshakeAnswer {
-	data, err := json.Marshal(model.GetNodeInfo())
+// ClusterHandshake sends a handshake request to the cluster manager, retrying with the given backoff
+// while the cluster is unreachable or answers with an error. The node ID, if any, is presented together with
+// the previously issued credentials.
+func ClusterHandshake(cluster config.ClusterConfig, previous model.Identity, backoff Backoff) (HandshakeAnswer, error) {
+	answer := HandshakeAnswer{}
+	err := backoff.Retry(func() error {
+		var err error
+		answer, err = clusterHandshake(cluster, previous)
+		return err
+	}, func(attempt int, wait time.Duration, err error) {
+		log.Error("Handshake attempt failed", "attempt", attempt+1, logger.ERROR, err, "retry_in", wait.String())
+	})
+	return answer, err
+}
+
+func clusterHandshake(cluster config.ClusterConfig, previous model.Identity) (HandshakeAnswer, error) {
+	handhsakeanswer := HandshakeAnswer{}
+	client, baseUrl, err := clusterClient(cluster)
 	if err != nil {
-		logger.ErrorLogger().Fatalf("Handshake failed, json encoding problem, %v", err)
+		return handhsakeanswer, fmt.Errorf("unable to setup TLS: %v", err)
 	}
-	jsonbody := bytes.NewBuffer(data)
-	resp, err := http.Post(fmt.Sprintf("http://%s:%d/api/node/register", address, port), "application/json", jsonbody)
+	data, err := json.Marshal(handshakeRequest{
+		Node:         model.GetNodeInfo(),
+		MqttUsername: previous.MqttUsername,
+		Token:        previous.MqttPassword,
+	})
 	if err != nil {
-		logger.ErrorLogger().Fatalf("Handshake failed, %v", err)
+		return handhsakeanswer, fmt.Errorf("json encoding problem, %v", err)
 	}
-	if resp.StatusCode != 200 {
-		logger.ErrorLogger().Fatalf("Handshake failed with error code %d", resp.StatusCode)
+	resp, err := client.Post(baseUrl+"/api/node/register", "application/json", bytes.NewBuffer(data))
+	if err != nil {
+		return handhsakeanswer, err
 	}
 	//defer resp.Body.Close()
 	defer func() {
 		if err := resp.Body.Close(); err != nil {
-			logger.ErrorLogger().Fatalf("Handshake failed, %v", err)
+			log.Error("Handshake response not closed", logger.ERROR, err)
 		}
 	}()
+	if resp.StatusCode != 200 {
+		return handhsakeanswer, fmt.Errorf("error code %d", resp.StatusCode)
+	}
 
-	handhsakeanswer := HandshakeAnswer{}
 	responseBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
-		logger.ErrorLogger().Fatalf("Handshake failed, %v", err)
+		return handhsakeanswer, err
 	}
 	err = json.Unmarshal(responseBytes, &handhsakeanswer)
 	if err != nil {
-		logger.ErrorLogger().Fatalf("Handshake failed, %v", err)
+		return handhsakeanswer, err
+	}
+	if handhsakeanswer.NodeId == "" {
+		return handhsakeanswer, errors.New("no node id in the handshake answer")
 	}
-	return handhsakeanswer
+	return handhsakeanswer, nil
 }
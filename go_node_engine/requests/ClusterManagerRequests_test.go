@@ -0,0 +1,40 @@
+package requests
+
+import (
+	"encoding/json"
+	"go_node_engine/config"
+	"go_node_engine/model"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+)
+
+func TestClusterHandshakePresentsIdentity(t *testing.T) {
+	received := make(map[string]interface{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&received)
+		_, _ = io.WriteString(w, `{"id":"node-1","MQTT_BROKER_PORT":"10003"}`)
+	}))
+	defer server.Close()
+	serverUrl, _ := url.Parse(server.URL)
+	host, port, _ := net.SplitHostPort(serverUrl.Host)
+
+	cluster := config.Default().Cluster
+	cluster.Address = host
+	cluster.Port, _ = strconv.Atoi(port)
+	model.SetNodeId("node-1")
+	defer model.SetNodeId("")
+	_, err := ClusterHandshake(cluster, model.Identity{NodeId: "node-1", MqttUsername: "node-1", MqttPassword: "secret"}, Backoff{Initial: time.Millisecond, Max: time.Millisecond})
+	assert.NilError(t, err)
+	assert.Equal(t, received["id"], "node-1")
+	assert.Equal(t, received["mqtt_username"], "node-1")
+	assert.Equal(t, received["token"], "secret")
+	assert.Assert(t, received["host"] != nil)
+}
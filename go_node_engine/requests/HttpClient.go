@@ -0,0 +1,100 @@
+package requests
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"go_node_engine/config"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HTTP_TIMEOUT is the timeout of every request sent by the NodeEngine
+const HTTP_TIMEOUT = time.Second * 10
+
+// UNIX_SOCKET_HOST is the placeholder host of the URLs sent over a unix domain socket
+const UNIX_SOCKET_HOST = "unix"
+
+var netManagerClient *http.Client
+var netManagerOnce sync.Once
+
+// NewTLSConfig returns a TLS configuration verifying the server against the given CA file, or the system CAs if empty,
+// and presenting the client certificate, if any
+func NewTLSConfig(caFile string, certFile string, keyFile string, serverName string) (*tls.Config, error) {
+	tlsCfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: serverName,
+	}
+	if caFile != "" {
+		ca, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificate found in %s", caFile)
+		}
+		tlsCfg.RootCAs = pool
+	}
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+	return tlsCfg, nil
+}
+
+// NewHttpClient returns an HTTP client using the given TLS configuration, if not nil.
+// When socket is not empty every connection goes to that unix domain socket, whatever the URL host.
+func NewHttpClient(tlsCfg *tls.Config, socket string) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsCfg
+	if socket != "" {
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", socket)
+		}
+	}
+	return &http.Client{
+		Timeout:   HTTP_TIMEOUT,
+		Transport: transport,
+	}
+}
+
+// clusterClient returns the client and the base URL of the cluster orchestrator
+func clusterClient(cluster config.ClusterConfig) (*http.Client, string, error) {
+	var tlsCfg *tls.Config
+	if cluster.Scheme == "https" {
+		var err error
+		tlsCfg, err = NewTLSConfig(cluster.CaFile, cluster.CertFile, cluster.KeyFile, cluster.ServerName)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	return NewHttpClient(tlsCfg, ""), fmt.Sprintf("%s://%s", cluster.Scheme, net.JoinHostPort(cluster.Address, fmt.Sprint(cluster.Port))), nil
+}
+
+// getNetManagerClient returns the client used for every NetManager request
+func getNetManagerClient() *http.Client {
+	netManagerOnce.Do(func() {
+		netManagerClient = NewHttpClient(nil, config.Get().Network.NetManagerSocket)
+	})
+	return netManagerClient
+}
+
+// netManagerUrl returns the URL of the given NetManager API path, over the unix socket if configured
+func netManagerUrl(path string, port int) string {
+	network := config.Get().Network
+	host := UNIX_SOCKET_HOST
+	if network.NetManagerSocket == "" {
+		host = net.JoinHostPort(network.NetManagerHost, fmt.Sprint(port))
+	}
+	return fmt.Sprintf("http://%s/%s", host, strings.TrimPrefix(path, "/"))
+}
@@ -0,0 +1,74 @@
+package requests
+
+import (
+	"encoding/pem"
+	"go_node_engine/config"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestClusterClientTLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, r.URL.Path)
+	}))
+	defer server.Close()
+	serverUrl, err := url.Parse(server.URL)
+	assert.NilError(t, err)
+	host, port, err := net.SplitHostPort(serverUrl.Host)
+	assert.NilError(t, err)
+
+	cluster := config.Default().Cluster
+	cluster.Address = host
+	cluster.Port, _ = strconv.Atoi(port)
+	cluster.Scheme = "https"
+
+	// the test server certificate is not signed by the system CAs
+	client, baseUrl, err := clusterClient(cluster)
+	assert.NilError(t, err)
+	_, err = client.Get(baseUrl + "/api/node/register")
+	assert.ErrorContains(t, err, "certificate")
+
+	cluster.CaFile = filepath.Join(t.TempDir(), "ca.pem")
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	assert.NilError(t, os.WriteFile(cluster.CaFile, ca, 0600))
+	cluster.ServerName = "example.com"
+	client, baseUrl, err = clusterClient(cluster)
+	assert.NilError(t, err)
+	response, err := client.Get(baseUrl + "/api/node/register")
+	assert.NilError(t, err)
+	body, _ := io.ReadAll(response.Body)
+	assert.Equal(t, string(body), "/api/node/register")
+}
+
+func TestUnixSocketClient(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "netmanager.sock")
+	listener, err := net.Listen("unix", socket)
+	assert.NilError(t, err)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, r.URL.Path)
+	}))
+	server.Listener = listener
+	server.Start()
+	defer server.Close()
+
+	cfg := config.Default()
+	cfg.Network.NetManagerSocket = socket
+	config.Set(cfg)
+	defer config.Set(config.Default())
+
+	url := netManagerUrl("/container/deploy", 6000)
+	assert.Equal(t, url, "http://unix/container/deploy")
+	response, err := NewHttpClient(nil, socket).Get(url)
+	assert.NilError(t, err)
+	body, _ := io.ReadAll(response.Body)
+	assert.Equal(t, string(body), "/container/deploy")
+}
@@ -5,10 +5,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"go_node_engine/metrics"
 	"go_node_engine/model"
-	"net/http"
 	"sync"
-	"time"
 )
 
 type registerRequest struct {
@@ -31,10 +30,6 @@ type connectNetworkRequestUnikernel struct {
 
 var ongoingDeployment sync.Mutex
 
-var httpClient = &http.Client{
-	Timeout: time.Second * 10,
-}
-
 // AttachNetworkToTask attaches a network to a task
 func AttachNetworkToTask(pid int, servicename string, instance int, portMappings string) error {
 
@@ -47,23 +42,7 @@ func AttachNetworkToTask(pid int, servicename string, instance int, portMappings
 		Instancenumber: instance,
 		PortMappings:   portMappings,
 	}
-	jsonReq, err := json.Marshal(request)
-	if err != nil {
-		return err
-	}
-
-	response, err := httpClient.Post(
-		fmt.Sprintf("http://localhost:%d/container/deploy", model.GetNodeInfo().NetManagerPort),
-		"application/json",
-		bytes.NewBuffer(jsonReq),
-	)
-	if err != nil {
-		return err
-	}
-	if response.StatusCode != 200 {
-		return errors.New(fmt.Sprintf("NetManager deploy failed, status code: %d", response.StatusCode))
-	}
-	return nil
+	return postNetManager("container/deploy", "deploy", request)
 }
 
 // DetachNetworkFromTask detaches a network from a task
@@ -73,48 +52,15 @@ func DetachNetworkFromTask(servicename string, instance int) error {
 		Servicename:    servicename,
 		Instancenumber: instance,
 	}
-	jsonReq, err := json.Marshal(request)
-	if err != nil {
-		return err
-	}
-
-	response, err := httpClient.Post(
-		fmt.Sprintf("http://localhost:%d/container/undeploy", model.GetNodeInfo().NetManagerPort),
-		"application/json",
-		bytes.NewBuffer(jsonReq),
-	)
-	if err != nil {
-		return err
-	}
-	if response.StatusCode != 200 {
-		return errors.New(fmt.Sprintf("NetManager undeploy failed, status code: %d", response.StatusCode))
-	}
-	return nil
+	return postNetManager("container/undeploy", "undeploy", request)
 }
 
 // RegisterSelfToNetworkComponent registers the node to the network component
 func RegisterSelfToNetworkComponent() error {
 	request := registerRequest{
-		ClientId: model.GetNodeInfo().Id,
+		ClientId: model.GetCachedNodeInfo().Id,
 	}
-	jsonReq, err := json.Marshal(request)
-	if err != nil {
-		return err
-	}
-
-	response, err := httpClient.Post(
-		fmt.Sprintf("http://localhost:%d/register", model.GetNodeInfo().NetManagerPort),
-		"application/json",
-		bytes.NewBuffer(jsonReq),
-	)
-
-	if err != nil {
-		return err
-	}
-	if response.StatusCode != 200 {
-		return errors.New(fmt.Sprintf("NetManager registration failed, status code: %d", response.StatusCode))
-	}
-	return nil
+	return postNetManager("register", "registration", request)
 }
 
 // CreateNetworkNamespaceForUnikernel creates a network namespace for a unikernel
@@ -129,23 +75,7 @@ func CreateNetworkNamespaceForUnikernel(servicename string, instance int, portMa
 		Instancenumber: instance,
 		PortMappings:   portMappings,
 	}
-	jsonReq, err := json.Marshal(request)
-	if err != nil {
-		return err
-	}
-
-	response, err := httpClient.Post(
-		fmt.Sprintf("http://localhost:%d/unikernel/deploy", model.GetNodeInfo().NetManagerPort),
-		"application/json",
-		bytes.NewBuffer(jsonReq),
-	)
-	if err != nil {
-		return err
-	}
-	if response.StatusCode != 200 {
-		return errors.New(fmt.Sprintf("NetManager deploy failed, status code: %d", response.StatusCode))
-	}
-	return nil
+	return postNetManager("unikernel/deploy", "deploy", request)
 }
 
 // DeleteNamespaceForUnikernel deletes a network namespace for a unikernel
@@ -155,21 +85,29 @@ func DeleteNamespaceForUnikernel(servicename string, instance int) error {
 		Servicename:    servicename,
 		Instancenumber: instance,
 	}
+	return postNetManager("unikernel/undeploy", "undeploy", request)
+}
+
+// postNetManager sends the request to the NetManager API path, counting the failures by path
+func postNetManager(path string, action string, request interface{}) error {
 	jsonReq, err := json.Marshal(request)
 	if err != nil {
 		return err
 	}
 
-	response, err := httpClient.Post(
-		fmt.Sprintf("http://localhost:%d/unikernel/undeploy", model.GetNodeInfo().NetManagerPort),
+	response, err := getNetManagerClient().Post(
+		netManagerUrl(path, model.GetCachedNodeInfo().NetManagerPort),
 		"application/json",
 		bytes.NewBuffer(jsonReq),
 	)
 	if err != nil {
+		metrics.NetManagerErrors.WithLabelValues(path).Inc()
 		return err
 	}
+	defer response.Body.Close()
 	if response.StatusCode != 200 {
-		return errors.New(fmt.Sprintf("NetManager undeploy failed, status code: %d", response.StatusCode))
+		metrics.NetManagerErrors.WithLabelValues(path).Inc()
+		return errors.New(fmt.Sprintf("NetManager %s failed, status code: %d", action, response.StatusCode))
 	}
 	return nil
 }
@@ -0,0 +1,262 @@
+package schema
+
+import (
+	"go_node_engine/model"
+	"go_node_engine/model/gpu"
+)
+
+// ServiceCommand is a deploy, update or delete command. The request ID identifies repeated commands.
+type ServiceCommand struct {
+	RequestID string `json:"request_id,omitempty"`
+	model.Service
+}
+
+// ServiceStatus reports the status of a service instance, topic job
+type ServiceStatus struct {
+	Sname    string `json:"sname"`
+	Status   string `json:"status"`
+	Detail   string `json:"status_detail"`
+	Reason   string `json:"status_reason,omitempty"`
+	Instance int    `json:"instance"`
+	Publicip string `json:"publicip"`
+}
+
+// ServiceResources reports the resources used by the service instances, topic jobs/resources
+type ServiceResources struct {
+	Services []model.Resources `json:"services"`
+}
+
+// resourcesV1 is the version 1 JSON layout of model.Resources, with the numbers sent as strings
+type resourcesV1 struct {
+	Cpu      float64 `json:"cpu,string"`
+	Memory   float64 `json:"memory,string"`
+	Disk     int64   `json:"disk,string"`
+	Logs     string  `json:"logs"`
+	Sname    string  `json:"job_name"`
+	Runtime  string  `json:"virtualization"`
+	Instance int     `json:"instance"`
+}
+
+func (r ServiceResources) jsonV1() interface{} {
+	services := make([]resourcesV1, 0, len(r.Services))
+	for _, service := range r.Services {
+		services = append(services, resourcesV1(service))
+	}
+	return struct {
+		Services []resourcesV1 `json:"services"`
+	}{services}
+}
+
+// NodeInformation reports the node status, topic information
+type NodeInformation struct {
+	model.Node
+}
+
+// NodeLabels reports the labels, taints and capabilities of the node, topic labels
+type NodeLabels struct {
+	model.NodeLabels
+}
+
+// Ack tells the cluster orchestrator whether the node accepted the control command with the given request ID, topic ack
+type Ack struct {
+	RequestID string `json:"request_id"`
+	Command   string `json:"command"`
+	Accepted  bool   `json:"accepted"`
+	Reason    string `json:"reason,omitempty"`
+	Detail    string `json:"detail,omitempty"`
+}
+
+func (c ServiceCommand) MarshalProto() []byte {
+	e := encoder{}
+	e.string(1, c.RequestID)
+	e.string(2, c.JobID)
+	e.string(3, c.Sname)
+	e.int(4, int64(c.Instance))
+	e.string(5, c.Image)
+	e.strings(6, c.Commands)
+	e.strings(7, c.Env)
+	e.string(8, c.Ports)
+	e.string(9, c.Runtime)
+	e.int(10, int64(c.Vtpus))
+	e.int(11, int64(c.Vgpus))
+	e.int(12, int64(c.Vcpus))
+	e.int(13, int64(c.Memory))
+	e.int(14, int64(c.Storage))
+	e.strings(15, c.UnikernelImages)
+	e.strings(16, c.Architectures)
+	e.bool(17, c.OneShot)
+	return e
+}
+
+// UnmarshalProto decodes a command encoded following node_engine.proto, unknown fields are ignored
+func (c *ServiceCommand) UnmarshalProto(data []byte) error {
+	*c = ServiceCommand{}
+	return decodeFields(data, func(f field) {
+		value := string(f.Bytes)
+		number := int(int64(f.Varint))
+		switch f.Number {
+		case 1:
+			c.RequestID = value
+		case 2:
+			c.JobID = value
+		case 3:
+			c.Sname = value
+		case 4:
+			c.Instance = number
+		case 5:
+			c.Image = value
+		case 6:
+			c.Commands = append(c.Commands, value)
+		case 7:
+			c.Env = append(c.Env, value)
+		case 8:
+			c.Ports = value
+		case 9:
+			c.Runtime = value
+		case 10:
+			c.Vtpus = number
+		case 11:
+			c.Vgpus = number
+		case 12:
+			c.Vcpus = number
+		case 13:
+			c.Memory = number
+		case 14:
+			c.Storage = number
+		case 15:
+			c.UnikernelImages = append(c.UnikernelImages, value)
+		case 16:
+			c.Architectures = append(c.Architectures, value)
+		case 17:
+			c.OneShot = f.Varint != 0
+		}
+	})
+}
+
+func (s ServiceStatus) MarshalProto() []byte {
+	e := encoder{}
+	e.string(1, s.Sname)
+	e.string(2, s.Status)
+	e.string(3, s.Detail)
+	e.string(4, s.Reason)
+	e.int(5, int64(s.Instance))
+	e.string(6, s.Publicip)
+	return e
+}
+
+func (r ServiceResources) MarshalProto() []byte {
+	e := encoder{}
+	for _, service := range r.Services {
+		resources := encoder{}
+		resources.double(1, service.Cpu)
+		resources.double(2, service.Memory)
+		resources.int(3, service.Disk)
+		resources.string(4, service.Logs)
+		resources.string(5, service.Sname)
+		resources.string(6, service.Runtime)
+		resources.int(7, int64(service.Instance))
+		e.message(1, resources)
+	}
+	return e
+}
+
+func (n NodeInformation) MarshalProto() []byte {
+	e := encoder{}
+	e.string(1, n.Id)
+	e.string(2, n.Host)
+	e.string(3, n.Ip)
+	e.string(4, n.Port)
+	e.stringMap(5, n.SystemInfo)
+	e.double(6, n.CpuUsage)
+	e.int(7, int64(n.CpuCores))
+	e.string(8, n.CpuArch)
+	e.double(9, n.MemoryUsed)
+	e.int(10, int64(n.MemoryMB))
+	e.stringMap(11, n.DiskInfo)
+	e.stringMap(12, n.NetworkInfo)
+	e.string(13, n.GpuDriver)
+	e.double(14, n.GpuUsage)
+	e.int(15, int64(n.GpuCores))
+	e.double(16, n.GpuTemp)
+	e.double(17, n.GpuMemUsage)
+	e.double(18, n.GpuTotMem)
+	for _, allocation := range n.GpuAllocation {
+		e.message(19, marshalAllocation(allocation))
+	}
+	e.int(20, int64(n.TpuCores))
+	for _, allocation := range n.TpuAllocation {
+		e.message(21, marshalAllocation(allocation))
+	}
+	for _, device := range n.Accelerators {
+		e.message(22, marshalDevice(device))
+	}
+	e.message(23, marshalAmount(n.Capacity))
+	e.message(24, marshalAmount(n.Allocated))
+	e.message(25, marshalAmount(n.Allocatable))
+	for _, technology := range n.Technology {
+		e.string(26, string(technology))
+	}
+	for _, addon := range n.SupportedAddons {
+		e.string(27, string(addon))
+	}
+	e.bool(28, n.Overlay)
+	e.message(29, NodeLabels{n.NodeLabels}.MarshalProto())
+	return e
+}
+
+func (l NodeLabels) MarshalProto() []byte {
+	e := encoder{}
+	e.stringMap(1, l.Labels)
+	for _, taint := range l.Taints {
+		t := encoder{}
+		t.string(1, taint.Key)
+		t.string(2, taint.Value)
+		t.string(3, taint.Effect)
+		e.message(2, t)
+	}
+	e.stringMap(3, l.Capabilities)
+	return e
+}
+
+func (a Ack) MarshalProto() []byte {
+	e := encoder{}
+	e.string(1, a.RequestID)
+	e.string(2, a.Command)
+	e.bool(3, a.Accepted)
+	e.string(4, a.Reason)
+	e.string(5, a.Detail)
+	return e
+}
+
+func marshalAmount(amount model.ResourceAmount) []byte {
+	e := encoder{}
+	e.double(1, amount.Cpu)
+	e.int(2, int64(amount.Memory))
+	e.int(3, int64(amount.Gpu))
+	e.int(4, int64(amount.Disk))
+	return e
+}
+
+func marshalAllocation(allocation gpu.DeviceAllocation) []byte {
+	e := encoder{}
+	e.int(1, int64(allocation.Index))
+	e.string(2, allocation.Instance)
+	e.string(3, allocation.BusID)
+	return e
+}
+
+func marshalDevice(device gpu.Device) []byte {
+	e := encoder{}
+	e.int(1, int64(device.Index))
+	e.string(2, string(device.Kind))
+	e.string(3, device.Vendor)
+	e.string(4, device.Name)
+	e.string(5, device.Driver)
+	e.string(6, device.BusID)
+	e.double(7, device.Utilization)
+	e.double(8, device.MemTotalMB)
+	e.double(9, device.MemUsedMB)
+	e.double(10, device.MemFreeMB)
+	e.double(11, device.Temperature)
+	return e
+}
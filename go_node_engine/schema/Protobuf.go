@@ -0,0 +1,104 @@
+package schema
+
+import (
+	"math"
+	"sort"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// encoder appends proto3 fields, omitting the zero values of singular fields
+type encoder []byte
+
+func (e *encoder) string(num protowire.Number, value string) {
+	if value == "" {
+		return
+	}
+	*e = protowire.AppendTag(*e, num, protowire.BytesType)
+	*e = protowire.AppendString(*e, value)
+}
+
+func (e *encoder) strings(num protowire.Number, values []string) {
+	for _, value := range values {
+		*e = protowire.AppendTag(*e, num, protowire.BytesType)
+		*e = protowire.AppendString(*e, value)
+	}
+}
+
+func (e *encoder) int(num protowire.Number, value int64) {
+	if value == 0 {
+		return
+	}
+	*e = protowire.AppendTag(*e, num, protowire.VarintType)
+	*e = protowire.AppendVarint(*e, uint64(value))
+}
+
+func (e *encoder) bool(num protowire.Number, value bool) {
+	if !value {
+		return
+	}
+	*e = protowire.AppendTag(*e, num, protowire.VarintType)
+	*e = protowire.AppendVarint(*e, 1)
+}
+
+func (e *encoder) double(num protowire.Number, value float64) {
+	if value == 0 {
+		return
+	}
+	*e = protowire.AppendTag(*e, num, protowire.Fixed64Type)
+	*e = protowire.AppendFixed64(*e, math.Float64bits(value))
+}
+
+func (e *encoder) message(num protowire.Number, value []byte) {
+	*e = protowire.AppendTag(*e, num, protowire.BytesType)
+	*e = protowire.AppendBytes(*e, value)
+}
+
+// stringMap appends a map<string, string>, sorted by key so that the encoding is deterministic
+func (e *encoder) stringMap(num protowire.Number, values map[string]string) {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		entry := encoder{}
+		entry.string(1, key)
+		entry.string(2, values[key])
+		e.message(num, entry)
+	}
+}
+
+// field is a decoded field, Bytes is set for length delimited fields and Varint for varint ones
+type field struct {
+	Number protowire.Number
+	Type   protowire.Type
+	Varint uint64
+	Bytes  []byte
+}
+
+// decodeFields calls visit for every field of the message, skipping the types it does not expect
+func decodeFields(data []byte, visit func(field field)) error {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+		f := field{Number: num, Type: typ}
+		switch typ {
+		case protowire.VarintType:
+			f.Varint, n = protowire.ConsumeVarint(data)
+		case protowire.BytesType:
+			f.Bytes, n = protowire.ConsumeBytes(data)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, data)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+		visit(f)
+	}
+	return nil
+}
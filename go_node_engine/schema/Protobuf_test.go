@@ -0,0 +1,450 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"go_node_engine/model"
+	"go_node_engine/model/gpu"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"gotest.tools/assert"
+)
+
+// The hand-written encodings are checked against node_engine.proto: every message is decoded with a descriptor
+// built from the .proto file, it must have no unknown field and every field set to the expected value.
+
+const commandJSON = `{
+	"request_id": "r1", "job_id": "42", "job_name": "app", "instance_number": "3", "image": "nginx",
+	"cmd": ["nginx", "-g"], "environment": ["A=1"], "port": "8080:80", "virtualization": "docker",
+	"vtpus": "1", "vgpus": "2", "vcpus": "4", "memory": "512", "storage": "100",
+	"vm_images": ["kernel.img"], "arch": ["amd64"], "one_shot": true}`
+
+func TestMessagesFollowProtoFile(t *testing.T) {
+	file := loadProtoFile(t)
+	tests := []struct {
+		message  Message
+		name     string
+		expected string
+	}{
+		{testCommand(), "ServiceCommand", commandJSON},
+		{Ack{RequestID: "r1", Command: "deploy", Accepted: true, Reason: "ALREADY_DEPLOYED", Detail: "running"}, "Ack", `{
+			"request_id": "r1", "command": "deploy", "accepted": true, "reason": "ALREADY_DEPLOYED", "detail": "running"}`},
+		{ServiceStatus{Sname: "app", Status: "FAILED", Detail: "exit code 1", Reason: "UPDATE_FAILED", Instance: 2, Publicip: "10.0.0.1"}, "ServiceStatus", `{
+			"sname": "app", "status": "FAILED", "status_detail": "exit code 1", "status_reason": "UPDATE_FAILED",
+			"instance": "2", "publicip": "10.0.0.1"}`},
+		{ServiceResources{Services: []model.Resources{{Cpu: 12.5, Memory: 1024, Disk: 10, Logs: "log", Sname: "app", Runtime: "docker", Instance: 1}}}, "ServiceResources", `{
+			"services": [{"cpu": 12.5, "memory": 1024, "disk": "10", "logs": "log", "job_name": "app", "virtualization": "docker", "instance": "1"}]}`},
+		{NodeLabels{testLabels()}, "NodeLabels", `{
+			"labels": {"zone": "a", "rack": "1"}, "taints": [{"key": "gpu", "value": "only", "effect": "NoSchedule"}],
+			"capabilities": {"runtime.container": "true"}}`},
+		{NodeInformation{testNode()}, "NodeInformation", `{
+			"id": "n1", "host": "edge", "ip": "10.0.0.1", "port": "10100", "system_info": {"kernel": "6.1"},
+			"cpu": 25.5, "free_cores": "4", "architecture": "amd64", "memory": 40.5, "memory_free_in_MB": "2048",
+			"disk_info": {"/": "50"}, "network_info": {"eth0": "up"}, "gpu_driver": "nvidia", "gpu_usage": 10.5,
+			"gpu_cores": "1", "gpu_temp": 60.5, "gpu_mem_used": 100.5, "gpu_tot_mem": 8000.5,
+			"gpu_allocation": [{"index": "1", "instance": "app.instance.0", "bus_id": "0000:01:00.0"}],
+			"tpu_cores": "1",
+			"tpu_allocation": [{"index": "2", "instance": "ml.instance.0", "bus_id": "0000:02:00.0"}],
+			"accelerators": [{"index": "1", "kind": "gpu", "vendor": "nvidia", "name": "T4", "driver": "535",
+				"bus_id": "0000:01:00.0", "utilization": 10.5, "mem_total_mb": 16000.5, "mem_used_mb": 100.5,
+				"mem_free_mb": 15900.5, "temperature": 60.5}],
+			"capacity": {"cpu": 4, "memory": "8192", "gpu": "1", "disk": "100000"},
+			"allocated": {"cpu": 1.5, "memory": "512", "gpu": "1", "disk": "100"},
+			"allocatable": {"cpu": 2.5, "memory": "7680", "gpu": "2", "disk": "99900"},
+			"technology": ["docker", "unikernel"], "supported_addons": ["image-builder"], "overlay": true,
+			"labels": {"labels": {"zone": "a", "rack": "1"}, "taints": [{"key": "gpu", "value": "only", "effect": "NoSchedule"}],
+				"capabilities": {"runtime.container": "true"}}}`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			descriptor := file.Messages().ByName(protoreflect.Name(test.name))
+			assert.Assert(t, descriptor != nil)
+			decoded := dynamicpb.NewMessage(descriptor)
+			assert.NilError(t, proto.Unmarshal(test.message.MarshalProto(), decoded))
+			checkFields(t, decoded, test.name)
+			assert.DeepEqual(t, parseJSON(t, protoJSON(t, decoded)), parseJSON(t, test.expected))
+		})
+	}
+}
+
+func TestCommandDecodingFollowsProtoFile(t *testing.T) {
+	file := loadProtoFile(t)
+	encoded := dynamicpb.NewMessage(file.Messages().ByName("ServiceCommand"))
+	assert.NilError(t, protojson.Unmarshal([]byte(commandJSON), encoded))
+	data, err := proto.Marshal(encoded)
+	assert.NilError(t, err)
+	decoded := ServiceCommand{}
+	assert.NilError(t, decoded.UnmarshalProto(data))
+	assert.DeepEqual(t, decoded, testCommand())
+}
+
+func testCommand() ServiceCommand {
+	return ServiceCommand{
+		RequestID: "r1",
+		Service: model.Service{
+			JobID:           "42",
+			Sname:           "app",
+			Instance:        3,
+			Image:           "nginx",
+			Commands:        []string{"nginx", "-g"},
+			Env:             []string{"A=1"},
+			Ports:           "8080:80",
+			Runtime:         "docker",
+			Vtpus:           1,
+			Vgpus:           2,
+			Vcpus:           4,
+			Memory:          512,
+			Storage:         100,
+			UnikernelImages: []string{"kernel.img"},
+			Architectures:   []string{"amd64"},
+			OneShot:         true,
+		},
+	}
+}
+
+func testLabels() model.NodeLabels {
+	return model.NodeLabels{
+		Labels:       map[string]string{"zone": "a", "rack": "1"},
+		Taints:       []model.Taint{{Key: "gpu", Value: "only", Effect: "NoSchedule"}},
+		Capabilities: map[string]string{"runtime.container": "true"},
+	}
+}
+
+func testNode() model.Node {
+	return model.Node{
+		Id:            "n1",
+		Host:          "edge",
+		Ip:            "10.0.0.1",
+		Port:          "10100",
+		SystemInfo:    map[string]string{"kernel": "6.1"},
+		CpuUsage:      25.5,
+		CpuCores:      4,
+		CpuArch:       "amd64",
+		MemoryUsed:    40.5,
+		MemoryMB:      2048,
+		DiskInfo:      map[string]string{"/": "50"},
+		NetworkInfo:   map[string]string{"eth0": "up"},
+		GpuDriver:     "nvidia",
+		GpuUsage:      10.5,
+		GpuCores:      1,
+		GpuTemp:       60.5,
+		GpuMemUsage:   100.5,
+		GpuTotMem:     8000.5,
+		GpuAllocation: []gpu.DeviceAllocation{{Index: 1, Instance: "app.instance.0", BusID: "0000:01:00.0"}},
+		TpuCores:      1,
+		TpuAllocation: []gpu.DeviceAllocation{{Index: 2, Instance: "ml.instance.0", BusID: "0000:02:00.0"}},
+		Accelerators: []gpu.Device{{
+			Index: 1, Kind: gpu.GPU, Vendor: "nvidia", Name: "T4", Driver: "535", BusID: "0000:01:00.0",
+			Utilization: 10.5, MemTotalMB: 16000.5, MemUsedMB: 100.5, MemFreeMB: 15900.5, Temperature: 60.5,
+		}},
+		Capacity:        model.ResourceAmount{Cpu: 4, Memory: 8192, Gpu: 1, Disk: 100000},
+		Allocated:       model.ResourceAmount{Cpu: 1.5, Memory: 512, Gpu: 1, Disk: 100},
+		Allocatable:     model.ResourceAmount{Cpu: 2.5, Memory: 7680, Gpu: 2, Disk: 99900},
+		Technology:      []model.RuntimeType{model.CONTAINER_RUNTIME, model.UNIKERNEL_RUNTIME},
+		SupportedAddons: []model.AddonType{"image-builder"},
+		Overlay:         true,
+		NodeLabels:      testLabels(),
+	}
+}
+
+// checkFields fails for the unknown fields and the fields not set, in the message and the nested ones,
+// so that the test values cover every field of the .proto file
+func checkFields(t *testing.T, message protoreflect.Message, path string) {
+	t.Helper()
+	assert.Equal(t, len(message.GetUnknown()), 0, "unknown fields in %s", path)
+	fields := message.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+		fieldPath := path + "." + string(field.Name())
+		assert.Assert(t, message.Has(field), "%s not set", fieldPath)
+		if field.Message() == nil || field.IsMap() {
+			continue
+		}
+		if field.IsList() {
+			list := message.Get(field).List()
+			for j := 0; j < list.Len(); j++ {
+				checkFields(t, list.Get(j).Message(), fmt.Sprintf("%s[%d]", fieldPath, j))
+			}
+			continue
+		}
+		checkFields(t, message.Get(field).Message(), fieldPath)
+	}
+}
+
+func protoJSON(t *testing.T, message proto.Message) []byte {
+	t.Helper()
+	data, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(message)
+	assert.NilError(t, err)
+	return data
+}
+
+func parseJSON(t *testing.T, data interface{}) map[string]interface{} {
+	t.Helper()
+	var raw []byte
+	switch data := data.(type) {
+	case string:
+		raw = []byte(data)
+	case []byte:
+		raw = data
+	}
+	parsed := map[string]interface{}{}
+	assert.NilError(t, json.Unmarshal(raw, &parsed))
+	return parsed
+}
+
+// loadProtoFile builds the descriptor of node_engine.proto, see parseProto for the syntax supported
+func loadProtoFile(t *testing.T) protoreflect.FileDescriptor {
+	t.Helper()
+	content, err := os.ReadFile("node_engine.proto")
+	assert.NilError(t, err)
+	descriptor, err := parseProto(string(content))
+	assert.NilError(t, err)
+	file, err := protodesc.NewFile(descriptor, new(protoregistry.Files))
+	assert.NilError(t, err)
+	return file
+}
+
+// parseProto parses the subset of proto3 used by node_engine.proto: the syntax and package statements,
+// nested messages, singular and repeated fields of scalar or message type, and map<string, string> fields
+func parseProto(content string) (*descriptorpb.FileDescriptorProto, error) {
+	p := &protoParser{tokens: tokenize(content)}
+	file := &descriptorpb.FileDescriptorProto{Name: proto.String("node_engine.proto")}
+	for !p.done() {
+		switch keyword := p.next(); keyword {
+		case "syntax":
+			p.expect("=")
+			file.Syntax = proto.String(strings.Trim(p.next(), `"`))
+			p.expect(";")
+		case "package":
+			file.Package = proto.String(p.next())
+			p.expect(";")
+		case "message":
+			message, err := p.message()
+			if err != nil {
+				return nil, err
+			}
+			file.MessageType = append(file.MessageType, message)
+		default:
+			return nil, fmt.Errorf("unexpected %q", keyword)
+		}
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+	prefix := "." + file.GetPackage()
+	for _, message := range file.MessageType {
+		if err := resolveTypes(message, prefix, file); err != nil {
+			return nil, err
+		}
+	}
+	return file, nil
+}
+
+var scalarTypes = map[string]descriptorpb.FieldDescriptorProto_Type{
+	"string": descriptorpb.FieldDescriptorProto_TYPE_STRING,
+	"int64":  descriptorpb.FieldDescriptorProto_TYPE_INT64,
+	"bool":   descriptorpb.FieldDescriptorProto_TYPE_BOOL,
+	"double": descriptorpb.FieldDescriptorProto_TYPE_DOUBLE,
+}
+
+type protoParser struct {
+	tokens []string
+	err    error
+}
+
+func (p *protoParser) done() bool {
+	return len(p.tokens) == 0 || p.err != nil
+}
+
+func (p *protoParser) next() string {
+	if len(p.tokens) == 0 {
+		p.err = fmt.Errorf("unexpected end of file")
+		return ""
+	}
+	token := p.tokens[0]
+	p.tokens = p.tokens[1:]
+	return token
+}
+
+func (p *protoParser) expect(token string) {
+	if next := p.next(); next != token && p.err == nil {
+		p.err = fmt.Errorf("expected %q, found %q", token, next)
+	}
+}
+
+func (p *protoParser) message() (*descriptorpb.DescriptorProto, error) {
+	message := &descriptorpb.DescriptorProto{Name: proto.String(p.next())}
+	p.expect("{")
+	for p.err == nil {
+		switch token := p.next(); token {
+		case "}":
+			return message, nil
+		case "message":
+			nested, err := p.message()
+			if err != nil {
+				return nil, err
+			}
+			message.NestedType = append(message.NestedType, nested)
+		case "map":
+			p.expect("<")
+			key := p.next()
+			p.expect(",")
+			value := p.next()
+			p.expect(">")
+			if key != "string" || value != "string" {
+				return nil, fmt.Errorf("unsupported map<%s, %s>", key, value)
+			}
+			field := p.field("repeated", "")
+			entry := mapEntry(field.GetName())
+			field.TypeName = entry.Name
+			message.NestedType = append(message.NestedType, entry)
+			message.Field = append(message.Field, field)
+		case "repeated":
+			message.Field = append(message.Field, p.field("repeated", p.next()))
+		default:
+			message.Field = append(message.Field, p.field("", token))
+		}
+	}
+	return nil, p.err
+}
+
+// field parses the rest of a field declaration, from its name, the type of map fields is set by the caller
+func (p *protoParser) field(label string, typeName string) *descriptorpb.FieldDescriptorProto {
+	name := p.next()
+	p.expect("=")
+	number, err := strconv.Atoi(p.next())
+	if err != nil && p.err == nil {
+		p.err = err
+	}
+	p.expect(";")
+	field := &descriptorpb.FieldDescriptorProto{
+		Name:   proto.String(name),
+		Number: proto.Int32(int32(number)),
+		Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+	}
+	if label == "repeated" {
+		field.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	}
+	if typeName != "" {
+		field.TypeName = proto.String(typeName)
+	}
+	return field
+}
+
+// mapEntry returns the nested message synthesized for a map<string, string> field, as protoc does
+func mapEntry(field string) *descriptorpb.DescriptorProto {
+	name := ""
+	for _, part := range strings.Split(field, "_") {
+		if part != "" {
+			name += string(unicode.ToUpper(rune(part[0]))) + part[1:]
+		}
+	}
+	optional := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()
+	stringType := descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum()
+	return &descriptorpb.DescriptorProto{
+		Name: proto.String(name + "Entry"),
+		Field: []*descriptorpb.FieldDescriptorProto{
+			{Name: proto.String("key"), Number: proto.Int32(1), Label: optional, Type: stringType},
+			{Name: proto.String("value"), Number: proto.Int32(2), Label: optional, Type: stringType},
+		},
+		Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)},
+	}
+}
+
+// resolveTypes sets the type of the fields, resolving the message types from the innermost scope outwards
+func resolveTypes(message *descriptorpb.DescriptorProto, scope string, file *descriptorpb.FileDescriptorProto) error {
+	scope += "." + message.GetName()
+	for _, nested := range message.NestedType {
+		if err := resolveTypes(nested, scope, file); err != nil {
+			return err
+		}
+	}
+	for _, field := range message.Field {
+		if field.Type != nil {
+			continue
+		}
+		if scalar, found := scalarTypes[field.GetTypeName()]; found {
+			field.Type = scalar.Enum()
+			field.TypeName = nil
+			continue
+		}
+		resolved := ""
+		for candidate := scope; candidate != ""; candidate = candidate[:strings.LastIndex(candidate, ".")] {
+			if definedMessage(file, candidate+"."+field.GetTypeName()) {
+				resolved = candidate + "." + field.GetTypeName()
+				break
+			}
+		}
+		if resolved == "" {
+			return fmt.Errorf("unknown type %s of field %s", field.GetTypeName(), field.GetName())
+		}
+		field.Type = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum()
+		field.TypeName = proto.String(resolved)
+	}
+	return nil
+}
+
+// definedMessage tells if the file defines a message with the given fully qualified name, e.g. .pkg.Outer.Inner
+func definedMessage(file *descriptorpb.FileDescriptorProto, name string) bool {
+	prefix := "." + file.GetPackage() + "."
+	if !strings.HasPrefix(name, prefix) {
+		return false
+	}
+	messages := file.MessageType
+	parts := strings.Split(strings.TrimPrefix(name, prefix), ".")
+	for i, part := range parts {
+		var found *descriptorpb.DescriptorProto
+		for _, message := range messages {
+			if message.GetName() == part {
+				found = message
+			}
+		}
+		if found == nil {
+			return false
+		}
+		if i == len(parts)-1 {
+			return true
+		}
+		messages = found.NestedType
+	}
+	return false
+}
+
+// tokenize splits the .proto content into identifiers, numbers, strings and symbols, dropping the comments
+func tokenize(content string) []string {
+	tokens := make([]string, 0)
+	for _, line := range strings.Split(content, "\n") {
+		if index := strings.Index(line, "//"); index >= 0 {
+			line = line[:index]
+		}
+		token := ""
+		for _, r := range line {
+			switch {
+			case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '.' || r == '"':
+				token += string(r)
+				continue
+			case token != "":
+				tokens = append(tokens, token)
+				token = ""
+			}
+			if !unicode.IsSpace(r) {
+				tokens = append(tokens, string(r))
+			}
+		}
+		if token != "" {
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens
+}
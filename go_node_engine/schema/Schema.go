@@ -0,0 +1,103 @@
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Versions of the message schemas, sent with every MQTT 5 message. Messages without it are version 1.
+// Version 2 sends the resources of the instances as JSON numbers instead of strings.
+const (
+	VERSION_1 = "1"
+	VERSION_2 = "2"
+)
+
+// VERSION is the latest version of the message schemas
+const VERSION = VERSION_2
+
+// ErrUnsupportedVersion is returned for messages of a schema version this node does not know
+var ErrUnsupportedVersion = errors.New("unsupported schema version")
+
+// Encodings of the MQTT payloads
+const (
+	ENCODING_JSON     = "json"
+	ENCODING_PROTOBUF = "protobuf"
+)
+
+// Content types of the MQTT payloads, carried by the MQTT 5 content type property. Messages without it are JSON.
+const (
+	CONTENT_TYPE_JSON     = "application/json"
+	CONTENT_TYPE_PROTOBUF = "application/x-protobuf"
+)
+
+// Message is a payload exchanged with the cluster orchestrator, encoded as JSON or as Protobuf
+// following node_engine.proto. The JSON layout is the one of the messages sent before the schemas were versioned.
+type Message interface {
+	MarshalProto() []byte
+}
+
+// legacyMessage is a message whose JSON layout changed, marshalled as jsonV1 for the version 1 peers
+type legacyMessage interface {
+	jsonV1() interface{}
+}
+
+// Marshal encodes the message with the given encoding and the latest schema version, returning the payload and its content type
+func Marshal(msg Message, encoding string) ([]byte, string, error) {
+	return MarshalVersion(msg, encoding, VERSION)
+}
+
+// MarshalVersion encodes the message with the given encoding and schema version, returning the payload and its content type.
+// The Protobuf encoding is the same for every version.
+func MarshalVersion(msg Message, encoding string, version string) ([]byte, string, error) {
+	if err := CheckVersion(version); err != nil {
+		return nil, "", err
+	}
+	switch encoding {
+	case ENCODING_PROTOBUF:
+		return msg.MarshalProto(), CONTENT_TYPE_PROTOBUF, nil
+	case ENCODING_JSON, "":
+		var content interface{} = msg
+		if legacy, changed := msg.(legacyMessage); changed && (version == VERSION_1 || version == "") {
+			content = legacy.jsonV1()
+		}
+		data, err := json.Marshal(content)
+		return data, CONTENT_TYPE_JSON, err
+	}
+	return nil, "", fmt.Errorf("unsupported encoding %s", encoding)
+}
+
+// CheckVersion returns ErrUnsupportedVersion for the schema versions this node does not know, none meaning version 1
+func CheckVersion(version string) error {
+	switch version {
+	case "", VERSION_1, VERSION_2:
+		return nil
+	}
+	return fmt.Errorf("%w %s", ErrUnsupportedVersion, version)
+}
+
+// EncodingOf returns the encoding of the given content type
+func EncodingOf(contentType string) (string, error) {
+	switch contentType {
+	case CONTENT_TYPE_PROTOBUF:
+		return ENCODING_PROTOBUF, nil
+	case CONTENT_TYPE_JSON, "":
+		return ENCODING_JSON, nil
+	}
+	return "", fmt.Errorf("unsupported content type %s", contentType)
+}
+
+// UnmarshalCommand decodes a control command of the given content type
+func UnmarshalCommand(data []byte, contentType string) (ServiceCommand, error) {
+	command := ServiceCommand{}
+	encoding, err := EncodingOf(contentType)
+	if err != nil {
+		return command, err
+	}
+	if encoding == ENCODING_PROTOBUF {
+		err = command.UnmarshalProto(data)
+	} else {
+		err = json.Unmarshal(data, &command)
+	}
+	return command, err
+}
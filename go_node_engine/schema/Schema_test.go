@@ -0,0 +1,93 @@
+package schema
+
+import (
+	"encoding/json"
+	"go_node_engine/model"
+	"math"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"gotest.tools/assert"
+)
+
+func TestCommandRoundTrip(t *testing.T) {
+	command := ServiceCommand{
+		RequestID: "r1",
+		Service: model.Service{
+			JobID:    "42",
+			Sname:    "app",
+			Instance: 3,
+			Image:    "nginx",
+			Commands: []string{"nginx", "-g", "daemon off;"},
+			Env:      []string{"A=1"},
+			Runtime:  string(model.CONTAINER_RUNTIME),
+			Vcpus:    2,
+			Memory:   512,
+			OneShot:  true,
+		},
+	}
+	for _, encoding := range []string{ENCODING_JSON, ENCODING_PROTOBUF} {
+		data, contentType, err := Marshal(command, encoding)
+		assert.NilError(t, err)
+		decoded, err := UnmarshalCommand(data, contentType)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, decoded, command)
+	}
+
+	_, err := UnmarshalCommand([]byte("{}"), "text/plain")
+	assert.ErrorContains(t, err, "unsupported content type")
+	_, err = UnmarshalCommand([]byte{0x0a, 0x05, 'r'}, CONTENT_TYPE_PROTOBUF)
+	assert.Assert(t, err != nil)
+}
+
+func TestJsonLayout(t *testing.T) {
+	resources := ServiceResources{Services: []model.Resources{{Cpu: 12.5, Memory: 1024, Disk: 10, Sname: "app"}}}
+	data, _, err := Marshal(resources, ENCODING_JSON)
+	assert.NilError(t, err)
+	assert.Equal(t, string(data), `{"services":[{"cpu":12.5,"memory":1024,"disk":10,"logs":"","job_name":"app","virtualization":"","instance":0}]}`)
+	data, _, err = MarshalVersion(resources, ENCODING_JSON, VERSION_1)
+	assert.NilError(t, err)
+	assert.Equal(t, string(data), `{"services":[{"cpu":"12.5","memory":"1024","disk":"10","logs":"","job_name":"app","virtualization":"","instance":0}]}`)
+	_, _, err = MarshalVersion(resources, ENCODING_JSON, "3")
+	assert.ErrorContains(t, err, "unsupported schema version 3")
+
+	data, _, err = Marshal(NodeLabels{model.NodeLabels{Labels: map[string]string{"zone": "a"}}}, ENCODING_JSON)
+	assert.NilError(t, err)
+	assert.Equal(t, string(data), `{"labels":{"zone":"a"}}`)
+
+	node := map[string]interface{}{}
+	data, _, err = Marshal(NodeInformation{model.Node{Id: "n1", CpuCores: 4}}, ENCODING_JSON)
+	assert.NilError(t, err)
+	assert.NilError(t, json.Unmarshal(data, &node))
+	assert.Equal(t, node["id"], "n1")
+	assert.Equal(t, node["free_cores"], 4.0)
+}
+
+func TestProtobufReport(t *testing.T) {
+	data, contentType, err := Marshal(ServiceResources{Services: []model.Resources{{Cpu: 12.5, Disk: 10, Sname: "app", Instance: 1}}}, ENCODING_PROTOBUF)
+	assert.NilError(t, err)
+	assert.Equal(t, contentType, CONTENT_TYPE_PROTOBUF)
+
+	services := make([][]byte, 0)
+	assert.NilError(t, decodeFields(data, func(f field) {
+		assert.Equal(t, f.Number, protowire.Number(1))
+		services = append(services, f.Bytes)
+	}))
+	assert.Equal(t, len(services), 1)
+
+	resources := services[0]
+	num, typ, n := protowire.ConsumeTag(resources)
+	assert.Equal(t, num, protowire.Number(1))
+	assert.Equal(t, typ, protowire.Fixed64Type)
+	bits, _ := protowire.ConsumeFixed64(resources[n:])
+	assert.Equal(t, math.Float64frombits(bits), 12.5)
+
+	fields := map[protowire.Number]field{}
+	assert.NilError(t, decodeFields(resources, func(f field) { fields[f.Number] = f }))
+	assert.Equal(t, fields[3].Varint, uint64(10))
+	assert.Equal(t, string(fields[5].Bytes), "app")
+	assert.Equal(t, fields[7].Varint, uint64(1))
+	// zero values are omitted
+	_, found := fields[2]
+	assert.Assert(t, !found)
+}
@@ -0,0 +1,76 @@
+package standalone
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go_node_engine/model"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Manifest lists the service instances run by a standalone node. The services have the fields of the deploy
+// command, in YAML or JSON, e.g.
+//
+//	services:
+//	  - job_name: app.web
+//	    instance_number: 0
+//	    image: docker.io/library/nginx:latest
+//	    virtualization: docker
+//	    port: "8080:80"
+type Manifest struct {
+	Services []model.Service `json:"services"`
+}
+
+// LoadManifest reads and validates the manifest, the runtime of the services defaults to containers
+func LoadManifest(path string) (Manifest, error) {
+	manifest := Manifest{}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return manifest, fmt.Errorf("unable to read the manifest: %v", err)
+	}
+	// the YAML is decoded through JSON, so that the fields are named as in the deploy commands
+	var document interface{}
+	if err := yaml.Unmarshal(content, &document); err != nil {
+		return manifest, fmt.Errorf("invalid manifest %s: %v", path, err)
+	}
+	encoded, err := json.Marshal(document)
+	if err != nil {
+		return manifest, fmt.Errorf("invalid manifest %s: %v", path, err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&manifest); err != nil {
+		return manifest, fmt.Errorf("invalid manifest %s: %v", path, err)
+	}
+	if err := manifest.validate(); err != nil {
+		return manifest, fmt.Errorf("invalid manifest %s: %v", path, err)
+	}
+	return manifest, nil
+}
+
+func (m *Manifest) validate() error {
+	seen := make(map[string]bool)
+	for i := range m.Services {
+		service := &m.Services[i]
+		if service.Sname == "" {
+			return fmt.Errorf("service %d without job_name", i)
+		}
+		if service.Image == "" {
+			return fmt.Errorf("service %s without image", service.Sname)
+		}
+		if service.Instance < 0 {
+			return fmt.Errorf("service %s with negative instance_number", service.Sname)
+		}
+		if service.Runtime == "" {
+			service.Runtime = string(model.CONTAINER_RUNTIME)
+		}
+		key := model.InstanceKey(service.Sname, service.Instance)
+		if seen[key] {
+			return fmt.Errorf("instance %s listed twice", key)
+		}
+		seen[key] = true
+	}
+	return nil
+}
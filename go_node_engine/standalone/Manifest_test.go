@@ -0,0 +1,78 @@
+package standalone
+
+import (
+	"go_node_engine/model"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func writeManifest(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "manifest.yaml")
+	assert.NilError(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestLoadManifest(t *testing.T) {
+	manifest, err := LoadManifest(writeManifest(t, `
+services:
+  - job_name: app.web
+    image: docker.io/library/nginx:latest
+    port: "8080:80"
+    memory: 100
+  - job_name: app.web
+    instance_number: 1
+    image: docker.io/library/nginx:latest
+    virtualization: unikernel
+    vm_images: [https://example.org/web.tar.gz]
+`))
+	assert.NilError(t, err)
+	assert.Equal(t, len(manifest.Services), 2)
+	web := manifest.Services[0]
+	assert.Equal(t, web.Sname, "app.web")
+	assert.Equal(t, web.Ports, "8080:80")
+	assert.Equal(t, web.Memory, 100)
+	assert.Equal(t, web.Runtime, string(model.CONTAINER_RUNTIME))
+	assert.Equal(t, manifest.Services[1].Instance, 1)
+	assert.Equal(t, manifest.Services[1].Runtime, string(model.UNIKERNEL_RUNTIME))
+
+	manifest, err = LoadManifest(writeManifest(t, ""))
+	assert.NilError(t, err)
+	assert.Equal(t, len(manifest.Services), 0)
+}
+
+func TestLoadManifestInvalid(t *testing.T) {
+	for content, expected := range map[string]string{
+		"services:\n  - image: nginx\n":                                                     "without job_name",
+		"services:\n  - job_name: app\n":                                                    "app without image",
+		"services:\n  - job_name: app\n    image: nginx\n    imgae: nginx\n":                "unknown field",
+		"services:\n  - {job_name: app, image: nginx}\n  - {job_name: app, image: redis}\n": "app.instance.0 listed twice",
+		"services: [": "invalid manifest",
+	} {
+		_, err := LoadManifest(writeManifest(t, content))
+		assert.ErrorContains(t, err, expected)
+	}
+	_, err := LoadManifest(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.ErrorContains(t, err, "unable to read the manifest")
+}
+
+func TestPlan(t *testing.T) {
+	web := model.Service{Sname: "app.web", Image: "nginx:1.25", Runtime: "docker"}
+	db := model.Service{Sname: "app.db", Image: "postgres:16", Runtime: "docker"}
+	cache := model.Service{Sname: "app.cache", Image: "redis:7", Runtime: "docker"}
+
+	deploy, undeploy := plan([]model.Service{web, db}, nil)
+	assert.DeepEqual(t, deploy, []model.Service{web, db})
+	assert.Equal(t, len(undeploy), 0)
+
+	// the deployed instances carry their status, which does not count as a change
+	running := web
+	running.Status = model.SERVICE_CREATED
+	changed := db
+	changed.Image = "postgres:17"
+	deploy, undeploy = plan([]model.Service{web, changed}, []model.Service{running, db, cache})
+	assert.DeepEqual(t, deploy, []model.Service{changed})
+	assert.DeepEqual(t, undeploy, []model.Service{db, cache})
+}
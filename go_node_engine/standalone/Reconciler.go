@@ -0,0 +1,222 @@
+package standalone
+
+import (
+	"context"
+	"errors"
+	"go_node_engine/deployment"
+	"go_node_engine/logger"
+	"go_node_engine/model"
+	"go_node_engine/requests"
+	"os"
+	"sync"
+	"time"
+)
+
+// A standalone node runs the services of a local manifest instead of the ones scheduled by a cluster.
+// The instances are deployed through the same runtimes, admission and instance tracking as the control commands,
+// so that they show up in the admin API and the operator subcommands.
+
+var log = logger.Module("standalone")
+
+// RETRY_MAX caps the wait before deploying again an instance that keeps failing
+const RETRY_MAX = 5 * time.Minute
+
+// Run deploys the services of the manifest, then reconciles the deployed instances with it every interval,
+// so that the instances gone in the meantime, e.g. exited or failed, are deployed again. Completed one-shot
+// instances are not run again and failed instances are deployed again after a backoff, starting from every.
+// The manifest is reloaded when modified; an invalid change is discarded and the last valid manifest keeps being applied.
+// The reconciliation stops with ctx.
+func Run(ctx context.Context, path string, every time.Duration) error {
+	lastModTime := modTime(path)
+	manifest, err := LoadManifest(path)
+	if err != nil {
+		return err
+	}
+	log.Info("Running the services of the manifest", "manifest", path, "services", len(manifest.Services))
+	r := newReconciler(every)
+	deployment.OnStatus(r.record)
+	r.reconcile(manifest)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(every):
+			}
+			if currentModTime := modTime(path); !currentModTime.Equal(lastModTime) {
+				lastModTime = currentModTime
+				changed, err := LoadManifest(path)
+				if err != nil {
+					log.Error("Manifest change discarded, applying the last valid manifest", logger.ERROR, err)
+				} else {
+					log.Info("Manifest changed, reconciling the instances", "manifest", path, "services", len(changed.Services))
+					manifest = changed
+				}
+			}
+			r.reconcile(manifest)
+		}
+	}()
+	return nil
+}
+
+// reconciler applies the manifest, remembering how the instances it deployed ended
+type reconciler struct {
+	backoff  requests.Backoff
+	outcomes map[string]outcome
+	lock     sync.Mutex
+}
+
+// outcome is the last deployment of a manifest instance, with its final status once gone
+type outcome struct {
+	spec     model.Service
+	deployed time.Time
+	status   string
+	failures int
+	retry    time.Time
+}
+
+func newReconciler(every time.Duration) *reconciler {
+	return &reconciler{
+		backoff:  requests.Backoff{Initial: every, Max: RETRY_MAX},
+		outcomes: make(map[string]outcome),
+	}
+}
+
+// reconcile undeploys the instances removed from the manifest or changed, then deploys the missing ones that are due
+func (r *reconciler) reconcile(manifest Manifest) {
+	r.forgetRemoved(manifest.Services)
+	deploy, undeploy := plan(manifest.Services, deployment.Instances())
+	for _, service := range undeploy {
+		serviceLog := log.Instance(service.Sname, service.Instance)
+		serviceLog.Info("Undeploying the instance")
+		if err := deployment.Undeploy(service.Sname, service.Instance); err != nil && !errors.Is(err, deployment.ErrNotDeployed) {
+			serviceLog.Error("Unable to undeploy the instance", logger.ERROR, err)
+		}
+	}
+	now := time.Now()
+	for _, service := range deploy {
+		if !r.due(service, now) {
+			continue
+		}
+		serviceLog := log.Instance(service.Sname, service.Instance)
+		serviceLog.Info("Deploying the instance")
+		r.deploying(service, now)
+		if err := deployment.Deploy(service); err != nil {
+			serviceLog.Error("Unable to deploy the instance", logger.ERROR, err)
+		}
+	}
+}
+
+// due tells if a missing instance is to be deployed. Completed one-shot instances are not run again and failed
+// instances wait for their backoff, until their spec changes.
+func (r *reconciler) due(service model.Service, now time.Time) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	last, found := r.outcomes[model.InstanceKey(service.Sname, service.Instance)]
+	if !found || !deployment.SameSpec(last.spec, service) {
+		return true
+	}
+	switch last.status {
+	case model.SERVICE_COMPLETED:
+		return !service.OneShot
+	case model.SERVICE_FAILED, model.SERVICE_DEAD:
+		return !now.Before(last.retry)
+	}
+	return true
+}
+
+// deploying records a deployment of the instance, a changed spec starts with no failures
+func (r *reconciler) deploying(service model.Service, now time.Time) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	key := model.InstanceKey(service.Sname, service.Instance)
+	last := r.outcomes[key]
+	if !deployment.SameSpec(last.spec, service) {
+		last = outcome{}
+	}
+	last.spec = service
+	last.deployed = now
+	last.status = ""
+	r.outcomes[key] = last
+}
+
+// record keeps the final status of the instances deployed by the reconciler, scheduling the retry of the failed ones
+func (r *reconciler) record(service model.Service) {
+	if !deployment.Gone(service) || service.Status == model.SERVICE_UNDEPLOYED {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	key := model.InstanceKey(service.Sname, service.Instance)
+	last, found := r.outcomes[key]
+	if !found || last.status != "" {
+		return
+	}
+	serviceLog := log.Instance(service.Sname, service.Instance)
+	now := time.Now()
+	last.status = service.Status
+	if service.Status == model.SERVICE_COMPLETED {
+		last.failures = 0
+		if last.spec.OneShot {
+			serviceLog.Info("One-shot instance completed, not deploying it again")
+		}
+	} else {
+		// an instance failing after running for long is not crash looping
+		if now.Sub(last.deployed) > r.backoff.Max {
+			last.failures = 0
+		}
+		wait := r.backoff.Delay(last.failures)
+		last.failures++
+		last.retry = now.Add(wait)
+		serviceLog.Warn("Instance gone, deploying it again after a backoff", "status", service.Status, "failures", last.failures, "wait", wait)
+	}
+	r.outcomes[key] = last
+}
+
+// forgetRemoved drops the outcomes of the instances no longer in the manifest, added back they start afresh
+func (r *reconciler) forgetRemoved(desired []model.Service) {
+	keep := make(map[string]bool, len(desired))
+	for _, service := range desired {
+		keep[model.InstanceKey(service.Sname, service.Instance)] = true
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for key := range r.outcomes {
+		if !keep[key] {
+			delete(r.outcomes, key)
+		}
+	}
+}
+
+// plan compares the manifest with the deployed instances. Changed instances are both undeployed and deployed again.
+func plan(desired []model.Service, deployed []model.Service) (deploy []model.Service, undeploy []model.Service) {
+	current := make(map[string]model.Service, len(deployed))
+	for _, service := range deployed {
+		current[model.InstanceKey(service.Sname, service.Instance)] = service
+	}
+	for _, service := range desired {
+		running, found := current[model.InstanceKey(service.Sname, service.Instance)]
+		delete(current, model.InstanceKey(service.Sname, service.Instance))
+		if found && deployment.SameSpec(running, service) {
+			continue
+		}
+		if found {
+			undeploy = append(undeploy, running)
+		}
+		deploy = append(deploy, service)
+	}
+	for _, service := range deployed {
+		if _, removed := current[model.InstanceKey(service.Sname, service.Instance)]; removed {
+			undeploy = append(undeploy, service)
+		}
+	}
+	return deploy, undeploy
+}
+
+func modTime(path string) time.Time {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return stat.ModTime()
+}
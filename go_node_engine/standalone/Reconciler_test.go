@@ -0,0 +1,116 @@
+package standalone
+
+import (
+	"context"
+	"errors"
+	"go_node_engine/deployment"
+	"go_node_engine/e2e"
+	"go_node_engine/model"
+	"go_node_engine/virtualization"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+)
+
+func TestRunRedeploysExitedInstances(t *testing.T) {
+	fake := e2e.NewFakeRuntime()
+	virtualization.RegisterRuntime(model.CONTAINER_RUNTIME, fake)
+
+	runManifest(t, "services:\n  - {job_name: app.worker, image: docker.io/library/busybox:latest}\n")
+	awaitRunning(t, fake, "app.worker")
+
+	assert.NilError(t, fake.Exit("app.worker", 0, model.SERVICE_DEAD, "exit code 137"))
+	awaitRunning(t, fake, "app.worker")
+	instance, deployed := deployment.GetInstance("app.worker", 0)
+	assert.Assert(t, deployed)
+	assert.Equal(t, instance.Image, "docker.io/library/busybox:latest")
+}
+
+func TestRunSkipsCompletedOneShots(t *testing.T) {
+	fake := e2e.NewFakeRuntime()
+	virtualization.RegisterRuntime(model.CONTAINER_RUNTIME, fake)
+
+	runManifest(t, "services:\n  - {job_name: app.migrate, image: docker.io/library/busybox:latest, one_shot: true}\n")
+	awaitRunning(t, fake, "app.migrate")
+
+	assert.NilError(t, fake.Exit("app.migrate", 0, model.SERVICE_COMPLETED, ""))
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, len(fake.Instances()), 0)
+	_, deployed := deployment.GetInstance("app.migrate", 0)
+	assert.Assert(t, !deployed)
+}
+
+func TestReconcileBacksOffFailures(t *testing.T) {
+	fake := e2e.NewFakeRuntime()
+	virtualization.RegisterRuntime(model.CONTAINER_RUNTIME, fake)
+	fake.FailDeploy("app.broken", errors.New("image not found"))
+
+	r := newReconciler(time.Minute)
+	deployment.OnStatus(r.record)
+	service := model.Service{Sname: "app.broken", Image: "docker.io/library/busybox:latest", Runtime: string(model.CONTAINER_RUNTIME)}
+	manifest := Manifest{Services: []model.Service{service}}
+	r.reconcile(manifest)
+	last := awaitOutcome(t, r, service)
+	assert.Equal(t, last.status, model.SERVICE_FAILED)
+	assert.Equal(t, last.failures, 1)
+
+	// the instance waits for its backoff, between half and the whole of the initial delay
+	r.reconcile(manifest)
+	_, deployed := deployment.GetInstance("app.broken", 0)
+	assert.Assert(t, !deployed)
+	assert.Assert(t, !r.due(service, time.Now()))
+	assert.Assert(t, r.due(service, time.Now().Add(time.Minute)))
+
+	// a changed spec is deployed right away
+	changed := service
+	changed.Image = "docker.io/library/busybox:1.36"
+	assert.Assert(t, r.due(changed, time.Now()))
+}
+
+// runManifest runs the manifest until the end of the test, undeploying its instances afterwards
+func runManifest(t *testing.T, content string) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(func() {
+		// the deployments still in progress are undeployed once they complete
+		deadline := time.Now().Add(5 * time.Second)
+		for len(deployment.Instances()) > 0 && time.Now().Before(deadline) {
+			for _, service := range deployment.Instances() {
+				_ = deployment.Undeploy(service.Sname, service.Instance)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	})
+	t.Cleanup(cancel)
+	assert.NilError(t, Run(ctx, writeManifest(t, content), 10*time.Millisecond))
+}
+
+func awaitOutcome(t *testing.T, r *reconciler, service model.Service) outcome {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		r.lock.Lock()
+		last := r.outcomes[model.InstanceKey(service.Sname, service.Instance)]
+		r.lock.Unlock()
+		if last.status != "" {
+			return last
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("no outcome for %s", service.Sname)
+	return outcome{}
+}
+
+func awaitRunning(t *testing.T, fake *e2e.FakeRuntime, sname string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		for _, running := range fake.Instances() {
+			if running.Sname == sname {
+				return
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("instance of %s not running", sname)
+}
@@ -0,0 +1,113 @@
+package tracing
+
+import (
+	"context"
+	"go_node_engine/config"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TRACER_NAME is the instrumentation scope of the NodeEngine spans
+const TRACER_NAME = "go_node_engine"
+
+// SERVICE_NAME identifies the NodeEngine in the collector
+const SERVICE_NAME = "NodeEngine"
+
+// TRACEPARENT is the W3C trace context header, carried as MQTT user property
+const TRACEPARENT = "traceparent"
+
+// Spans of the deployment lifecycle
+const (
+	SPAN_RECEIVE = "mqtt.receive"
+	SPAN_DEPLOY  = "deploy"
+	SPAN_PULL    = "image.pull"
+	SPAN_CREATE  = "instance.create"
+	SPAN_NETWORK = "netmanager.attach"
+	SPAN_START   = "task.start"
+	SPAN_REPORT  = "status.report"
+)
+
+var propagator = propagation.TraceContext{}
+
+// Init exports the spans to the configured OTLP/HTTP collector. Without an endpoint the spans are not recorded.
+// The returned function flushes the pending spans and stops the exporter.
+func Init(cfg config.TracingConfig) (func(context.Context) error, error) {
+	if cfg.Endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+	options := []otlptracehttp.Option{otlptracehttp.WithEndpoint(cfg.Endpoint)}
+	if cfg.Insecure {
+		options = append(options, otlptracehttp.WithInsecure())
+	}
+	exporter, err := otlptracehttp.New(context.Background(), options...)
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+		sdktrace.WithResource(nodeResource()),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+func nodeResource() *resource.Resource {
+	attributes := []attribute.KeyValue{semconv.ServiceName(SERVICE_NAME)}
+	if hostname, err := os.Hostname(); err == nil {
+		attributes = append(attributes, semconv.HostName(hostname))
+	}
+	return resource.NewWithAttributes(semconv.SchemaURL, attributes...)
+}
+
+// Start starts a span of the NodeEngine, child of the span in ctx if any
+func Start(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(TRACER_NAME).Start(ctx, name, trace.WithAttributes(attributes...))
+}
+
+// End ends the span, marking it as failed when err is not nil
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Extract returns ctx continuing the trace of the given traceparent, e.g. the one of a deploy command
+func Extract(ctx context.Context, traceParent string) context.Context {
+	if traceParent == "" {
+		return ctx
+	}
+	return propagator.Extract(ctx, propagation.MapCarrier{TRACEPARENT: traceParent})
+}
+
+// TraceParent returns the traceparent of the span in ctx, empty if there is none
+func TraceParent(ctx context.Context) string {
+	carrier := propagation.MapCarrier{}
+	propagator.Inject(ctx, carrier)
+	return carrier.Get(TRACEPARENT)
+}
+
+// Attach returns base carrying the span of traced, so that the runtime contexts continue the deployment trace
+func Attach(base context.Context, traced context.Context) context.Context {
+	return trace.ContextWithSpan(base, trace.SpanFromContext(traced))
+}
+
+// Instance returns the attributes identifying a service instance
+func Instance(sname string, instance int, runtime string) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("oakestra.service", sname),
+		attribute.Int("oakestra.instance", instance),
+		attribute.String("oakestra.runtime", runtime),
+	}
+}
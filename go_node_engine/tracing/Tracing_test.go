@@ -0,0 +1,78 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"go_node_engine/config"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+	"gotest.tools/assert"
+)
+
+const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+
+// collector is a local stand-in for the OTLP/HTTP collector
+type collector struct {
+	lock    sync.Mutex
+	exports [][]byte
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	if r.URL.Path == "/v1/traces" && r.Header.Get("Content-Type") == "application/x-protobuf" {
+		c.lock.Lock()
+		c.exports = append(c.exports, body)
+		c.lock.Unlock()
+	}
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	w.WriteHeader(http.StatusOK)
+}
+
+func TestExportToCollector(t *testing.T) {
+	received := &collector{}
+	server := httptest.NewServer(received)
+	defer server.Close()
+	previous := otel.GetTracerProvider()
+	defer otel.SetTracerProvider(previous)
+
+	shutdown, err := Init(config.TracingConfig{Endpoint: strings.TrimPrefix(server.URL, "http://"), Insecure: true, SampleRatio: 1})
+	assert.NilError(t, err)
+
+	ctx := Extract(context.Background(), "00-"+traceID+"-00f067aa0ba902b7-01")
+	ctx, deploy := Start(ctx, SPAN_DEPLOY, Instance("app", 1, "docker")...)
+	_, pull := Start(ctx, SPAN_PULL)
+	End(pull, errors.New("pull failed"))
+	End(deploy, nil)
+	assert.Assert(t, strings.HasPrefix(TraceParent(ctx), "00-"+traceID+"-"))
+	assert.NilError(t, shutdown(context.Background()))
+
+	received.lock.Lock()
+	defer received.lock.Unlock()
+	assert.Assert(t, len(received.exports) > 0)
+	export := bytes.Join(received.exports, nil)
+	id, _ := hex.DecodeString(traceID)
+	assert.Assert(t, bytes.Contains(export, id))
+	for _, expected := range []string{SPAN_DEPLOY, SPAN_PULL, "pull failed", SERVICE_NAME} {
+		assert.Assert(t, bytes.Contains(export, []byte(expected)), "missing %s", expected)
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	shutdown, err := Init(config.TracingConfig{SampleRatio: 1})
+	assert.NilError(t, err)
+	assert.NilError(t, shutdown(context.Background()))
+
+	assert.Equal(t, Extract(context.Background(), ""), context.Background())
+	ctx := Extract(context.Background(), "00-"+traceID+"-00f067aa0ba902b7-01")
+	assert.Equal(t, trace.SpanContextFromContext(ctx).TraceID().String(), traceID)
+	assert.Equal(t, TraceParent(context.Background()), "")
+}
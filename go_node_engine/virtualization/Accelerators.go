@@ -0,0 +1,106 @@
+package virtualization
+
+import (
+	"go_node_engine/model"
+	"go_node_engine/model/gpu"
+
+	"github.com/containerd/containerd/contrib/nvidia"
+	"github.com/containerd/containerd/oci"
+)
+
+// acceleratorAssignment contains the IDs of the devices reserved to an instance
+type acceleratorAssignment struct {
+	gpus []string
+	tpus []string
+}
+
+// allocateAccelerators reserves the GPUs and TPUs requested by the service, all or nothing
+func allocateAccelerators(taskid string, service model.Service) (acceleratorAssignment, error) {
+	gpus, err := gpu.GetAllocator(gpu.GPU).Allocate(taskid, service.Vgpus)
+	if err != nil {
+		return acceleratorAssignment{}, err
+	}
+	tpus, err := gpu.GetAllocator(gpu.TPU).Allocate(taskid, service.Vtpus)
+	if err != nil {
+		gpu.GetAllocator(gpu.GPU).ReleaseDevices(taskid, gpus)
+		return acceleratorAssignment{}, err
+	}
+	return acceleratorAssignment{gpus: gpus, tpus: tpus}, nil
+}
+
+// allocateReplacement reserves the GPUs and TPUs of a container replacing the current one of the instance, reusing
+// the devices of the current container first, so that an update does not need free devices for what it keeps.
+// The reused devices go back to the current container if the replacement is released before keep.
+func allocateReplacement(currentID string, replacementID string, service model.Service) (acceleratorAssignment, error) {
+	gpus, err := gpu.GetAllocator(gpu.GPU).AllocateFrom(currentID, replacementID, service.Vgpus)
+	if err != nil {
+		return acceleratorAssignment{}, err
+	}
+	tpus, err := gpu.GetAllocator(gpu.TPU).AllocateFrom(currentID, replacementID, service.Vtpus)
+	if err != nil {
+		gpu.GetAllocator(gpu.GPU).ReleaseDevices(replacementID, gpus)
+		return acceleratorAssignment{}, err
+	}
+	return acceleratorAssignment{gpus: gpus, tpus: tpus}, nil
+}
+
+// keepAccelerators makes the devices reused by a replacement its own, once it replaced the current container
+func keepAccelerators(replacementID string) {
+	gpu.GetAllocator(gpu.GPU).Keep(replacementID)
+	gpu.GetAllocator(gpu.TPU).Keep(replacementID)
+}
+
+// release frees the devices of the assignment, leaving any other device of the instance assigned
+func (a acceleratorAssignment) release(taskid string) {
+	gpu.GetAllocator(gpu.GPU).ReleaseDevices(taskid, a.gpus)
+	gpu.GetAllocator(gpu.TPU).ReleaseDevices(taskid, a.tpus)
+}
+
+// releaseAccelerators frees all the devices reserved to the instance
+func releaseAccelerators(taskid string) {
+	gpu.GetAllocator(gpu.GPU).Release(taskid)
+	gpu.GetAllocator(gpu.TPU).Release(taskid)
+}
+
+// specOpts maps the assigned devices to the OCI options exposing them to the container.
+// NVIDIA GPUs go through the nvidia container hook, any other device is bound from its host device node.
+func (a acceleratorAssignment) specOpts() []oci.SpecOpts {
+	specOpts := make([]oci.SpecOpts, 0)
+	snapshot := gpu.GetCollector().Collect()
+
+	nvidiaDevices := make([]int, 0)
+	devicePaths := make([]string, 0)
+	for _, device := range assignedDevices(snapshot.Gpus, a.gpus) {
+		if device.Vendor == "nvidia" {
+			nvidiaDevices = append(nvidiaDevices, device.Index)
+			continue
+		}
+		devicePaths = append(devicePaths, device.DevicePaths...)
+	}
+	for _, device := range assignedDevices(snapshot.Tpus, a.tpus) {
+		devicePaths = append(devicePaths, device.DevicePaths...)
+	}
+
+	if len(nvidiaDevices) > 0 {
+		specOpts = append(specOpts, nvidia.WithGPUs(nvidia.WithDevices(nvidiaDevices...), nvidia.WithAllCapabilities))
+		log.Info("Adding the NVIDIA GPU driver", "devices", nvidiaDevices)
+	}
+	for _, path := range devicePaths {
+		specOpts = append(specOpts, oci.WithDevices(path, "", "rwm"))
+		log.Info("Adding accelerator device", "path", path)
+	}
+	return specOpts
+}
+
+// assignedDevices returns the devices with the given IDs, skipping the ones no longer present
+func assignedDevices(devices []gpu.Device, ids []string) []gpu.Device {
+	assigned := make([]gpu.Device, 0, len(ids))
+	for _, id := range ids {
+		for _, device := range devices {
+			if gpu.DeviceID(device) == id {
+				assigned = append(assigned, device)
+			}
+		}
+	}
+	return assigned
+}
@@ -4,9 +4,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"go_node_engine/config"
 	"go_node_engine/logger"
+	"go_node_engine/metrics"
 	"go_node_engine/model"
 	"go_node_engine/requests"
+	"go_node_engine/tracing"
 	"os"
 	"reflect"
 	"strconv"
@@ -17,13 +20,13 @@ import (
 	"github.com/containerd/containerd"
 	"github.com/containerd/containerd/cio"
 	"github.com/containerd/containerd/containers"
-	"github.com/containerd/containerd/contrib/nvidia"
 	"github.com/containerd/containerd/namespaces"
 	"github.com/containerd/containerd/oci"
 	"github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/shirou/gopsutil/docker"
 	"github.com/shirou/gopsutil/process"
 	"github.com/struCoder/pidusage"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // ContainerRuntime is the struct that describes the container runtime
@@ -32,36 +35,47 @@ type ContainerRuntime struct {
 	killQueue      map[string]*chan bool
 	channelLock    *sync.RWMutex
 	ctx            context.Context
+	namespace      string
+	// containers maps each task to its current container, which differs once updated in place
+	containers map[string]string
+	// exits are closed when the routine of the container ends
+	exits map[string]chan struct{}
+	// silenced containers were replaced by an update, their end is not reported
+	silenced   map[string]bool
+	revision   int
+	updateLock sync.Mutex
 }
 
 var runtime = ContainerRuntime{
 	channelLock: &sync.RWMutex{},
+	containers:  make(map[string]string),
+	exits:       make(map[string]chan struct{}),
+	silenced:    make(map[string]bool),
 }
 
 var containerdSingletonCLient sync.Once
 var startContainerMonitoring sync.Once
 
-// NAMESPACE is the namespace of the runtime
-const NAMESPACE = "oakestra"
+// CGROUPV1_BASE_MEM is the base memory path for cgroup v1, the runtime namespace is appended to it
+const CGROUPV1_BASE_MEM = "/sys/fs/cgroup/memory/"
 
-// CGROUPV1_BASE_MEM is the base memory path for cgroup v1
-const CGROUPV1_BASE_MEM = "/sys/fs/cgroup/memory/" + NAMESPACE
-
-// CGROUPV2_BASE_MEM is the base memory path for cgroup v2
-const CGROUPV2_BASE_MEM = "/sys/fs/cgroup/" + NAMESPACE
+// CGROUPV2_BASE_MEM is the base memory path for cgroup v2, the runtime namespace is appended to it
+const CGROUPV2_BASE_MEM = "/sys/fs/cgroup/"
 
 // GetContainerdClient returns the container runtime client
 func GetContainerdClient() *ContainerRuntime {
 	containerdSingletonCLient.Do(func() {
-		client, err := containerd.New("/run/containerd/containerd.sock")
+		cfg := config.Get().Runtime
+		client, err := containerd.New(cfg.ContainerdSocket)
 		if err != nil {
-			logger.ErrorLogger().Fatalf("Unable to start the container engine: %v\n", err)
+			log.Fatal("Unable to start the container engine", logger.ERROR, err)
 		}
 		runtime.contaierClient = client
 		runtime.killQueue = make(map[string]*chan bool)
-		runtime.ctx = namespaces.WithNamespace(context.Background(), NAMESPACE)
+		runtime.namespace = cfg.Namespace
+		runtime.ctx = namespaces.WithNamespace(context.Background(), cfg.Namespace)
 		runtime.forceContainerCleanup()
-		model.GetNodeInfo().AddSupportedTechnology(model.CONTAINER_RUNTIME)
+		model.AddSupportedTechnology(model.CONTAINER_RUNTIME)
 	})
 	return &runtime
 }
@@ -75,55 +89,105 @@ func (r *ContainerRuntime) StopContainerdClient() {
 	for _, taskid := range taskIDs {
 		err := r.Undeploy(extractSnameFromTaskID(taskid.String()), extractInstanceNumberFromTaskID(taskid.String()))
 		if err != nil {
-			logger.ErrorLogger().Printf("Unable to undeploy %s, error: %v", taskid.String(), err)
+			log.Error("Unable to undeploy", "task", taskid.String(), logger.ERROR, err)
 		}
 	}
 	if err := r.contaierClient.Close(); err != nil {
-		logger.ErrorLogger().Printf("Unable to close containerd client: %v", err)
+		log.Error("Unable to close containerd client", logger.ERROR, err)
 	}
 
 }
 
 // Deploy deploys a service
-func (r *ContainerRuntime) Deploy(service model.Service, statusChangeNotificationHandler func(service model.Service)) error {
-
-	var image containerd.Image
-	// pull the given image
-	sysimg, err := r.contaierClient.ImageService().Get(r.ctx, service.Image)
-	if err == nil {
-		image = containerd.NewImage(r.contaierClient, sysimg)
-	} else {
-		logger.ErrorLogger().Printf("Error retrieving the image: %v \n Trying to pull the image online.", err)
+func (r *ContainerRuntime) Deploy(ctx context.Context, service model.Service, statusChangeNotificationHandler func(service model.Service)) error {
 
-		image, err = r.contaierClient.Pull(r.ctx, service.Image, containerd.WithPullUnpack)
-		if err != nil {
-			return err
-		}
-	}
+	taskid := model.InstanceKey(service.Sname, service.Instance)
 
+	// claim the instance first, a duplicate deploy must neither allocate nor release the devices of the running one
 	killChannel := make(chan bool, 1)
-	startupChannel := make(chan bool, 0)
-	errorChannel := make(chan error, 0)
-
-	r.channelLock.RLock()
-	el, servicefound := r.killQueue[genTaskID(service.Sname, service.Instance)]
-	r.channelLock.RUnlock()
-	if !servicefound || el == nil {
-		r.channelLock.Lock()
-		r.killQueue[genTaskID(service.Sname, service.Instance)] = &killChannel
+	r.channelLock.Lock()
+	if el, servicefound := r.killQueue[taskid]; servicefound && el != nil {
 		r.channelLock.Unlock()
-	} else {
 		return errors.New("Service already deployed")
 	}
+	r.killQueue[taskid] = &killChannel
+	r.channelLock.Unlock()
+
+	// reserve the accelerator devices before pulling anything, rejecting the deployment if they are not available
+	accelerators, err := allocateAccelerators(taskid, service)
+	if err != nil {
+		r.unclaim(taskid, &killChannel)
+		return err
+	}
+
+	image, err := r.getImage(ctx, service.Image)
+	if err != nil {
+		accelerators.release(taskid)
+		r.unclaim(taskid, &killChannel)
+		return err
+	}
+
+	r.updateLock.Lock()
+	r.containers[taskid] = taskid
+	r.updateLock.Unlock()
+
+	return r.startContainer(ctx, image, taskid, service, accelerators, &killChannel, statusChangeNotificationHandler)
+}
+
+// unclaim frees the instance claimed by a deployment that did not start
+func (r *ContainerRuntime) unclaim(taskid string, killChannel *chan bool) {
+	r.channelLock.Lock()
+	defer r.channelLock.Unlock()
+	if r.killQueue[taskid] == killChannel {
+		r.killQueue[taskid] = nil
+	}
+}
+
+// getImage returns the given image, pulling it if not available locally
+func (r *ContainerRuntime) getImage(ctx context.Context, ref string) (containerd.Image, error) {
+	_, span := tracing.Start(ctx, tracing.SPAN_PULL, attribute.String("oakestra.image", ref))
+	sysimg, err := r.contaierClient.ImageService().Get(r.ctx, ref)
+	if err == nil {
+		span.SetAttributes(attribute.Bool("oakestra.image.cached", true))
+		span.End()
+		return containerd.NewImage(r.contaierClient, sysimg), nil
+	}
+	log.Info("Image not found locally, pulling it", "image", ref, logger.ERROR, err)
+	start := time.Now()
+	image, err := r.contaierClient.Pull(r.ctx, ref, containerd.WithPullUnpack)
+	if err == nil {
+		metrics.PullDuration.WithLabelValues(string(model.CONTAINER_RUNTIME)).Observe(time.Since(start).Seconds())
+	}
+	tracing.End(span, err)
+	return image, err
+}
+
+// startContainer creates the container of the service instance and waits for its task to start
+func (r *ContainerRuntime) startContainer(
+	ctx context.Context,
+	image containerd.Image,
+	containerID string,
+	service model.Service,
+	accelerators acceleratorAssignment,
+	killChannel *chan bool,
+	statusChangeNotificationHandler func(service model.Service),
+) error {
+	startupChannel := make(chan bool, 0)
+	errorChannel := make(chan error, 0)
+	r.updateLock.Lock()
+	r.exits[containerID] = make(chan struct{})
+	r.updateLock.Unlock()
 
 	// create startup routine which will accompany the container through its lifetime
 	go r.containerCreationRoutine(
-		r.ctx,
+		tracing.Attach(r.ctx, ctx),
 		image,
+		containerID,
 		service,
+		accelerators,
 		startupChannel,
 		errorChannel,
-		&killChannel,
+		killChannel,
 		statusChangeNotificationHandler,
 	)
 
@@ -131,7 +195,6 @@ func (r *ContainerRuntime) Deploy(service model.Service, statusChangeNotificatio
 	if <-startupChannel != true {
 		return <-errorChannel
 	}
-
 	return nil
 }
 
@@ -139,18 +202,19 @@ func (r *ContainerRuntime) Deploy(service model.Service, statusChangeNotificatio
 func (r *ContainerRuntime) Undeploy(service string, instance int) error {
 	r.channelLock.Lock()
 	defer r.channelLock.Unlock()
-	taskid := genTaskID(service, instance)
+	taskid := model.InstanceKey(service, instance)
 	el, found := r.killQueue[taskid]
 	if found && el != nil {
-		logger.InfoLogger().Printf("Sending kill signal to %s", taskid)
+		serviceLog := log.Instance(service, instance)
+		serviceLog.Info("Sending kill signal", "task", taskid)
 		*r.killQueue[taskid] <- true
 		select {
 		case res := <-*r.killQueue[taskid]:
 			if res == false {
-				logger.ErrorLogger().Printf("Unable to stop service %s", taskid)
+				serviceLog.Error("Unable to stop service", "task", taskid)
 			}
 		case <-time.After(5 * time.Second):
-			logger.ErrorLogger().Printf("Unable to stop service %s", taskid)
+			serviceLog.Error("Unable to stop service in time", "task", taskid)
 		}
 		delete(r.killQueue, taskid)
 		return nil
@@ -161,22 +225,30 @@ func (r *ContainerRuntime) Undeploy(service string, instance int) error {
 func (r *ContainerRuntime) containerCreationRoutine(
 	ctx context.Context,
 	image containerd.Image,
+	containerID string,
 	service model.Service,
+	accelerators acceleratorAssignment,
 	startup chan bool,
 	errorchan chan error,
 	killChannel *chan bool,
 	statusChangeNotificationHandler func(service model.Service),
 ) {
 
-	taskid := genTaskID(service.Sname, service.Instance)
+	taskid := model.InstanceKey(service.Sname, service.Instance)
 	hostname := fmt.Sprintf("instance-%d", service.Instance)
+	serviceLog := log.Instance(service.Sname, service.Instance)
+	defer r.containerExited(containerID)
 
 	revert := func(err error) {
+		accelerators.release(containerID)
 		startup <- false
 		errorchan <- err
 		r.channelLock.Lock()
 		defer r.channelLock.Unlock()
-		r.killQueue[taskid] = nil
+		// a replacement failing to start must not clear the instance it was replacing
+		if r.killQueue[taskid] == killChannel {
+			r.killQueue[taskid] = nil
+		}
 	}
 
 	//create container general oci specs
@@ -190,13 +262,10 @@ func (r *ContainerRuntime) containerCreationRoutine(
 	if len(service.Commands) > 0 {
 		specOpts = append(specOpts, oci.WithProcessArgs(service.Commands...))
 	}
-	//add GPU if needed
-	if service.Vgpus > 0 {
-		specOpts = append(specOpts, nvidia.WithGPUs(nvidia.WithDevices(0), nvidia.WithAllCapabilities))
-		logger.InfoLogger().Printf("NVIDIA - Adding GPU driver")
-	}
-	//add resolve file with default google dns
-	resolvconfFile, err := getGoogleDNSResolveConf()
+	//add GPUs and TPUs if needed
+	specOpts = append(specOpts, accelerators.specOpts()...)
+	//add resolve file with the configured nameservers
+	resolvconfFile, err := getResolveConf(config.Get().Network.Nameservers)
 	if err != nil {
 		revert(err)
 		return
@@ -204,7 +273,7 @@ func (r *ContainerRuntime) containerCreationRoutine(
 	//defer resolvconfFile.Close()
 	defer func() {
 		if err := resolvconfFile.Close(); err != nil {
-			logger.ErrorLogger().Printf("Unable to close resolvconf file: %v", err)
+			serviceLog.Error("Unable to close resolvconf file", logger.ERROR, err)
 		}
 	}()
 
@@ -213,45 +282,52 @@ func (r *ContainerRuntime) containerCreationRoutine(
 	specOpts = append(specOpts, withCustomResolvConf(resolvconfFile.Name()))
 
 	// create the container
+	_, createSpan := tracing.Start(ctx, tracing.SPAN_CREATE, attribute.String("oakestra.container", containerID))
 	container, err := r.contaierClient.NewContainer(
 		ctx,
-		taskid,
+		containerID,
 		containerd.WithImage(image),
-		containerd.WithNewSnapshot(fmt.Sprintf("%s-snapshotter", taskid), image),
+		containerd.WithNewSnapshot(fmt.Sprintf("%s-snapshotter", containerID), image),
 		containerd.WithNewSpec(specOpts...),
 	)
 	if err != nil {
+		tracing.End(createSpan, err)
 		revert(err)
 		return
 	}
 
 	//	start task with /tmp/hostname default log directory
-	file, err := os.OpenFile(fmt.Sprintf("%s/%s", model.GetNodeInfo().LogDirectory, taskid), os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	file, err := os.OpenFile(logPath(taskid), os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
 	if err != nil {
+		tracing.End(createSpan, err)
 		revert(err)
 		return
 	}
 	//defer file.Close()
 	defer func() {
 		if err := file.Close(); err != nil {
-			logger.ErrorLogger().Printf("Unable to close log file: %v", err)
+			serviceLog.Error("Unable to close log file", logger.ERROR, err)
 		}
 	}()
 
 	task, err := container.NewTask(ctx, cio.NewCreator(cio.WithStreams(nil, file, file)))
 
 	if err != nil {
-		logger.ErrorLogger().Printf("ERROR: containerd task creation failure: %v", err)
+		serviceLog.Error("Containerd task creation failure", logger.ERROR, err)
 		_ = container.Delete(ctx)
+		tracing.End(createSpan, err)
 		revert(err)
 		return
 	}
+	createSpan.End()
 	defer func(ctx context.Context, task containerd.Task) {
 		err := killTask(ctx, task, container)
-		//removing from killqueue
+		//removing from killqueue, unless already replaced by an update
 		r.channelLock.Lock()
 		defer r.channelLock.Unlock()
-		r.killQueue[taskid] = nil
+		if r.killQueue[taskid] == killChannel {
+			r.killQueue[taskid] = nil
+		}
 		if err != nil {
 			*killChannel <- false
 		} else {
@@ -262,28 +338,33 @@ func (r *ContainerRuntime) containerCreationRoutine(
 	// get wait channel
 	exitStatusC, err := task.Wait(ctx)
 	if err != nil {
-		logger.ErrorLogger().Printf("ERROR: containerd task wait failure: %v", err)
+		serviceLog.Error("Containerd task wait failure", logger.ERROR, err)
 		revert(err)
 		return
 	}
 
 	// if Overlay mode is active then attach network to the task
-	if model.GetNodeInfo().Overlay {
+	if model.GetCachedNodeInfo().Overlay {
 		taskpid := int(task.Pid())
+		_, networkSpan := tracing.Start(ctx, tracing.SPAN_NETWORK)
 		err = requests.AttachNetworkToTask(taskpid, service.Sname, service.Instance, service.Ports)
+		tracing.End(networkSpan, err)
 		if err != nil {
-			logger.ErrorLogger().Printf("Unable to attach network interface to the task: %v", err)
+			serviceLog.Error("Unable to attach network interface to the task", logger.ERROR, err)
 			revert(err)
 			return
 		}
 	}
 
 	// execute the image's task
+	_, startSpan := tracing.Start(ctx, tracing.SPAN_START)
 	if err := task.Start(ctx); err != nil {
-		logger.ErrorLogger().Printf("ERROR: containerd task start failure: %v", err)
+		serviceLog.Error("Containerd task start failure", logger.ERROR, err)
+		tracing.End(startSpan, err)
 		revert(err)
 		return
 	}
+	startSpan.End()
 
 	// adv startup finished
 	startup <- true
@@ -298,10 +379,10 @@ func (r *ContainerRuntime) containerCreationRoutine(
 		if err != nil {
 			return
 		}
-		logger.InfoLogger().Printf("WARNING: Container exited with status %d", exitStatus.ExitCode())
+		serviceLog.Warn("Container exited", "exit_code", exitStatus.ExitCode())
 		service.StatusDetail = fmt.Sprintf("Container exited with status: %d", exitStatus.ExitCode())
 	case <-*killChannel:
-		logger.InfoLogger().Printf("Kill channel message received for task %s", task.ID())
+		serviceLog.Info("Kill channel message received", "task", task.ID())
 	}
 
 	if service.Status != model.SERVICE_COMPLETED {
@@ -309,18 +390,36 @@ func (r *ContainerRuntime) containerCreationRoutine(
 	}
 
 	//detaching network
-	if model.GetNodeInfo().Overlay {
+	if model.GetCachedNodeInfo().Overlay {
 		_ = requests.DetachNetworkFromTask(service.Sname, service.Instance)
 	}
-	statusChangeNotificationHandler(service)
+	r.updateLock.Lock()
+	replaced := r.silenced[containerID]
+	delete(r.silenced, containerID)
+	r.updateLock.Unlock()
+	// a replaced container is not the end of the instance, the replacement reports its status
+	if !replaced {
+		statusChangeNotificationHandler(service)
+	}
 	r.removeContainer(container)
+	releaseAccelerators(containerID)
+}
+
+// containerExited signals the end of the container routine
+func (r *ContainerRuntime) containerExited(containerID string) {
+	r.updateLock.Lock()
+	defer r.updateLock.Unlock()
+	if exited, found := r.exits[containerID]; found {
+		close(exited)
+		delete(r.exits, containerID)
+	}
 }
 
 func getTotalCpuUsageByPid(pid int32) (float64, error) {
 	totCpu := 0.0
 	procs, err := process.NewProcess(pid)
 	if err != nil {
-		logger.ErrorLogger().Printf("ERROR: %v", err)
+		log.Error("Unable to read the task process", "pid", pid, logger.ERROR, err)
 		return 0, err
 	}
 
@@ -332,31 +431,34 @@ func getTotalCpuUsageByPid(pid int32) (float64, error) {
 	for _, child := range children {
 		cpuUsage, err := child.CPUPercent()
 		if err != nil {
-			logger.ErrorLogger().Printf("ERROR: %v", err)
+			log.Error("Unable to read the CPU usage of the task process", "pid", child.Pid, logger.ERROR, err)
 			return 0, err
 		}
 		totCpu += cpuUsage
 	}
-	return totCpu / float64(model.GetNodeInfo().CpuCores), nil
+	return totCpu / float64(model.GetCachedNodeInfo().CpuCores), nil
 }
 
-func (r *ContainerRuntime) ResourceMonitoring(every time.Duration, notifyHandler func(res []model.Resources)) {
+func (r *ContainerRuntime) ResourceMonitoring(every func() time.Duration, notifyHandler func(res []model.Resources)) {
 	//start container monitoring service
 	startContainerMonitoring.Do(func() {
 		for true {
 			select {
-			case <-time.After(every):
+			case <-time.After(every()):
 				deployedContainers, err := r.contaierClient.Containers(r.ctx)
 				if err != nil {
-					logger.ErrorLogger().Printf("Unable to fetch running containers: %v", err)
+					log.Error("Unable to fetch running containers", logger.ERROR, err)
 				}
 
 				resourceList := make([]model.Resources, 0)
 
 				for _, container := range deployedContainers {
+					sname := extractSnameFromTaskID(container.ID())
+					instance := extractInstanceNumberFromTaskID(container.ID())
+					serviceLog := log.Instance(sname, instance)
 					task, err := container.Task(r.ctx, nil)
 					if err != nil {
-						logger.ErrorLogger().Printf("Unable to fetch container task: %v", err)
+						serviceLog.Error("Unable to fetch container task", logger.ERROR, err)
 						continue
 					}
 
@@ -364,38 +466,38 @@ func (r *ContainerRuntime) ResourceMonitoring(every time.Duration, notifyHandler
 					if err != nil {
 						sysInfo, err := pidusage.GetStat(int(task.Pid()))
 						if err != nil {
-							logger.ErrorLogger().Printf("Unable to fetch task info: %v", err)
+							serviceLog.Error("Unable to fetch task info", logger.ERROR, err)
 							continue
 						}
-						cpuUsage = sysInfo.CPU / float64(model.GetNodeInfo().CpuCores)
+						cpuUsage = sysInfo.CPU / float64(model.GetCachedNodeInfo().CpuCores)
 					}
 
 					mem, err := r.getContainerMemoryUsage(container.ID(), int(task.Pid()))
 					if err != nil {
-						logger.ErrorLogger().Printf("Unable to fetch container Memory: %v", err)
+						serviceLog.Error("Unable to fetch container memory", logger.ERROR, err)
 						mem = 0
 					}
 
 					containerMetadata, err := container.Info(r.ctx)
 					if err != nil {
-						logger.ErrorLogger().Printf("Unable to fetch container metadata: %v", err)
+						serviceLog.Error("Unable to fetch container metadata", logger.ERROR, err)
 						continue
 					}
 					currentsnapshotter := r.contaierClient.SnapshotService(containerd.DefaultSnapshotter)
 					usage, err := currentsnapshotter.Usage(r.ctx, containerMetadata.SnapshotKey)
 					if err != nil {
-						logger.ErrorLogger().Printf("Unable to fetch task disk usage: %v", err)
+						serviceLog.Error("Unable to fetch task disk usage", logger.ERROR, err)
 						continue
 					}
 
 					resourceList = append(resourceList, model.Resources{
-						Cpu:      fmt.Sprintf("%f", cpuUsage),
-						Memory:   fmt.Sprintf("%f", mem),
-						Disk:     fmt.Sprintf("%d", usage.Size),
-						Sname:    extractSnameFromTaskID(container.ID()),
+						Cpu:      cpuUsage,
+						Memory:   mem,
+						Disk:     usage.Size,
+						Sname:    sname,
 						Runtime:  string(model.CONTAINER_RUNTIME),
-						Logs:     getLogs(container.ID()),
-						Instance: extractInstanceNumberFromTaskID(container.ID()),
+						Logs:     getLogs(model.InstanceKey(sname, instance)),
+						Instance: instance,
 					})
 				}
 				//NOTIFY WITH THE CURRENT CONTAINERS STATUS
@@ -408,7 +510,7 @@ func (r *ContainerRuntime) ResourceMonitoring(every time.Duration, notifyHandler
 func (r *ContainerRuntime) forceContainerCleanup() {
 	deployedContainers, err := r.contaierClient.Containers(r.ctx)
 	if err != nil {
-		logger.ErrorLogger().Printf("Unable to fetch running containers: %v", err)
+		log.Error("Unable to fetch running containers", logger.ERROR, err)
 	}
 	for _, container := range deployedContainers {
 		r.removeContainer(container)
@@ -416,29 +518,30 @@ func (r *ContainerRuntime) forceContainerCleanup() {
 }
 
 func (r *ContainerRuntime) removeContainer(container containerd.Container) {
-	logger.InfoLogger().Printf("Clenaning up container: %s", container.ID())
+	containerLog := log.With("container", container.ID())
+	containerLog.Info("Cleaning up container")
 	task, err := container.Task(r.ctx, nil)
 	if err != nil {
-		logger.ErrorLogger().Printf("Unable to fetch container task: %v", err)
+		containerLog.Error("Unable to fetch container task", logger.ERROR, err)
 	}
 	if err == nil {
 		err = killTask(r.ctx, task, container)
 		if err != nil {
-			logger.ErrorLogger().Printf("Unable to fetch kill task: %v", err)
+			containerLog.Error("Unable to kill task", logger.ERROR, err)
 		}
 	}
 	err = container.Delete(r.ctx)
 	if err != nil {
-		logger.ErrorLogger().Printf("Unable to delete container: %v", err)
+		containerLog.Error("Unable to delete container", logger.ERROR, err)
 	}
 }
 
 func (r *ContainerRuntime) getContainerMemoryUsage(containerID string, pid int) (float64, error) {
 	//trying fetching memory using CGROUP_V1 path
-	mem, err := docker.CgroupMem(containerID, CGROUPV1_BASE_MEM)
+	mem, err := docker.CgroupMem(containerID, CGROUPV1_BASE_MEM+r.namespace)
 	if err != nil {
 		//trying fetching memory using CGROUP_V2 path
-		mem, err = docker.CgroupMem(containerID, CGROUPV2_BASE_MEM)
+		mem, err = docker.CgroupMem(containerID, CGROUPV2_BASE_MEM+r.namespace)
 		if err != nil {
 			//unable to get memory usage from CGROUPS, likely disabled. Defaulting to PID memory consumption
 			sysInfo, err := pidusage.GetStat(pid)
@@ -463,17 +566,21 @@ func withCustomResolvConf(src string) func(context.Context, oci.Client, *contain
 	}
 }
 
-func getGoogleDNSResolveConf() (*os.File, error) {
+func getResolveConf(nameservers []string) (*os.File, error) {
 	//file, err := ioutil.TempFile("/tmp", "edgeio-resolv-conf")
 	file, err := os.CreateTemp("/tmp", "edgeio-resolv-conf")
 
 	if err != nil {
-		logger.ErrorLogger().Printf("Unable to create temp resolv file: %v", err)
+		log.Error("Unable to create temp resolv file", logger.ERROR, err)
 		return nil, err
 	}
-	_, err = file.WriteString(fmt.Sprintf("nameserver 8.8.8.8\n"))
+	resolvconf := ""
+	for _, nameserver := range nameservers {
+		resolvconf += fmt.Sprintf("nameserver %s\n", nameserver)
+	}
+	_, err = file.WriteString(resolvconf)
 	if err != nil {
-		logger.ErrorLogger().Printf("Unable to write temp resolv file: %v", err)
+		log.Error("Unable to write temp resolv file", logger.ERROR, err)
 		return nil, err
 	}
 	return file, err
@@ -483,18 +590,18 @@ func killTask(ctx context.Context, task containerd.Task, container containerd.Co
 	//removing the task
 	p, err := task.LoadProcess(ctx, task.ID(), nil)
 	if err != nil {
-		logger.ErrorLogger().Printf("ERROR deleting the task, LoadProcess: %v", err)
+		log.Error("Unable to delete the task, LoadProcess failed", "task", task.ID(), logger.ERROR, err)
 		return err
 	}
 	_, err = p.Delete(ctx, containerd.WithProcessKill)
 	if err != nil {
-		logger.ErrorLogger().Printf("ERROR deleting the task, Delete: %v", err)
+		log.Error("Unable to delete the task, Delete failed", "task", task.ID(), logger.ERROR, err)
 		return err
 	}
 	_, _ = task.Delete(ctx)
 	_ = container.Delete(ctx)
 
-	logger.ErrorLogger().Printf("Task %s terminated", task.ID())
+	log.Info("Task terminated", "task", task.ID())
 	return nil
 }
 
@@ -512,14 +619,15 @@ func extractInstanceNumberFromTaskID(taskid string) int {
 	separator := ".instance"
 	index := strings.LastIndex(taskid, separator)
 	if index > 0 {
-		number, err := strconv.Atoi(taskid[index+len(separator)+1:])
+		number := taskid[index+len(separator)+1:]
+		// containers updated in place carry a revision suffix
+		if dot := strings.Index(number, "."); dot >= 0 {
+			number = number[:dot]
+		}
+		parsed, err := strconv.Atoi(number)
 		if err == nil {
-			instance = number
+			instance = parsed
 		}
 	}
 	return instance
 }
-
-func genTaskID(sname string, instancenumber int) string {
-	return fmt.Sprintf("%s.instance.%d", sname, instancenumber)
-}
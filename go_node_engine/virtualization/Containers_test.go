@@ -1,6 +1,7 @@
 package virtualization
 
 import (
+	"go_node_engine/model"
 	"testing"
 
 	"gotest.tools/assert"
@@ -8,14 +9,14 @@ import (
 
 func TestSnameExtractionFromTaskid(t *testing.T) {
 	sname := "test.test.nginx.test"
-	taskid := genTaskID(sname, 23)
+	taskid := model.InstanceKey(sname, 23)
 	extracted := extractSnameFromTaskID(taskid)
 	assert.Equal(t, extracted, sname)
 }
 
 func TestSnameExtractionFromTaskidMultipleInstance(t *testing.T) {
 	sname := "test.test.instance.test"
-	taskid := genTaskID(sname, 23)
+	taskid := model.InstanceKey(sname, 23)
 	extracted := extractSnameFromTaskID(taskid)
 	assert.Equal(t, extracted, sname)
 }
@@ -23,7 +24,7 @@ func TestSnameExtractionFromTaskidMultipleInstance(t *testing.T) {
 func TestInstanceExtractionFromTaskid(t *testing.T) {
 	sname := "test.test.nginx.test"
 	iid := 23
-	taskid := genTaskID(sname, iid)
+	taskid := model.InstanceKey(sname, iid)
 	extracted := extractInstanceNumberFromTaskID(taskid)
 	assert.Equal(t, extracted, iid)
 }
@@ -31,7 +32,14 @@ func TestInstanceExtractionFromTaskid(t *testing.T) {
 func TestInstanceExtractionFromTaskidMultipleInstance(t *testing.T) {
 	sname := "test.test.instance.test"
 	iid := 23
-	taskid := genTaskID(sname, iid)
+	taskid := model.InstanceKey(sname, iid)
 	extracted := extractInstanceNumberFromTaskID(taskid)
 	assert.Equal(t, extracted, iid)
 }
+
+func TestExtractionFromUpdatedContainer(t *testing.T) {
+	sname := "test.test.instance.test"
+	containerID := model.InstanceKey(sname, 23) + ".rev4"
+	assert.Equal(t, extractSnameFromTaskID(containerID), sname)
+	assert.Equal(t, extractInstanceNumberFromTaskID(containerID), 23)
+}
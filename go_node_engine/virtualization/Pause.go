@@ -0,0 +1,90 @@
+package virtualization
+
+import (
+	"errors"
+	"fmt"
+	"go_node_engine/logger"
+	"go_node_engine/model"
+	"time"
+
+	"github.com/containerd/containerd"
+	"github.com/digitalocean/go-qemu/qmp"
+)
+
+// QMP_TIMEOUT bounds the connection to the QMP socket of a VM
+const QMP_TIMEOUT = 2 * time.Second
+
+// Pause freezes the processes of the instance container
+func (r *ContainerRuntime) Pause(sname string, instance int) error {
+	task, err := r.task(sname, instance)
+	if err != nil {
+		return err
+	}
+	log.Instance(sname, instance).Info("Pausing container", "task", task.ID())
+	return task.Pause(r.ctx)
+}
+
+// Resume lets the processes of a paused instance container continue
+func (r *ContainerRuntime) Resume(sname string, instance int) error {
+	task, err := r.task(sname, instance)
+	if err != nil {
+		return err
+	}
+	log.Instance(sname, instance).Info("Resuming container", "task", task.ID())
+	return task.Resume(r.ctx)
+}
+
+// task returns the containerd task currently running the instance, the replacement one if updated in place
+func (r *ContainerRuntime) task(sname string, instance int) (containerd.Task, error) {
+	taskid := model.InstanceKey(sname, instance)
+	r.channelLock.RLock()
+	el, found := r.killQueue[taskid]
+	r.channelLock.RUnlock()
+	if !found || el == nil {
+		return nil, errors.New("service not found")
+	}
+	r.updateLock.Lock()
+	containerID := r.containers[taskid]
+	r.updateLock.Unlock()
+	container, err := r.contaierClient.LoadContainer(r.ctx, containerID)
+	if err != nil {
+		return nil, err
+	}
+	return container.Task(r.ctx, nil)
+}
+
+// Pause stops the vCPUs of the instance VM
+func (r *UnikernelRuntime) Pause(sname string, instance int) error {
+	log.Instance(sname, instance).Info("Pausing VM", "vm", model.InstanceKey(sname, instance))
+	return r.runQmp(sname, instance, "stop")
+}
+
+// Resume restarts the vCPUs of a paused instance VM
+func (r *UnikernelRuntime) Resume(sname string, instance int) error {
+	log.Instance(sname, instance).Info("Resuming VM", "vm", model.InstanceKey(sname, instance))
+	return r.runQmp(sname, instance, "cont")
+}
+
+// runQmp executes the QMP command on the instance VM
+func (r *UnikernelRuntime) runQmp(sname string, instance int, command string) error {
+	r.channelLock.RLock()
+	domain, found := r.qemuDomains[model.InstanceKey(sname, instance)]
+	r.channelLock.RUnlock()
+	if !found {
+		return errors.New("Service not found")
+	}
+	monitor, err := qmp.NewSocketMonitor("unix", domain.qmpSocket, QMP_TIMEOUT)
+	if err != nil {
+		return err
+	}
+	if err := monitor.Connect(); err != nil {
+		return err
+	}
+	defer func() {
+		if err := monitor.Disconnect(); err != nil {
+			log.Debug("Unable to disconnect from QMP", "vm", domain.Name, logger.ERROR, err)
+		}
+	}()
+	_, err = monitor.Run([]byte(fmt.Sprintf(`{"execute": "%s"}`, command)))
+	return err
+}
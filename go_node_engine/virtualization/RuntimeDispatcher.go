@@ -1,22 +1,68 @@
 package virtualization
 
 import (
+	"context"
+	"go_node_engine/logger"
 	"go_node_engine/model"
+	"sync"
 	"time"
 )
 
+var log = logger.Module("virtualization")
+
+// RuntimeInterface deploys and undeploys the service instances. The deployment spans are children of the span in ctx.
 type RuntimeInterface interface {
-	Deploy(service model.Service, statusChangeNotificationHandler func(service model.Service)) error
+	Deploy(ctx context.Context, service model.Service, statusChangeNotificationHandler func(service model.Service)) error
 	Undeploy(sname string, instance int) error
 }
 
+// RuntimeUpdater is implemented by the runtimes able to replace a running instance in place.
+// The phase callback is called with the model.UPDATE_* phases as the update progresses.
+type RuntimeUpdater interface {
+	Update(current model.Service, replacement model.Service, phase func(phase string), statusChangeNotificationHandler func(service model.Service)) error
+}
+
+// RuntimePauser is implemented by the runtimes able to freeze a running instance and let it continue afterwards
+type RuntimePauser interface {
+	Pause(sname string, instance int) error
+	Resume(sname string, instance int) error
+}
+
 type RuntimeMonitoring interface {
-	ResourceMonitoring(every time.Duration, notifyHandler func(res []model.Resources))
+	ResourceMonitoring(every func() time.Duration, notifyHandler func(res []model.Resources))
 }
 
 type RuntimeType string
 
+// Runtime is a runtime registered in place of a built-in one, e.g. a fake runtime in the end-to-end tests
+type Runtime interface {
+	RuntimeInterface
+	RuntimeMonitoring
+}
+
+var registered = make(map[model.RuntimeType]Runtime)
+var registeredLock sync.RWMutex
+
+// RegisterRuntime serves the instances of the given runtime type with impl instead of the built-in runtime,
+// and advertises the runtime type as supported by the node
+func RegisterRuntime(runtimeType model.RuntimeType, impl Runtime) {
+	registeredLock.Lock()
+	defer registeredLock.Unlock()
+	registered[runtimeType] = impl
+	model.AddSupportedTechnology(runtimeType)
+}
+
+func registeredRuntime(runtimeType model.RuntimeType) (Runtime, bool) {
+	registeredLock.RLock()
+	defer registeredLock.RUnlock()
+	impl, found := registered[runtimeType]
+	return impl, found
+}
+
 func GetRuntime(runtime model.RuntimeType) RuntimeInterface {
+	if impl, found := registeredRuntime(runtime); found {
+		return impl
+	}
 	if runtime == model.CONTAINER_RUNTIME {
 		return GetContainerdClient()
 	}
@@ -27,6 +73,9 @@ func GetRuntime(runtime model.RuntimeType) RuntimeInterface {
 }
 
 func GetRuntimeMonitoring(runtime model.RuntimeType) RuntimeMonitoring {
+	if impl, found := registeredRuntime(runtime); found {
+		return impl
+	}
 	if runtime == model.CONTAINER_RUNTIME {
 		return GetContainerdClient()
 	}
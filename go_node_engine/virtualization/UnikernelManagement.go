@@ -3,17 +3,22 @@ package virtualization
 import (
 	"archive/tar"
 	"compress/gzip"
+	"context"
 	"errors"
 	"fmt"
+	"go_node_engine/config"
 	"go_node_engine/logger"
+	"go_node_engine/metrics"
 	"go_node_engine/model"
 	"go_node_engine/requests"
+	"go_node_engine/tracing"
 	"io"
 	"io/fs"
 	"net"
 	"net/http"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"reflect"
 	rt "runtime"
 	"strings"
@@ -22,6 +27,7 @@ import (
 
 	"github.com/digitalocean/go-qemu/qmp"
 	"github.com/struCoder/pidusage"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type qemuDomain struct {
@@ -29,6 +35,7 @@ type qemuDomain struct {
 	Sname       string
 	Instance    int
 	qemuProcess *os.Process
+	qmpSocket   string
 }
 
 type UnikernelRuntime struct {
@@ -47,39 +54,40 @@ var ukSyncOnce sync.Once
 
 func GetUnikernelRuntime() *UnikernelRuntime {
 	ukSyncOnce.Do(func() {
-		var command string
-		var err error
-
-		//Check which version of qemu is required for kvm support (local arch)
-		if rt.GOARCH == "amd64" {
-			command = "qemu-system-x86_64"
-		} else {
-			command = "qemu-system-aarch64"
-		}
-
-		path, err := exec.LookPath(command)
+		command := QemuCommand()
+		qemuPath, err := exec.LookPath(command)
 		if err != nil {
-			logger.ErrorLogger().Fatalf("Unable to find qemu executable(%s): %v\n", command, err)
+			log.Fatal("Unable to find qemu executable", "command", command, logger.ERROR, err)
 			ukruntime.qemuPath = ""
 		}
-		ukruntime.qemuPath = path
-		logger.InfoLogger().Printf("Using qemu at %s\n", path)
+		ukruntime.qemuPath = qemuPath
+		log.Info("Using qemu", "path", qemuPath)
 		ukruntime.killQueue = make(map[string]*chan bool)
 		ukruntime.qemuDomains = make(map[string]*qemuDomain)
-		err = os.MkdirAll("/tmp/node_engine/kernel/tmp/", 0755)
+		path = filepath.Join(config.Get().Runtime.UnikernelDirectory, "kernel") + "/"
+		inst_path = filepath.Join(config.Get().Runtime.UnikernelDirectory, "inst") + "/"
+		err = os.MkdirAll(path+"tmp/", 0755)
 		if err != nil {
-			logger.ErrorLogger().Printf("Unable to create kernel directory: %v", err)
+			log.Error("Unable to create kernel directory", logger.ERROR, err)
 		}
 
-		err = os.MkdirAll("/tmp/node_engine/inst/", 0755)
+		err = os.MkdirAll(inst_path, 0755)
 		if err != nil {
-			logger.ErrorLogger().Printf("Unable to create instance directory: %v", err)
+			log.Error("Unable to create instance directory", logger.ERROR, err)
 		}
-		model.GetNodeInfo().AddSupportedTechnology(model.UNIKERNEL_RUNTIME)
+		model.AddSupportedTechnology(model.UNIKERNEL_RUNTIME)
 	})
 	return &ukruntime
 }
 
+// QemuCommand returns the qemu executable with kvm support for the local architecture
+func QemuCommand() string {
+	if rt.GOARCH == "amd64" {
+		return "qemu-system-x86_64"
+	}
+	return "qemu-system-aarch64"
+}
+
 func (r *UnikernelRuntime) StopUnikernelRuntime() {
 	r.channelLock.Lock()
 	IDs := reflect.ValueOf(r.killQueue).MapKeys()
@@ -88,34 +96,34 @@ func (r *UnikernelRuntime) StopUnikernelRuntime() {
 		if r.killQueue[id.String()] == nil {
 			continue
 		}
-		logger.InfoLogger().Printf("Stopping VM %s : %s %d\n", id.String(), extractSnameFromTaskID(id.String()), extractInstanceNumberFromTaskID(id.String()))
+		log.Instance(extractSnameFromTaskID(id.String()), extractInstanceNumberFromTaskID(id.String())).Info("Stopping VM", "vm", id.String())
 		err := r.Undeploy(extractSnameFromTaskID(id.String()), extractInstanceNumberFromTaskID(id.String()))
 		if err != nil {
-			logger.ErrorLogger().Printf("Unable to undeploy %s, error: %v", id.String(), err)
+			log.Error("Unable to undeploy", "vm", id.String(), logger.ERROR, err)
 		}
 	}
 
-	logger.InfoLogger().Print("Stopped all Unikernel deployments\n")
+	log.Info("Stopped all Unikernel deployments")
 }
 
-func (r *UnikernelRuntime) Deploy(service model.Service, statusChangeNotificationHandler func(service model.Service)) error {
+func (r *UnikernelRuntime) Deploy(ctx context.Context, service model.Service, statusChangeNotificationHandler func(service model.Service)) error {
 
 	killChannel := make(chan bool, 1)
 	startupChannel := make(chan bool, 0)
 	errorChannel := make(chan error, 0)
 
 	r.channelLock.RLock()
-	el, servicefound := r.killQueue[genTaskID(service.Sname, service.Instance)]
+	el, servicefound := r.killQueue[model.InstanceKey(service.Sname, service.Instance)]
 	r.channelLock.RUnlock()
 	if !servicefound || el == nil {
 		r.channelLock.Lock()
-		r.killQueue[genTaskID(service.Sname, service.Instance)] = &killChannel
+		r.killQueue[model.InstanceKey(service.Sname, service.Instance)] = &killChannel
 		r.channelLock.Unlock()
 	} else {
 		return errors.New("Service already deployed")
 	}
-	logger.InfoLogger().Println("Start Unikernel creation")
-	go r.VirtualMachineCreationRoutine(service, &killChannel, startupChannel, errorChannel, statusChangeNotificationHandler)
+	log.Instance(service.Sname, service.Instance).Info("Start Unikernel creation")
+	go r.VirtualMachineCreationRoutine(ctx, service, &killChannel, startupChannel, errorChannel, statusChangeNotificationHandler)
 
 	if <-startupChannel != true {
 		return <-errorChannel
@@ -128,19 +136,20 @@ func (r *UnikernelRuntime) Undeploy(service string, instance int) error {
 
 	r.channelLock.Lock()
 	defer r.channelLock.Unlock()
-	hostname := genTaskID(service, instance)
+	hostname := model.InstanceKey(service, instance)
 	//r.qemuDomains = append(r.qemuDomains, hostname)
 	el, found := r.killQueue[hostname]
 	if found && el != nil {
-		logger.InfoLogger().Printf("Sending kill signal to VM with hostname: %s", hostname)
+		serviceLog := log.Instance(service, instance)
+		serviceLog.Info("Sending kill signal to VM", "vm", hostname)
 		*r.killQueue[hostname] <- true
 		select {
 		case res := <-*r.killQueue[hostname]:
 			if res == false {
-				logger.ErrorLogger().Printf("Unable to stop VM %s", hostname)
+				serviceLog.Error("Unable to stop VM", "vm", hostname)
 			}
 		case <-time.After(5 * time.Second):
-			logger.ErrorLogger().Printf("Unable to stop service %s", hostname)
+			serviceLog.Error("Unable to stop VM in time", "vm", hostname)
 		}
 
 		delete(r.killQueue, hostname)
@@ -156,6 +165,8 @@ type QemuStopResult struct {
 	}
 }
 
+// path and inst_path are the kernels cache and the instances directories, set at runtime creation
+// under the configured runtime.unikernel_directory
 var path = "/tmp/node_engine/kernel/"
 var inst_path = "/tmp/node_engine/inst/"
 
@@ -171,6 +182,7 @@ func GetKernelImage(kernel string, name string, sname string) *string {
 	kernel_location := path + sname + "/"
 	instance_path := inst_path + name
 	kernel_local := kernel_location + "kernel"
+	kernelLog := log.With(logger.SERVICE, sname, "vm", name)
 
 	/*This is to make sure that in case of a redeployment
 	Makes sure that the directory does not already exists
@@ -183,61 +195,63 @@ func GetKernelImage(kernel string, name string, sname string) *string {
 			time.Sleep(10 * time.Millisecond)
 			continue
 		} else {
-			logger.InfoLogger().Printf("Problem with instance data: %v", err)
+			kernelLog.Error("Problem with instance data", logger.ERROR, err)
 			return nil
 		}
 	}
 	if err := os.Mkdir(instance_path, 0777); err != nil {
-		logger.InfoLogger().Printf("Unable to create instance directory: %v", err)
+		kernelLog.Error("Unable to create instance directory", logger.ERROR, err)
 	}
 
 	var kimage *os.File
 	_, err := os.Stat(kernel_tar)
 	if err != nil {
-		logger.InfoLogger().Printf("Kernel not found locally")
+		kernelLog.Info("Kernel not found locally, downloading it", "kernel", kernel)
 		kimage, err = os.Create(kernel_tar)
 
 		if err != nil {
-			logger.InfoLogger().Printf("Unable to create Kernel: %v", err)
+			kernelLog.Error("Unable to create Kernel", logger.ERROR, err)
 			return nil
 		}
-		
+
 		//defer kimage.Close()
 		defer func() {
 			if err := kimage.Close(); err != nil {
-				logger.InfoLogger().Printf("Unable to close kernel image: %v", err)
+				kernelLog.Error("Unable to close kernel image", logger.ERROR, err)
 			}
 		}()
+		start := time.Now()
 		d, err := http.Get(kernel)
 		if err != nil {
-			logger.InfoLogger().Printf("Unable to locate kernel image (%s): %v", kernel, err)
+			kernelLog.Error("Unable to locate kernel image", "kernel", kernel, logger.ERROR, err)
 			err := os.Remove(kernel_tar)
 			if err != nil {
-				logger.InfoLogger().Printf("Unable to remove kernel image: %v", err)
+				kernelLog.Error("Unable to remove kernel image", logger.ERROR, err)
 			}
 			return nil
 		}
 		size, err := io.Copy(kimage, d.Body)
 		if err != nil {
-			logger.InfoLogger().Printf("Kernel download failed: %v", err)
+			kernelLog.Error("Kernel download failed", logger.ERROR, err)
 			err := os.Remove(kernel_tar)
 			if err != nil {
-				logger.InfoLogger().Printf("Unable to remove kernel image: %v", err)
+				kernelLog.Error("Unable to remove kernel image", logger.ERROR, err)
 			}
 			return nil
 		}
 		if err := d.Body.Close(); err != nil {
-			logger.InfoLogger().Printf("Unable to close kernel image: %v", err)
+			kernelLog.Error("Unable to close kernel image", logger.ERROR, err)
 		}
 
-		logger.InfoLogger().Printf("Written %d B", size)
+		kernelLog.Info("Kernel downloaded", "bytes", size)
+		metrics.PullDuration.WithLabelValues(string(model.UNIKERNEL_RUNTIME)).Observe(time.Since(start).Seconds())
 
 		if err := kimage.Close(); err != nil {
-			logger.InfoLogger().Printf("Unable to close kernel image: %v", err)
+			kernelLog.Error("Unable to close kernel image", logger.ERROR, err)
 		}
 
 		if err := os.Mkdir(kernel_location, 0777); err != nil {
-			logger.InfoLogger().Printf("Unable to create kernel directory: %v", err)
+			kernelLog.Error("Unable to create kernel directory", logger.ERROR, err)
 		}
 		/*unpack Kernel and additional data*/
 		kimage, _ = os.Open(kernel_tar)
@@ -245,13 +259,13 @@ func GetKernelImage(kernel string, name string, sname string) *string {
 
 		defer func() {
 			if err := kimage.Close(); err != nil {
-				logger.InfoLogger().Printf("Unable to close kernel image: %v", err)
+				kernelLog.Error("Unable to close kernel image", logger.ERROR, err)
 			}
 		}()
 
 		exdata, err := gzip.NewReader(kimage)
 		if err != nil {
-			logger.InfoLogger().Printf("Unable to open kernel archive: %v", err)
+			kernelLog.Error("Unable to open kernel archive", logger.ERROR, err)
 		}
 		tardata := tar.NewReader(exdata)
 
@@ -262,7 +276,7 @@ func GetKernelImage(kernel string, name string, sname string) *string {
 				if err == io.EOF {
 					break
 				}
-				logger.InfoLogger().Printf("Unable to read tar: %v", err)
+				kernelLog.Error("Unable to read tar", logger.ERROR, err)
 				return nil
 			}
 
@@ -270,56 +284,56 @@ func GetKernelImage(kernel string, name string, sname string) *string {
 			case tar.TypeDir:
 				err := os.Mkdir(kernel_location+header.Name, 0777)
 				if err != nil {
-					logger.InfoLogger().Printf("Unable to create dir: %v", err)
+					kernelLog.Error("Unable to create dir", logger.ERROR, err)
 				}
 			case tar.TypeReg:
 				file, err := os.Create(kernel_location + header.Name)
 				if err != nil {
-					logger.InfoLogger().Printf("Unable to create file: %v", err)
+					kernelLog.Error("Unable to create file", logger.ERROR, err)
 				}
 				_, err = io.Copy(file, tardata)
 				if err != nil {
-					logger.InfoLogger().Printf("File copy failed: %v", err)
+					kernelLog.Error("File copy failed", logger.ERROR, err)
 				}
 			default:
-				logger.InfoLogger().Printf("ERROR: incorrect typeflag")
+				kernelLog.Error("Incorrect typeflag", "entry", header.Name)
 				return nil
 
 			}
 
 		}
 	} else {
-		logger.InfoLogger().Printf("Kernel found locally")
+		kernelLog.Info("Kernel found locally")
 	}
 	if err != nil {
-		logger.InfoLogger().Printf("Unable to open kernel archive: %v", err)
+		kernelLog.Error("Unable to open kernel archive", logger.ERROR, err)
 		return nil
 	}
 
 	_, err = os.Stat(kernel_location + "files")
 	if !errors.Is(err, fs.ErrNotExist) {
-		logger.InfoLogger().Printf("Creating new instance envioument %s -> %s", kernel_location+"files", instance_path)
+		kernelLog.Info("Creating new instance environment", "source", kernel_location+"files", "destination", instance_path)
 
 		err = exec.Command("cp", "-r", kernel_location+"files", instance_path).Run()
 		if err != nil {
-			logger.InfoLogger().Printf("Unable to set files: %v", err)
+			kernelLog.Error("Unable to set files", logger.ERROR, err)
 		}
 	}
 
 	//Kernel image is expected at a fixed location within the archive ./kernel
 	_, err = os.Stat(kernel_local)
 	if err != nil {
-		logger.InfoLogger().Printf("Archive does not seem to contain the kernel image: %v", err)
+		kernelLog.Error("Archive does not seem to contain the kernel image", logger.ERROR, err)
 		return nil
 	}
-	logger.InfoLogger().Printf("Kernel location: %s", kernel_local)
+	kernelLog.Info("Kernel ready", "location", kernel_local)
 
 	return &instance_path
 }
 
 func getUnikernelURL(position int, code string) string {
 	addr := strings.Split(code, ",")
-	logger.InfoLogger().Printf("%v", addr)
+	log.Debug("Unikernel images", "urls", addr)
 	if position >= len(addr) {
 		return ""
 	}
@@ -327,6 +341,7 @@ func getUnikernelURL(position int, code string) string {
 }
 
 func (r *UnikernelRuntime) VirtualMachineCreationRoutine(
+	ctx context.Context,
 	service model.Service,
 	killChannel *chan bool,
 	startup chan bool,
@@ -339,7 +354,8 @@ func (r *UnikernelRuntime) VirtualMachineCreationRoutine(
 	qemuConfig.Memory = service.Memory
 	qemuConfig.CPU = service.Vcpus
 	//hostname is used as name for the namespace in which the unikernel will be running in
-	hostname := genTaskID(service.Sname, service.Instance)
+	hostname := model.InstanceKey(service.Sname, service.Instance)
+	serviceLog := log.Instance(service.Sname, service.Instance)
 	qemuConfig.Name = hostname
 	qemuConfig.NSname = &hostname
 
@@ -352,12 +368,18 @@ func (r *UnikernelRuntime) VirtualMachineCreationRoutine(
 	}
 
 	if kernelImage == "" {
-		logger.InfoLogger().Printf("Failed to find kernel/architecture pair.")
+		serviceLog.Error("Failed to find kernel/architecture pair", "architecture", rt.GOARCH)
 	}
 
+	_, pullSpan := tracing.Start(ctx, tracing.SPAN_PULL, attribute.String("oakestra.image", kernelImage))
 	kernelPath := GetKernelImage(kernelImage, hostname, service.Sname)
 	if kernelPath == nil {
-		logger.InfoLogger().Println("Failed to get Kernel image")
+		tracing.End(pullSpan, errors.New("unable to get the kernel image"))
+	} else {
+		pullSpan.End()
+	}
+	if kernelPath == nil {
+		serviceLog.Error("Failed to get Kernel image")
 		return
 	}
 	qemuConfig.Kernel = path + service.Sname + "/kernel"
@@ -371,17 +393,19 @@ func (r *UnikernelRuntime) VirtualMachineCreationRoutine(
 		defer r.channelLock.Unlock()
 		r.killQueue[hostname] = nil
 		if err := os.RemoveAll(inst_path + instance); err != nil {
-			logger.InfoLogger().Printf("Unable to remove instance data: %v", err)
+			serviceLog.Error("Unable to remove instance data", logger.ERROR, err)
 		}
-		logger.InfoLogger().Printf("Removing Instance data -- ")
+		serviceLog.Info("Removing instance data", "path", inst_path+instance)
 	}
 	var qemuCmd *exec.Cmd
 	var err error
-	if model.GetNodeInfo().Overlay {
+	if model.GetCachedNodeInfo().Overlay {
 		//Use Overlay Network to configure network
+		_, networkSpan := tracing.Start(ctx, tracing.SPAN_NETWORK)
 		err := requests.CreateNetworkNamespaceForUnikernel(service.Sname, service.Instance, service.Ports)
+		tracing.End(networkSpan, err)
 		if err != nil {
-			logger.InfoLogger().Printf("Network creation for Unikernel failed: %v\n", err)
+			serviceLog.Error("Network creation for Unikernel failed", logger.ERROR, err)
 			return
 		}
 	}
@@ -392,15 +416,18 @@ func (r *UnikernelRuntime) VirtualMachineCreationRoutine(
 	command, args := qemuConfig.GenerateArgs(r)
 	qemuCmd = exec.Command(command, args...)
 
-	logger.InfoLogger().Printf("Unikernel starting command: %s", qemuCmd.String())
+	serviceLog.Info("Unikernel starting", "command", qemuCmd.String())
 
+	_, createSpan := tracing.Start(ctx, tracing.SPAN_CREATE, attribute.String("oakestra.vm", hostname))
 	err = qemuCmd.Start()
+	tracing.End(createSpan, err)
 	if err != nil {
-		logger.ErrorLogger().Printf("Failed to start qemu: %v", err)
+		serviceLog.Error("Failed to start qemu", logger.ERROR, err)
 		revert(err, hostname)
 		return
 	}
-	logger.InfoLogger().Println("Unikernel started")
+	_, startSpan := tracing.Start(ctx, tracing.SPAN_START)
+	serviceLog.Info("Unikernel started")
 
 	exitStatusQemu := make(chan int)
 
@@ -408,10 +435,10 @@ func (r *UnikernelRuntime) VirtualMachineCreationRoutine(
 		err = qemuCmd.Wait()
 		if err != nil {
 			if e, ok := err.(*exec.ExitError); ok {
-				logger.InfoLogger().Printf("Qemu exited with code %d and error %s", e.ExitCode(), string(e.Stderr))
+				serviceLog.Warn("Qemu exited", "exit_code", e.ExitCode(), "stderr", string(e.Stderr))
 				status <- e.ExitCode()
 			} else {
-				logger.InfoLogger().Printf("Unexpected error occured %v", err)
+				serviceLog.Error("Unexpected error waiting for qemu", logger.ERROR, err)
 				status <- -1
 			}
 		} else {
@@ -427,21 +454,23 @@ func (r *UnikernelRuntime) VirtualMachineCreationRoutine(
 	}
 
 	socketPath := fmt.Sprintf("%s/%s", qemuConfig.Instancepath, hostname)
+	Domain.qmpSocket = socketPath
 	for i := 0; i < 3; i++ {
 		//Wait for qemu to properly start up maximum 3 times
 		conn, err := net.DialTimeout("unix", socketPath, 2*time.Second)
 
 		if errors.Is(err, os.ErrNotExist) {
-			//logger.InfoLogger().Printf("Waiting: %v", err)
+			//serviceLog.Debug("Waiting", logger.ERROR, err)
 			time.Sleep(10 * time.Millisecond)
 		} else if err != nil {
 			if !strings.HasSuffix(err.Error(), ": connection refused") {
-				logger.InfoLogger().Printf("Something went wrong while starting Qemu %v", err)
+				serviceLog.Error("Something went wrong while starting Qemu", logger.ERROR, err)
+				tracing.End(startSpan, err)
 				revert(err, hostname)
-				if model.GetNodeInfo().Overlay {
+				if model.GetCachedNodeInfo().Overlay {
 					err = requests.DeleteNamespaceForUnikernel(service.Sname, service.Instance)
 					if err != nil {
-						logger.InfoLogger().Printf("Unable to undeploy %s's network: %v", hostname, err)
+						serviceLog.Error("Unable to undeploy the VM network", "vm", hostname, logger.ERROR, err)
 					}
 				}
 				if qemuCmd.Process != nil {
@@ -449,7 +478,7 @@ func (r *UnikernelRuntime) VirtualMachineCreationRoutine(
 				}
 				return
 			}
-			//logger.InfoLogger().Printf("Waiting: %v", err)
+			//serviceLog.Debug("Waiting", logger.ERROR, err)
 
 		} else {
 			conn.Close() //nolint:errcheck // Ignore error check for close
@@ -458,15 +487,16 @@ func (r *UnikernelRuntime) VirtualMachineCreationRoutine(
 
 	}
 
-	logger.InfoLogger().Printf("Trying to connec to to %s", socketPath)
+	serviceLog.Info("Connecting to QMP", "socket", socketPath)
 	qemuMonitor, err = qmp.NewSocketMonitor("unix", socketPath, 2*time.Second)
 	if err != nil {
-		logger.InfoLogger().Printf("Failed to Create connection to QMP: %v\n", err)
+		serviceLog.Error("Failed to Create connection to QMP", logger.ERROR, err)
+		tracing.End(startSpan, err)
 		revert(err, hostname)
-		if model.GetNodeInfo().Overlay {
+		if model.GetCachedNodeInfo().Overlay {
 			err = requests.DeleteNamespaceForUnikernel(service.Sname, service.Instance)
 			if err != nil {
-				logger.InfoLogger().Printf("Unable to undeploy %s's network: %v", hostname, err)
+				serviceLog.Error("Unable to undeploy the VM network", "vm", hostname, logger.ERROR, err)
 			}
 		}
 		//Kill the qemu process because of no qmp connectivity
@@ -482,21 +512,21 @@ func (r *UnikernelRuntime) VirtualMachineCreationRoutine(
 	r.channelLock.Unlock()
 
 	defer func(monitor *qmp.SocketMonitor) {
-		logger.InfoLogger().Printf("Trying to kill VM %s", hostname)
+		serviceLog.Info("Trying to kill VM", "vm", hostname)
 		//There is no guaranteed answer for the quit Command
 		cmd := []byte(`{"execute": "quit"}`)
 		err := monitor.Connect()
 
 		if err != nil {
-			logger.InfoLogger().Printf("Failed to connect to qmp: %v", err)
+			serviceLog.Error("Failed to connect to qmp", logger.ERROR, err)
 		}
 		_, err = monitor.Run(cmd)
 		if err != nil {
-			logger.InfoLogger().Printf("Failed to close qemu: %v\n", err)
+			serviceLog.Error("Failed to close qemu", logger.ERROR, err)
 		}
 		err = monitor.Disconnect()
 		if err != nil {
-			logger.InfoLogger().Printf("Failed to close connection (expected): %v", err)
+			serviceLog.Debug("Failed to close connection (expected)", logger.ERROR, err)
 		}
 
 		r.channelLock.Lock()
@@ -505,17 +535,17 @@ func (r *UnikernelRuntime) VirtualMachineCreationRoutine(
 		r.channelLock.Unlock()
 
 		//Undeploy the network -> Delete Namespace
-		if model.GetNodeInfo().Overlay {
+		if model.GetCachedNodeInfo().Overlay {
 			err = requests.DeleteNamespaceForUnikernel(service.Sname, service.Instance)
 			if err != nil {
-				logger.InfoLogger().Printf("Unable to undeploy %s's network: %v", hostname, err)
+				serviceLog.Error("Unable to undeploy the VM network", "vm", hostname, logger.ERROR, err)
 			}
 		}
 		//Delete instance folder
 		if err := os.RemoveAll(inst_path + hostname); err != nil {
-			logger.InfoLogger().Printf("Unable to remove instance data: %v", err)
+			serviceLog.Error("Unable to remove instance data", logger.ERROR, err)
 		}
-		logger.InfoLogger().Printf("Removing Instance data %s", inst_path+hostname)
+		serviceLog.Info("Removing instance data", "path", inst_path+hostname)
 
 		if err != nil {
 			*killChannel <- false
@@ -524,34 +554,35 @@ func (r *UnikernelRuntime) VirtualMachineCreationRoutine(
 		}
 	}(qemuMonitor)
 
+	startSpan.End()
 	startup <- true
 	select {
 	case return_value := <-exitStatusQemu:
-		logger.InfoLogger().Printf("Received status back from Qemu process %d", return_value)
+		serviceLog.Info("Received status back from Qemu process", "exit_code", return_value)
 	case <-*killChannel:
-		logger.InfoLogger().Printf("Kill channel message received for unikernel")
+		serviceLog.Info("Kill channel message received for unikernel")
 	}
 	service.Status = model.SERVICE_DEAD
 	statusChangeNotificationHandler(service)
 }
 
-func (r *UnikernelRuntime) ResourceMonitoring(every time.Duration, notifyHandler func(res []model.Resources)) {
+func (r *UnikernelRuntime) ResourceMonitoring(every func() time.Duration, notifyHandler func(res []model.Resources)) {
 
 	for true {
 		select {
-		case <-time.After(every):
+		case <-time.After(every()):
 			resourceList := make([]model.Resources, 0)
 			for _, domain := range r.qemuDomains {
 				//Get CPU and memory stats based on pid
 				sysInfo, err := pidusage.GetStat(domain.qemuProcess.Pid)
 				if err != nil {
-					logger.ErrorLogger().Printf("Unable to fetch task info: %v", err)
+					log.Instance(domain.Sname, domain.Instance).Error("Unable to fetch VM info", logger.ERROR, err)
 					continue
 				}
 				resourceList = append(resourceList, model.Resources{
-					Cpu:      fmt.Sprintf("%f", sysInfo.CPU),
-					Memory:   fmt.Sprintf("%f", sysInfo.Memory),
-					Disk:     fmt.Sprintf("%d", 0),
+					Cpu:      sysInfo.CPU,
+					Memory:   sysInfo.Memory,
+					Disk:     0,
 					Sname:    domain.Sname,
 					Logs:     getLogs(domain.Name),
 					Runtime:  string(model.UNIKERNEL_RUNTIME),
@@ -579,7 +610,7 @@ func (q *QemuConfiguration) GenerateArgs(r *UnikernelRuntime) (string, []string)
 	args := make([]string, 0)
 	command := r.qemuPath
 	//Check if Qemu needs to run in different Namespace
-	if model.GetNodeInfo().Overlay {
+	if model.GetCachedNodeInfo().Overlay {
 		command = "ip"
 		args = append(args, "netns", "exec", *q.NSname, r.qemuPath)
 	}
@@ -588,7 +619,7 @@ func (q *QemuConfiguration) GenerateArgs(r *UnikernelRuntime) (string, []string)
 	args = append(args, "-name", name)
 
 	//Set qemu log folder
-	serialiface := fmt.Sprintf("file:%s/%s", model.GetNodeInfo().LogDirectory, q.Name)
+	serialiface := fmt.Sprintf("file:%s/%s", model.GetCachedNodeInfo().LogDirectory, q.Name)
 	args = append(args, "-serial", serialiface)
 
 	//Kernel image
@@ -600,7 +631,7 @@ func (q *QemuConfiguration) GenerateArgs(r *UnikernelRuntime) (string, []string)
 	args = append(args, "-m", memory, "-smp", fmt.Sprintf("%d", q.CPU))
 
 	//Network
-	if model.GetNodeInfo().Overlay {
+	if model.GetCachedNodeInfo().Overlay {
 		//Network backend fixed at tap0 and virbr0 created inside the namespace
 		args = append(args, "-netdev", "tap,id=tap0,ifname=tap0,script=no,downscript=no,br=virbr0,vhost=on")
 		//Network device
@@ -619,7 +650,7 @@ func (q *QemuConfiguration) GenerateArgs(r *UnikernelRuntime) (string, []string)
 	mountpath := fmt.Sprintf("%s/files/", q.Instancepath)
 	_, err := os.Stat(mountpath)
 	if err == nil {
-		logger.InfoLogger().Println("Mounting as folder for unikernel")
+		log.Info("Mounting as folder for unikernel", "path", mountpath)
 
 		//FS backend
 		fsdevarg := fmt.Sprintf("local,security_model=passthrough,id=hvirtio0,path=%s/files", q.Instancepath)
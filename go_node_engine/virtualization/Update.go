@@ -0,0 +1,205 @@
+package virtualization
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go_node_engine/config"
+	"go_node_engine/logger"
+	"go_node_engine/model"
+	"sync/atomic"
+	"time"
+)
+
+// STOP_TIMEOUT is the time waited for a replaced instance to stop
+const STOP_TIMEOUT = 10 * time.Second
+
+// RollbackError is returned by an update that failed and could not restore the instance it was replacing
+type RollbackError struct {
+	Cause error
+	Err   error
+}
+
+func (e *RollbackError) Error() string {
+	return fmt.Sprintf("%v, rollback failed: %v", e.Cause, e.Err)
+}
+
+// Update replaces the running instance with the replacement spec: the new image is pulled, the replacement started
+// and, once it kept running for the readiness period, the current instance is stopped.
+// With the overlay network the instance address can be attached only once, the current instance is then stopped
+// right before the replacement starts and started again if the replacement does not become ready.
+// The replacement reuses the accelerators of the current instance, only the additional ones must be free.
+// On failure the current instance keeps running, unless a *RollbackError is returned.
+func (r *ContainerRuntime) Update(
+	current model.Service,
+	replacement model.Service,
+	phase func(phase string),
+	statusChangeNotificationHandler func(service model.Service),
+) error {
+	taskid := model.InstanceKey(current.Sname, current.Instance)
+	r.channelLock.RLock()
+	currentKill := r.killQueue[taskid]
+	r.channelLock.RUnlock()
+	if currentKill == nil {
+		return errors.New("service not found")
+	}
+	r.updateLock.Lock()
+	currentID := r.containers[taskid]
+	r.revision++
+	replacementID := fmt.Sprintf("%s.rev%d", taskid, r.revision)
+	r.updateLock.Unlock()
+
+	phase(model.UPDATE_PULLING)
+	image, err := r.getImage(context.Background(), replacement.Image)
+	if err != nil {
+		return err
+	}
+	accelerators, err := allocateReplacement(currentID, replacementID, replacement)
+	if err != nil {
+		return err
+	}
+
+	overlay := model.GetCachedNodeInfo().Overlay
+	if overlay {
+		phase(model.UPDATE_STOPPING)
+		if err := r.stopReplaced(currentID, currentKill); err != nil {
+			releaseAccelerators(replacementID)
+			return err
+		}
+	}
+
+	phase(model.UPDATE_STARTING)
+	killChannel := make(chan bool, 1)
+	gate := newReadinessGate(statusChangeNotificationHandler)
+	err = r.startContainer(context.Background(), image, replacementID, replacement, accelerators, &killChannel, gate.notify)
+	if err == nil {
+		phase(model.UPDATE_READINESS)
+		err = gate.wait(config.Get().Runtime.UpdateReadiness)
+	}
+	if err != nil {
+		if overlay {
+			// the devices reused by the replacement are given back once it is gone
+			r.awaitExit(replacementID)
+			if rollbackErr := r.restart(current, currentID, statusChangeNotificationHandler); rollbackErr != nil {
+				return &RollbackError{Cause: err, Err: rollbackErr}
+			}
+		}
+		return err
+	}
+
+	keepAccelerators(replacementID)
+	r.channelLock.Lock()
+	r.killQueue[taskid] = &killChannel
+	r.channelLock.Unlock()
+	r.updateLock.Lock()
+	r.containers[taskid] = replacementID
+	r.updateLock.Unlock()
+
+	if !overlay {
+		phase(model.UPDATE_STOPPING)
+		if err := r.stopReplaced(currentID, currentKill); err != nil {
+			log.Instance(current.Sname, current.Instance).Error("Unable to stop the replaced container", "container", currentID, logger.ERROR, err)
+		}
+	}
+	return nil
+}
+
+// stopReplaced stops a container replaced by an update, without reporting the end of the instance
+func (r *ContainerRuntime) stopReplaced(containerID string, killChannel *chan bool) error {
+	r.updateLock.Lock()
+	exited, running := r.exits[containerID]
+	if running {
+		r.silenced[containerID] = true
+	}
+	r.updateLock.Unlock()
+	if !running {
+		return nil
+	}
+	select {
+	case *killChannel <- true:
+	default:
+	}
+	select {
+	case <-exited:
+		return nil
+	case <-time.After(STOP_TIMEOUT):
+		return fmt.Errorf("container %s did not stop in time", containerID)
+	}
+}
+
+// awaitExit waits for the routine of the container to end, releasing its devices, if still running
+func (r *ContainerRuntime) awaitExit(containerID string) {
+	r.updateLock.Lock()
+	exited, running := r.exits[containerID]
+	r.updateLock.Unlock()
+	if !running {
+		return
+	}
+	select {
+	case <-exited:
+	case <-time.After(STOP_TIMEOUT):
+	}
+}
+
+// restart starts again the spec of an instance stopped by a failed update, reusing the devices of its stopped container
+func (r *ContainerRuntime) restart(service model.Service, stoppedID string, statusChangeNotificationHandler func(service model.Service)) error {
+	taskid := model.InstanceKey(service.Sname, service.Instance)
+	r.updateLock.Lock()
+	r.revision++
+	containerID := fmt.Sprintf("%s.rev%d", taskid, r.revision)
+	r.updateLock.Unlock()
+
+	image, err := r.getImage(context.Background(), service.Image)
+	if err != nil {
+		return err
+	}
+	accelerators, err := allocateReplacement(stoppedID, containerID, service)
+	// the devices the stopped container still holds are not used anymore
+	keepAccelerators(containerID)
+	releaseAccelerators(stoppedID)
+	if err != nil {
+		return err
+	}
+	killChannel := make(chan bool, 1)
+	r.channelLock.Lock()
+	r.killQueue[taskid] = &killChannel
+	r.channelLock.Unlock()
+	r.updateLock.Lock()
+	r.containers[taskid] = containerID
+	r.updateLock.Unlock()
+	return r.startContainer(context.Background(), image, containerID, service, accelerators, &killChannel, statusChangeNotificationHandler)
+}
+
+// readinessGate holds back the end of a replacement during its readiness period, turning it into an update failure.
+// Afterwards the status changes go through to the handler.
+type readinessGate struct {
+	settled int32
+	exited  chan model.Service
+	handler func(service model.Service)
+}
+
+func newReadinessGate(handler func(service model.Service)) *readinessGate {
+	return &readinessGate{exited: make(chan model.Service, 1), handler: handler}
+}
+
+func (g *readinessGate) notify(service model.Service) {
+	if atomic.CompareAndSwapInt32(&g.settled, 0, 1) {
+		g.exited <- service
+		return
+	}
+	g.handler(service)
+}
+
+// wait returns an error if the replacement ended before the readiness period elapsed
+func (g *readinessGate) wait(period time.Duration) error {
+	select {
+	case service := <-g.exited:
+		return fmt.Errorf("replacement not ready: %s %s", service.Status, service.StatusDetail)
+	case <-time.After(period):
+		if atomic.CompareAndSwapInt32(&g.settled, 0, 1) {
+			return nil
+		}
+		service := <-g.exited
+		return fmt.Errorf("replacement not ready: %s %s", service.Status, service.StatusDetail)
+	}
+}
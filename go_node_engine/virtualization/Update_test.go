@@ -0,0 +1,28 @@
+package virtualization
+
+import (
+	"go_node_engine/model"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+)
+
+func TestReadinessGateReady(t *testing.T) {
+	notified := make([]model.Service, 0)
+	gate := newReadinessGate(func(service model.Service) { notified = append(notified, service) })
+	assert.NilError(t, gate.wait(time.Millisecond))
+
+	gate.notify(model.Service{Status: model.SERVICE_DEAD})
+	assert.Equal(t, len(notified), 1)
+}
+
+func TestReadinessGateExited(t *testing.T) {
+	notified := 0
+	gate := newReadinessGate(func(service model.Service) { notified++ })
+	gate.notify(model.Service{Status: model.SERVICE_DEAD, StatusDetail: "Container exited with status: 1"})
+
+	err := gate.wait(time.Minute)
+	assert.ErrorContains(t, err, "Container exited with status: 1")
+	assert.Equal(t, notified, 0)
+}
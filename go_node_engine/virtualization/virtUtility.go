@@ -9,25 +9,34 @@ import (
 
 const LOG_SIZE = 1024
 
+// LogPath returns the file the output of the instance is written to
+func LogPath(sname string, instance int) string {
+	return logPath(model.InstanceKey(sname, instance))
+}
+
+func logPath(serviceID string) string {
+	return fmt.Sprintf("%s/%s", model.GetCachedNodeInfo().LogDirectory, serviceID)
+}
+
 // reads the last 100 bytes of the logfile of a container
 func getLogs(serviceID string) string {
 
-	file, err := os.Open(fmt.Sprintf("%s/%s", model.GetNodeInfo().LogDirectory, serviceID))
+	file, err := os.Open(logPath(serviceID))
 	if err != nil {
-		logger.ErrorLogger().Printf("%v", err)
+		log.Error("Unable to open the service logs", "task", serviceID, logger.ERROR, err)
 		return ""
 	}
 	//defer file.Close()
 	defer func() {
 		if err := file.Close(); err != nil {
-			logger.ErrorLogger().Printf("%v", err)
+			log.Error("Unable to close the service logs", "task", serviceID, logger.ERROR, err)
 		}
 	}()
 
 	buf := make([]byte, LOG_SIZE)
 	stat, err := file.Stat()
 	if err != nil {
-		logger.ErrorLogger().Printf("%v", err)
+		log.Error("Unable to read the service logs", "task", serviceID, logger.ERROR, err)
 		return ""
 	}
 